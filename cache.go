@@ -1,10 +1,9 @@
 package pepperlint
 
 import (
-	"fmt"
 	"go/ast"
-	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // Cache defintion that contain type information per package.
@@ -12,6 +11,13 @@ type Cache struct {
 	Packages             Packages
 	CurrentPkgImportPath string
 	CurrentASTFile       *ast.File
+
+	// filenames contains the filename of every file of the package that is
+	// currently being cached.
+	filenames map[*ast.File]string
+
+	// sources contains the contents of linted files.
+	sources map[*ast.File][]byte
 }
 
 // NewCache will return a new cache along with initializing any fields.
@@ -21,6 +27,42 @@ func NewCache() *Cache {
 	}
 }
 
+// Snapshot returns a cache that shares the packages of c, but keeps track of
+// its own current package and file. This allows for the same cache to be used
+// by visitors in separate goroutines, as long as the packages are no longer
+// being modified.
+func (c *Cache) Snapshot() *Cache {
+	return &Cache{
+		Packages: c.Packages,
+		sources:  c.sources,
+	}
+}
+
+// Source will return the contents that f was parsed from. Only the sources of
+// files that are linted by Run are known, and false is returned for any other
+// file.
+func (c Cache) Source(f *ast.File) ([]byte, bool) {
+	src, ok := c.sources[f]
+	return src, ok
+}
+
+// Copy returns a cache with copies of the packages of c, so any package that is
+// cached by the copy does not modify c. Files and sources are shared between
+// both caches.
+func (c *Cache) Copy() *Cache {
+	packages := make(Packages, len(c.Packages))
+	for importPath, pkg := range c.Packages {
+		cp := *pkg
+		cp.Files = append(Files(nil), pkg.Files...)
+		packages[importPath] = &cp
+	}
+
+	return &Cache{
+		Packages: packages,
+		sources:  c.sources,
+	}
+}
+
 // CurrentPackage will attempt to return the current package. If CurrentPkgImportPath
 // was not found in the map, then false will be returned.
 func (c Cache) CurrentPackage() (*Package, bool) {
@@ -45,6 +87,17 @@ func (c Cache) CurrentFile() (*File, bool) {
 	return nil, false
 }
 
+// IsTestFile will return true if the file that is currently being visited is a
+// test file.
+func (c Cache) IsTestFile() bool {
+	f, ok := c.CurrentFile()
+	if !ok {
+		return false
+	}
+
+	return f.IsTest()
+}
+
 // Packages is a map of Packages that keyed off of the import path.
 type Packages map[string]*Package
 
@@ -60,6 +113,27 @@ type Package struct {
 	Files Files
 }
 
+// Method will return the method methodName of the type typeName, which may be
+// declared in any file of the package.
+func (p *Package) Method(typeName, methodName string) (OpInfo, bool) {
+	info, ok := p.Files.GetOpInfo(methodKey(typeName, methodName))
+	if !ok || !info.IsMethod {
+		return OpInfo{}, false
+	}
+
+	return info, true
+}
+
+// Func will return the function name, and never a method of the same name.
+func (p *Package) Func(name string) (OpInfo, bool) {
+	info, ok := p.Files.GetOpInfo(name)
+	if !ok || info.IsMethod {
+		return OpInfo{}, false
+	}
+
+	return info, true
+}
+
 // Files is a list of Files
 type Files []*File
 
@@ -79,7 +153,11 @@ func (fs Files) GetTypeInfo(typeName string) (TypeInfo, bool) {
 }
 
 // GetOpInfo will iterate through all the files in an atttempt to grab the specified
-// op info by the op name provided.
+// op info by the op name provided. Functions are keyed by their name, and methods
+// by the name of their receiver type and their own, as in Type.Method. If no op is
+// keyed by opName, a method named opName is returned, as methods used to be keyed
+// by their name alone. When more than one type has such a method, the method of
+// the type whose name sorts first is returned.
 func (fs Files) GetOpInfo(opName string) (OpInfo, bool) {
 	for _, f := range fs {
 		info, ok := f.OpInfos[opName]
@@ -90,12 +168,31 @@ func (fs Files) GetOpInfo(opName string) (OpInfo, bool) {
 		return info, true
 	}
 
-	return OpInfo{}, false
+	if strings.Contains(opName, ".") {
+		return OpInfo{}, false
+	}
+
+	key := ""
+	found := OpInfo{}
+	for _, f := range fs {
+		for k, info := range f.OpInfos {
+			if !info.IsMethod || k != methodKey(info.Receiver, opName) {
+				continue
+			}
+
+			if len(key) == 0 || k < key {
+				key, found = k, info
+			}
+		}
+	}
+
+	return found, len(key) > 0
 }
 
 // File contains the file scope of types and operation infos
 type File struct {
 	ASTFile   *ast.File
+	Filename  string
 	TypeInfos TypeInfos
 	OpInfos   OpInfos
 
@@ -113,6 +210,11 @@ func NewFile(t *ast.File) *File {
 	}
 }
 
+// IsTest will return true if the file is a test file.
+func (f *File) IsTest() bool {
+	return IsTestFilename(f.Filename)
+}
+
 // TypeInfos represents a map of TypeInfos
 type TypeInfos map[string]TypeInfo
 
@@ -124,7 +226,9 @@ type TypeInfo struct {
 }
 
 // OpInfos is a map of key operation name and OpInfo containing
-// relevant per package operation declarations.
+// relevant per package operation declarations. Methods are keyed by their
+// receiver type name and method name, so methods of the same name on
+// different types do not overwrite each other.
 type OpInfos map[string]OpInfo
 
 // OpInfo signifies an operation which is a method or function.
@@ -133,6 +237,36 @@ type OpInfo struct {
 	TypeSpecs []*ast.TypeSpec
 	Decl      *ast.FuncDecl
 	PkgName   string
+
+	// Receiver is the name of the receiver type of a method, which is known
+	// even if the type spec could not be resolved.
+	Receiver string
+}
+
+// methodKey will return the key of a method in OpInfos.
+func methodKey(typeName, methodName string) string {
+	return typeName + "." + methodName
+}
+
+// receiverTypeName will return the name of the type of a method receiver,
+// without any pointer or type parameters.
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.ParenExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	}
+
+	if x, ok := indexListX(expr); ok {
+		return receiverTypeName(x)
+	}
+
+	return ""
 }
 
 // HasReceiverType will return true if the method has a receiver of type
@@ -171,26 +305,22 @@ func (c *Cache) getTypeFromField(expr ast.Expr) (*ast.TypeSpec, bool) {
 	case *ast.StarExpr:
 		return c.getTypeFromField(fieldType.X)
 	default:
-		Log("TODO getTypeFromField %T", fieldType)
+		Unhandled(nil, nil, "Cache.getTypeFromField", fieldType)
 	}
 
 	return nil, false
 }
 
-// Visit will cache all specifications and docs
+// Visit will cache all specifications and docs. Only top level declarations
+// are cached, so function bodies and the contents of specs are never walked.
 func (c *Cache) Visit(node ast.Node) ast.Visitor {
 	switch t := node.(type) {
 	case *ast.FuncDecl:
-		pkg, ok := c.Packages.Get(c.CurrentPkgImportPath)
-
+		pkg, f, ok := c.currentCacheFile()
 		if !ok {
-			pkg = &Package{}
-			c.Packages[c.CurrentPkgImportPath] = pkg
+			return nil
 		}
 
-		// Get most recent file
-		f := pkg.Files[len(pkg.Files)-1]
-
 		method := false
 		opInfo := OpInfo{
 			Decl:    t,
@@ -204,6 +334,10 @@ func (c *Cache) Visit(node ast.Node) ast.Visitor {
 			// methods can have multiple receivers it looks like.
 			for _, field := range t.Recv.List {
 				method = true
+				if len(opInfo.Receiver) == 0 {
+					opInfo.Receiver = receiverTypeName(field.Type)
+				}
+
 				spec, ok := c.getTypeFromField(field.Type)
 				if !ok {
 					continue
@@ -215,43 +349,31 @@ func (c *Cache) Visit(node ast.Node) ast.Visitor {
 			opInfo.IsMethod = method
 		}
 
-		f.OpInfos[t.Name.Name] = opInfo
-
-	case *ast.ImportSpec:
-		pkg, ok := c.CurrentPackage()
-		if !ok {
-			panic("Current package could not be found")
+		key := t.Name.Name
+		if opInfo.IsMethod {
+			key = methodKey(opInfo.Receiver, key)
 		}
+		f.OpInfos[key] = opInfo
 
-		importPath, err := strconv.Unquote(t.Path.Value)
-		if err != nil {
-			importPath = t.Path.Value
-		}
-
-		f := pkg.Files[len(pkg.Files)-1]
-		if t.Name != nil {
-			name, err := strconv.Unquote(t.Name.Name)
-			if err != nil {
-				name = t.Name.Name
-			}
-
-			f.Imports[name] = importPath
-		} else {
-			f.Imports[GetPackageNameFromImportPath(importPath)] = importPath
-		}
+		// nothing within a function body is cached
+		return nil
+	case *ast.ImportSpec:
+		c.addImport(t)
 
 	case *ast.Package:
-		// iterate through files to get the full path of the file
-		for k := range t.Files {
-			c.CurrentPkgImportPath = GetImportPathFromFullPath(filepath.Dir(k))
-			break
+		c.CurrentPkgImportPath = GetImportPathFromPackage(t)
+
+		c.filenames = map[*ast.File]string{}
+		for filename, f := range t.Files {
+			c.filenames[f] = filename
 		}
 
 		// Issue #13
 		//
 		// Was squashing over package names that may exist in the same package
 		// but contain a pkg_test format. That would mean all previous cached
-		// files would be lost
+		// files would be lost. External test packages now have their own
+		// import path, but the same package may still be walked more than once.
 		if _, ok := c.Packages[c.CurrentPkgImportPath]; ok {
 			return c
 		}
@@ -262,17 +384,23 @@ func (c *Cache) Visit(node ast.Node) ast.Visitor {
 	case *ast.File:
 		pkg, ok := c.Packages.Get(c.CurrentPkgImportPath)
 		if !ok {
-			panic(fmt.Errorf("package import path not found: %q", c.CurrentPkgImportPath))
+			// files can be visited without their package, in which case the
+			// package is created here.
+			pkg = &Package{
+				Name: GetPackageNameFromImportPath(c.CurrentPkgImportPath),
+			}
+			c.Packages[c.CurrentPkgImportPath] = pkg
 		}
 
-		pkg.Files = append(pkg.Files, NewFile(t))
+		f := NewFile(t)
+		f.Filename = c.filenames[t]
+		pkg.Files = append(pkg.Files, f)
 	case *ast.GenDecl:
-		pkg, ok := c.Packages.Get(c.CurrentPkgImportPath)
+		_, f, ok := c.currentCacheFile()
 		if !ok {
-			panic(fmt.Errorf("package import path not found: %q", c.CurrentPkgImportPath))
+			return nil
 		}
 
-		f := pkg.Files[len(pkg.Files)-1]
 		pkgName := GetPackageNameFromImportPath(c.CurrentPkgImportPath)
 
 		for _, spec := range t.Specs {
@@ -283,9 +411,52 @@ func (c *Cache) Visit(node ast.Node) ast.Visitor {
 					spec,
 					pkgName,
 				}
+			case *ast.ImportSpec:
+				c.addImport(spec)
 			}
 		}
+
+		// all specs have been cached, so there is no need to walk them
+		return nil
 	}
 
 	return c
 }
+
+// currentCacheFile will return the file that is currently being cached, which
+// is the most recently added file of the current package. False is returned if
+// no file of the current package has been visited.
+func (c *Cache) currentCacheFile() (*Package, *File, bool) {
+	pkg, ok := c.CurrentPackage()
+	if !ok || len(pkg.Files) == 0 {
+		if DefaultLogger.Enabled(DebugLevel) {
+			DefaultLogger.Log(DebugLevel, "no file is being cached", Field{Key: "package", Value: c.CurrentPkgImportPath})
+		}
+		return nil, nil, false
+	}
+
+	return pkg, pkg.Files[len(pkg.Files)-1], true
+}
+
+func (c *Cache) addImport(spec *ast.ImportSpec) {
+	_, f, ok := c.currentCacheFile()
+	if !ok {
+		return
+	}
+
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		importPath = spec.Path.Value
+	}
+
+	if spec.Name != nil {
+		name, err := strconv.Unquote(spec.Name.Name)
+		if err != nil {
+			name = spec.Name.Name
+		}
+
+		f.Imports[name] = importPath
+	} else {
+		f.Imports[GetPackageNameFromImportPath(importPath)] = importPath
+	}
+}
@@ -124,6 +124,41 @@ func TestCacheCurrentFile(t *testing.T) {
 	}
 }
 
+func TestCacheSnapshot(t *testing.T) {
+	fileAST := &ast.File{}
+	cache := &Cache{
+		Packages: Packages{
+			"foo": &Package{
+				Files: Files{
+					{
+						ASTFile: fileAST,
+					},
+				},
+			},
+		},
+		CurrentPkgImportPath: "foo",
+		CurrentASTFile:       fileAST,
+	}
+
+	snapshot := cache.Snapshot()
+
+	if _, ok := snapshot.CurrentFile(); ok {
+		t.Errorf("expected snapshot to not have a current file")
+	}
+
+	snapshot.CurrentPkgImportPath = "foo"
+	snapshot.CurrentASTFile = fileAST
+	cache.CurrentPkgImportPath = "bar"
+
+	if _, ok := snapshot.CurrentFile(); !ok {
+		t.Errorf("expected snapshot to be unaffected by the original cache")
+	}
+
+	if e, a := cache.Packages["foo"], snapshot.Packages["foo"]; e != a {
+		t.Errorf("expected packages to be shared")
+	}
+}
+
 func TestCachePackagesGet(t *testing.T) {
 	cases := []struct {
 		name            string
@@ -297,6 +332,87 @@ func TestCacheGetOpInfo(t *testing.T) {
 				PkgName: "baz",
 			},
 		},
+		{
+			name: "method by name",
+			packages: Packages{
+				"bar": &Package{
+					Files: Files{
+						{
+							OpInfos: OpInfos{
+								"Foo.Close": {
+									IsMethod: true,
+									Receiver: "Foo",
+								},
+							},
+						},
+						{
+							OpInfos: OpInfos{
+								"Bar.Close": {
+									IsMethod: true,
+									Receiver: "Bar",
+								},
+							},
+						},
+					},
+				},
+			},
+			opName:        "Close",
+			pkgImportPath: "bar",
+			expectedOk:    true,
+			expectedOpInfo: OpInfo{
+				IsMethod: true,
+				Receiver: "Bar",
+			},
+		},
+		{
+			name: "function before method",
+			packages: Packages{
+				"bar": &Package{
+					Files: Files{
+						{
+							OpInfos: OpInfos{
+								"Foo.Close": {
+									IsMethod: true,
+									Receiver: "Foo",
+								},
+							},
+						},
+						{
+							OpInfos: OpInfos{
+								"Close": {
+									PkgName: "bar",
+								},
+							},
+						},
+					},
+				},
+			},
+			opName:        "Close",
+			pkgImportPath: "bar",
+			expectedOk:    true,
+			expectedOpInfo: OpInfo{
+				PkgName: "bar",
+			},
+		},
+		{
+			name: "method of another type",
+			packages: Packages{
+				"bar": &Package{
+					Files: Files{
+						{
+							OpInfos: OpInfos{
+								"Foo.Close": {
+									IsMethod: true,
+									Receiver: "Foo",
+								},
+							},
+						},
+					},
+				},
+			},
+			opName:        "Bar.Close",
+			pkgImportPath: "bar",
+		},
 	}
 
 	for _, c := range cases {
@@ -382,6 +498,104 @@ func TestCacheVisitorImports(t *testing.T) {
 	}
 }
 
+func TestPackageMethod(t *testing.T) {
+	sources := map[string]string{
+		"a.go": "package foo\ntype A struct{}\nfunc (a *A) Close() {}\nfunc Close() {}",
+		"b.go": "package foo\ntype B struct{}\nfunc (b B) Close() {}",
+
+		// receivers declared in another file are not resolved, but are still
+		// indexed by their type name
+		"c.go": "package foo\nfunc (a A) Open() {}",
+	}
+
+	fset := token.NewFileSet()
+	pkg := &ast.Package{
+		Name:  "foo",
+		Files: map[string]*ast.File{},
+	}
+
+	for filename, src := range sources {
+		f, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		pkg.Files[filename] = f
+	}
+
+	cache := NewCache()
+	WalkPackage(cache, pkg)
+
+	p, ok := cache.CurrentPackage()
+	if !ok {
+		t.Fatalf("expected package to be cached")
+	}
+
+	cases := []struct {
+		typeName     string
+		methodName   string
+		expectedOk   bool
+		expectedFile string
+	}{
+		{typeName: "A", methodName: "Close", expectedOk: true, expectedFile: "a.go"},
+		{typeName: "B", methodName: "Close", expectedOk: true, expectedFile: "b.go"},
+		{typeName: "A", methodName: "Open", expectedOk: true, expectedFile: "c.go"},
+		{typeName: "B", methodName: "Open"},
+		{typeName: "", methodName: "Close"},
+	}
+
+	for _, c := range cases {
+		info, ok := p.Method(c.typeName, c.methodName)
+		if e, a := c.expectedOk, ok; e != a {
+			t.Fatalf("%s.%s: expected %t, but received %t", c.typeName, c.methodName, e, a)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if e, a := c.typeName, info.Receiver; e != a {
+			t.Errorf("%s.%s: expected receiver %q, but received %q", c.typeName, c.methodName, e, a)
+		}
+
+		if e, a := c.expectedFile, fset.Position(info.Decl.Pos()).Filename; e != a {
+			t.Errorf("%s.%s: expected %q, but received %q", c.typeName, c.methodName, e, a)
+		}
+	}
+
+	info, ok := p.Func("Close")
+	if !ok || info.IsMethod {
+		t.Errorf("expected the Close function, but received %v", info)
+	}
+
+	if _, ok := p.Func("Open"); ok {
+		t.Errorf("expected methods to not be returned as functions")
+	}
+}
+
+func TestReceiverTypeName(t *testing.T) {
+	cases := map[string]string{
+		"func (f Foo) M() {}":       "Foo",
+		"func (f *Foo) M() {}":      "Foo",
+		"func (f (*Foo)) M() {}":    "Foo",
+		"func (f *Foo[T]) M() {}":   "Foo",
+		"func (f Foo[T, U]) M() {}": "Foo",
+	}
+
+	for src, expected := range cases {
+		f, err := parser.ParseFile(token.NewFileSet(), "foo.go", "package foo\n"+src, 0)
+		if err != nil {
+			// type parameters can only be parsed by go1.18 and later
+			continue
+		}
+
+		decl := f.Decls[0].(*ast.FuncDecl)
+		if e, a := expected, receiverTypeName(decl.Recv.List[0].Type); e != a {
+			t.Errorf("%q: expected %q, but received %q", src, e, a)
+		}
+	}
+}
+
 func TestOpInfoMethod(t *testing.T) {
 	spec := &ast.TypeSpec{}
 
@@ -432,3 +646,76 @@ func TestOpInfoMethod(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheExternalTestPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	sources := map[string]string{
+		"foo/foo.go":          "package foo\ntype Foo struct{}",
+		"foo/foo_test.go":     "package foo\ntype FooTest struct{}",
+		"foo/foo_ext_test.go": "package foo_test\ntype Foo struct{}",
+	}
+
+	pkgs := map[string]*ast.Package{}
+	for filename, src := range sources {
+		f, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		pkg, ok := pkgs[f.Name.Name]
+		if !ok {
+			pkg = &ast.Package{
+				Name:  f.Name.Name,
+				Files: map[string]*ast.File{},
+			}
+			pkgs[f.Name.Name] = pkg
+		}
+		pkg.Files[filename] = f
+	}
+
+	cache := NewCache()
+	WalkPackage(cache, pkgs["foo"])
+	WalkPackage(cache, pkgs["foo_test"])
+
+	cases := []struct {
+		importPath    string
+		expectedName  string
+		expectedFiles map[string]bool
+	}{
+		{
+			importPath:   "foo",
+			expectedName: "foo",
+			expectedFiles: map[string]bool{
+				"foo/foo.go":      false,
+				"foo/foo_test.go": true,
+			},
+		},
+		{
+			importPath:   "foo_test",
+			expectedName: "foo_test",
+			expectedFiles: map[string]bool{
+				"foo/foo_ext_test.go": true,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		pkg, ok := cache.Packages.Get(c.importPath)
+		if !ok {
+			t.Fatalf("expected %q to be cached", c.importPath)
+		}
+
+		if e, a := c.expectedName, pkg.Name; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+
+		files := map[string]bool{}
+		for _, f := range pkg.Files {
+			files[f.Filename] = f.IsTest()
+		}
+
+		if e, a := c.expectedFiles, files; !reflect.DeepEqual(e, a) {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+const cacheUsage = `usage: pepperlint cache [-config-path path] clean|stats
+
+clean removes every entry of the disk cache.
+stats prints the number of entries of the disk cache and their size.
+`
+
+// cacheCommand will run the cache sub command with the arguments that follow
+// it.
+func cacheCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		fmt.Fprint(w, cacheUsage)
+	}
+
+	configPath := fs.String("config-path", "", "path to yaml config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a single cache command")
+	}
+
+	config, err := NewConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	dir := config.CacheDir
+	if len(dir) == 0 {
+		if dir, err = pepperlint.DefaultDiskCacheDir(); err != nil {
+			return err
+		}
+	}
+
+	diskCache := pepperlint.NewDiskCache(dir)
+	switch fs.Arg(0) {
+	case "clean":
+		return diskCache.Clean()
+	case "stats":
+		stats, err := diskCache.Stats()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "directory: %s\n", dir)
+		fmt.Fprintf(w, "packages:  %d\n", stats.Packages)
+		fmt.Fprintf(w, "results:   %d\n", stats.Results)
+		fmt.Fprintf(w, "size:      %d bytes\n", stats.Size)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown cache command %q", fs.Arg(0))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCacheCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cacheDir := filepath.Join(dir, "cache")
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(configPath, []byte("cache_dir: "+cacheDir+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := filepath.Join(cacheDir, "results", "ab", "abcd")
+	if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(entry, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := cacheCommand([]string{"-config-path", configPath, "stats"}, &buf); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	for _, s := range []string{cacheDir, "packages:  0", "results:   1", "size:      2 bytes"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in %q", s, buf.String())
+		}
+	}
+
+	if err := cacheCommand([]string{"-config-path", configPath, "clean"}, &buf); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if _, err := os.Stat(entry); !os.IsNotExist(err) {
+		t.Errorf("expected entry to be removed, but received %v", err)
+	}
+
+	if err := cacheCommand([]string{"-config-path", configPath, "unknown"}, &buf); err == nil {
+		t.Errorf("expected error for unknown command")
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 
 	"github.com/go-toolset/pepperlint"
+	"github.com/go-toolset/pepperlint/plugin"
 	"github.com/go-toolset/pepperlint/rules"
+	"github.com/go-toolset/pepperlint/rules/core/banned"
+	"github.com/go-toolset/pepperlint/rules/core/pattern"
 
 	"github.com/go-yaml/yaml"
 )
@@ -15,7 +19,54 @@ type Config struct {
 	Rules        Rules        `yaml:"rules"`
 	Suppressions Suppressions `yaml:"suppressions"`
 
+	// Plugins are rules implemented by external executables. Each plugin is
+	// registered under its name, and is run like any other rule once it is
+	// listed in Rules.
+	Plugins Plugins `yaml:"plugins"`
+
+	// Banned are rules that report uses of the selectors and imports they
+	// list. Like plugins, each is registered under its name.
+	Banned BannedRules `yaml:"banned"`
+
+	// Patterns are rules that report code matching a pattern. Like plugins,
+	// each is registered under its name.
+	Patterns PatternRules `yaml:"patterns"`
+
 	IncludePkgs []string
+
+	// Jobs is the number of packages that will be linted in parallel.
+	Jobs int `yaml:"jobs"`
+
+	// BuildTags, GOOS and GOARCH determine which files are linted based on
+	// their build constraints.
+	BuildTags []string `yaml:"tags"`
+	GOOS      string   `yaml:"goos"`
+	GOARCH    string   `yaml:"goarch"`
+
+	// Tests determines whether _test.go files are linted, which they are if
+	// this is not set.
+	Tests *bool `yaml:"tests"`
+
+	// Generated will lint files that are marked as generated, which are
+	// skipped otherwise.
+	Generated bool `yaml:"generated"`
+
+	// Cache determines whether the declarations of included packages are
+	// cached on disk across runs, which they are if this is not set.
+	Cache *bool `yaml:"cache"`
+
+	// CacheDir is the directory of the disk cache. Nothing is cached on disk
+	// if this is empty.
+	CacheDir string `yaml:"cache_dir"`
+
+	// Stats will collect where the time of a run was spent. It is only set by
+	// flags.
+	Stats bool `yaml:"-"`
+
+	// Logger is given to every rule, and Relint lints every package instead
+	// of reusing their cached diagnostics. They are only set by flags.
+	Logger pepperlint.Logger `yaml:"-"`
+	Relint bool              `yaml:"-"`
 }
 
 // NewConfig returns a new config at a given path.
@@ -50,6 +101,23 @@ func (cfg Config) Options() []pepperlint.Option {
 	return opts
 }
 
+// CopyRulers will return the copy rulers of every rule in the config. An error
+// is returned if a rule has not been registered.
+func (cfg Config) CopyRulers() ([]pepperlint.CopyRuler, error) {
+	copyRulers := []pepperlint.CopyRuler{}
+
+	for _, rule := range cfg.Rules {
+		r, ok := rules.Lookup(rule.RuleName)
+		if !ok {
+			return nil, fmt.Errorf("unknown rule %q", rule.RuleName)
+		}
+
+		copyRulers = append(copyRulers, r)
+	}
+
+	return copyRulers, nil
+}
+
 // Rules represents a list of rules
 type Rules []Rule
 
@@ -59,6 +127,126 @@ type Rule struct {
 	RuleName string `yaml:"rule_name"`
 }
 
+// Plugins represents a list of plugins
+type Plugins []Plugin
+
+// Plugin is a shape definition of what a plugin object will look like in the
+// yaml configuration.
+type Plugin struct {
+	Name    string            `yaml:"name"`
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Config  map[string]string `yaml:"config"`
+}
+
+// Register will add every plugin to the rules registry. An error is returned if
+// a plugin is missing its name or command, or if its name is already taken by a
+// rule that is not a plugin.
+func (ps Plugins) Register() error {
+	for _, p := range ps {
+		if len(p.Name) == 0 || len(p.Command) == 0 {
+			return fmt.Errorf("plugins must have a name and command")
+		}
+
+		if r, ok := rules.Lookup(p.Name); ok {
+			if _, ok := r.(*plugin.Plugin); !ok {
+				return fmt.Errorf("plugin %q has the name of a rule", p.Name)
+			}
+		}
+
+		rules.Add(p.Name, plugin.New(p.Name, p.Command, p.Args, p.Config))
+	}
+
+	return nil
+}
+
+// Close will stop every registered plugin that has been started.
+func (ps Plugins) Close() {
+	for _, p := range ps {
+		if r, ok := rules.Lookup(p.Name); ok {
+			if r, ok := r.(*plugin.Plugin); ok {
+				r.Close()
+			}
+		}
+	}
+}
+
+// BannedRules represents a list of banned rules
+type BannedRules []BannedRule
+
+// BannedRule is a shape definition of what a banned rule object will look like
+// in the yaml configuration.
+type BannedRule struct {
+	Name      string            `yaml:"name"`
+	Selectors []banned.Selector `yaml:"selectors"`
+	Imports   []banned.Import   `yaml:"imports"`
+}
+
+// Register will add every banned rule to the rules registry. An error is
+// returned if a rule is not valid, or if its name is already taken by a rule
+// that is not a banned rule.
+func (bs BannedRules) Register() error {
+	for _, b := range bs {
+		if len(b.Name) == 0 {
+			return fmt.Errorf("banned rules must have a name")
+		}
+
+		if r, ok := rules.Lookup(b.Name); ok {
+			if _, ok := r.(*banned.Rule); !ok {
+				return fmt.Errorf("banned rule %q has the name of another rule", b.Name)
+			}
+		}
+
+		r, err := banned.New(b.Name, b.Selectors, b.Imports)
+		if err != nil {
+			return fmt.Errorf("banned rule %q: %v", b.Name, err)
+		}
+
+		rules.Add(b.Name, r)
+	}
+
+	return nil
+}
+
+// PatternRules represents a list of pattern rules
+type PatternRules []PatternRule
+
+// PatternRule is a shape definition of what a pattern rule object will look
+// like in the yaml configuration.
+type PatternRule struct {
+	Name    string                        `yaml:"name"`
+	Pattern string                        `yaml:"pattern"`
+	Where   map[string]pattern.Constraint `yaml:"where"`
+	Message string                        `yaml:"message"`
+	Rewrite string                        `yaml:"rewrite"`
+}
+
+// Register will add every pattern rule to the rules registry. An error is
+// returned if a pattern is not valid, or if its name is already taken by a rule
+// that is not a pattern rule.
+func (ps PatternRules) Register() error {
+	for _, p := range ps {
+		if len(p.Name) == 0 {
+			return fmt.Errorf("pattern rules must have a name")
+		}
+
+		if r, ok := rules.Lookup(p.Name); ok {
+			if _, ok := r.(*pattern.Rule); !ok {
+				return fmt.Errorf("pattern rule %q has the name of another rule", p.Name)
+			}
+		}
+
+		r, err := pattern.New(p.Name, p.Pattern, p.Message, p.Rewrite, p.Where)
+		if err != nil {
+			return fmt.Errorf("pattern rule %q: %v", p.Name, err)
+		}
+
+		rules.Add(p.Name, r)
+	}
+
+	return nil
+}
+
 // Suppressions represents a list of suppressions
 type Suppressions []Suppression
 
@@ -68,6 +256,29 @@ type Suppression struct {
 	File *File `yaml:"file"`
 }
 
+// Options will return the suppressions as pepperlint suppressions.
+func (s Suppressions) Options() []pepperlint.Suppression {
+	suppressions := []pepperlint.Suppression{}
+
+	for _, sup := range s {
+		if sup.File == nil {
+			continue
+		}
+
+		suppression := pepperlint.Suppression{
+			Filename: sup.File.FilePath,
+		}
+
+		if sup.File.LineNumber != nil {
+			suppression.Line = *sup.File.LineNumber
+		}
+
+		suppressions = append(suppressions, suppression)
+	}
+
+	return suppressions
+}
+
 // File represents a File object that will be used for file based suppressions
 type File struct {
 	FilePath string `yaml:"file_path"`
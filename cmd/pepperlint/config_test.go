@@ -5,7 +5,12 @@ import (
 	"testing"
 
 	"github.com/go-toolset/pepperlint"
+	"github.com/go-toolset/pepperlint/plugin"
 	"github.com/go-toolset/pepperlint/rules"
+	"github.com/go-toolset/pepperlint/rules/core/banned"
+	"github.com/go-toolset/pepperlint/rules/core/pattern"
+
+	"github.com/go-yaml/yaml"
 )
 
 type mockRule struct{}
@@ -44,3 +49,221 @@ func TestConfigRules(t *testing.T) {
 func init() {
 	rules.Add("mock", mockRule{})
 }
+
+func TestConfigSuppressions(t *testing.T) {
+	line := 5
+	suppressions := Suppressions{
+		{},
+		{
+			File: &File{
+				FilePath: "foo.go",
+			},
+		},
+		{
+			File: &File{
+				FilePath:   "bar.go",
+				LineNumber: &line,
+			},
+		},
+	}
+
+	expected := []pepperlint.Suppression{
+		{
+			Filename: "foo.go",
+		},
+		{
+			Filename: "bar.go",
+			Line:     5,
+		},
+	}
+
+	if e, a := expected, suppressions.Options(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestConfigCopyRulers(t *testing.T) {
+	cfg := Config{
+		Rules: Rules{
+			{
+				RuleName: "mock",
+			},
+		},
+	}
+
+	copyRulers, err := cfg.CopyRulers()
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := []pepperlint.CopyRuler{mockRule{}}, copyRulers; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	cfg.Rules = append(cfg.Rules, Rule{RuleName: "unknown"})
+	if _, err := cfg.CopyRulers(); err == nil {
+		t.Errorf("expected error for unknown rule")
+	}
+}
+
+func TestConfigPlugins(t *testing.T) {
+	cfg := Config{}
+	src := `
+plugins:
+  - name: private/nohttp
+    command: nohttp
+    args: ["-strict"]
+    config:
+      allow: net/http/httptest
+rules:
+  - rule_name: private/nohttp
+`
+	if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Plugins.Register(); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	copyRulers, err := cfg.CopyRulers()
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	expected := plugin.New("private/nohttp", "nohttp", []string{"-strict"}, map[string]string{
+		"allow": "net/http/httptest",
+	})
+	if e, a := []pepperlint.CopyRuler{expected}, copyRulers; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	cases := []Plugins{
+		{{Name: "mock", Command: "mock"}},
+		{{Name: "private/nocommand"}},
+	}
+
+	for _, c := range cases {
+		if err := c.Register(); err == nil {
+			t.Errorf("expected error for %v", c)
+		}
+	}
+}
+
+func TestConfigBanned(t *testing.T) {
+	cfg := Config{}
+	src := `
+banned:
+  - name: team/banned
+    selectors:
+      - selector: net/http.Get
+        message: use the shared client
+        allow: ["example.com/foo/internal/..."]
+    imports:
+      - path: unsafe
+        replacement: example.com/foo/safe
+        packages: ["example.com/foo/..."]
+rules:
+  - rule_name: team/banned
+`
+	if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := BannedRules{
+		{
+			Name: "team/banned",
+			Selectors: []banned.Selector{
+				{
+					Selector: "net/http.Get",
+					Message:  "use the shared client",
+					Scope:    banned.Scope{Allow: []string{"example.com/foo/internal/..."}},
+				},
+			},
+			Imports: []banned.Import{
+				{
+					Path:        "unsafe",
+					Replacement: "example.com/foo/safe",
+					Scope:       banned.Scope{Packages: []string{"example.com/foo/..."}},
+				},
+			},
+		},
+	}
+	if e, a := expected, cfg.Banned; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	if err := cfg.Banned.Register(); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if _, err := cfg.CopyRulers(); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	cases := []BannedRules{
+		{{Name: "mock"}},
+		{{}},
+		{{Name: "team/invalid", Selectors: []banned.Selector{{Selector: "http"}}}},
+	}
+
+	for _, c := range cases {
+		if err := c.Register(); err == nil {
+			t.Errorf("expected error for %v", c)
+		}
+	}
+}
+
+func TestConfigPatterns(t *testing.T) {
+	cfg := Config{}
+	src := `
+patterns:
+  - name: team/sprintf
+    pattern: fmt.Sprintf("%s", $s)
+    where:
+      s:
+        kind: Ident
+    message: use $s.String() instead
+    rewrite: $s.String()
+rules:
+  - rule_name: team/sprintf
+`
+	if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := PatternRules{
+		{
+			Name:    "team/sprintf",
+			Pattern: `fmt.Sprintf("%s", $s)`,
+			Where: map[string]pattern.Constraint{
+				"s": {Kind: "Ident"},
+			},
+			Message: "use $s.String() instead",
+			Rewrite: "$s.String()",
+		},
+	}
+	if e, a := expected, cfg.Patterns; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	if err := cfg.Patterns.Register(); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if _, err := cfg.CopyRulers(); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	cases := []PatternRules{
+		{{Name: "mock", Pattern: "$x"}},
+		{{Pattern: "$x"}},
+		{{Name: "team/invalid", Pattern: "$x +"}},
+	}
+
+	for _, c := range cases {
+		if err := c.Register(); err == nil {
+			t.Errorf("expected error for %v", c)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+const dumpUsage = `usage: pepperlint dump -cache [-pkg path] [-json] [-config-path path] [-include-pkgs pkgs] [patterns]
+       pepperlint dump -ast file.go:line[:column] [-json]
+
+-cache prints what is cached for the packages matching patterns, which
+default to the current directory, and for any included package: the imports,
+types, functions and methods of every file, and which receiver types methods
+were resolved to.
+
+-ast prints the path of nodes that enclose a position, from the file down,
+along with the validate method each node is passed to.
+`
+
+// dumpCommand will run the dump sub command with the arguments that follow it.
+func dumpCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		fmt.Fprint(w, dumpUsage)
+	}
+
+	dumpCache := fs.Bool("cache", false, "print the cache of the packages matching patterns")
+	pos := fs.String("ast", "", "print the nodes enclosing file.go:line[:column]")
+	pkg := fs.String("pkg", "", "only print packages with this import path, or under it if it ends with /...")
+	asJSON := fs.Bool("json", false, "print as JSON")
+	configPath := fs.String("config-path", "", "path to yaml config")
+	includePkgs := fs.String("include-pkgs", "", "comma separated list of directories to be included")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dumpCache == (len(*pos) > 0) {
+		fs.Usage()
+		return fmt.Errorf("expected either -cache or -ast")
+	}
+
+	if len(*pos) > 0 {
+		path, err := astPath(*pos)
+		if err != nil {
+			return err
+		}
+
+		if *asJSON {
+			return writeJSON(w, path)
+		}
+
+		path.print(w)
+		return nil
+	}
+
+	config, err := NewConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if len(*includePkgs) > 0 {
+		config.IncludePkgs = strings.Split(*includePkgs, ",")
+	}
+	config = withCacheDir(config)
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	ctx := context.Background()
+	l, err := pepperlint.NewLinter(ctx, loadOptions(config, config.IncludePkgs))
+	if err != nil {
+		return err
+	}
+
+	cache, fset, err := l.Cache(ctx, patterns, nil)
+	if err != nil {
+		return err
+	}
+
+	dump := newCacheDump(fset, cache, *pkg)
+	if *asJSON {
+		return writeJSON(w, dump)
+	}
+
+	dump.print(w)
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// cacheDump is what a cache contains, without any of the syntax trees.
+type cacheDump struct {
+	Packages []packageDump `json:"packages"`
+}
+
+type packageDump struct {
+	ImportPath string     `json:"import_path"`
+	Name       string     `json:"name"`
+	Files      []fileDump `json:"files"`
+}
+
+type fileDump struct {
+	Filename string            `json:"filename"`
+	Test     bool              `json:"test"`
+	Imports  map[string]string `json:"imports"`
+	Types    []typeDump        `json:"types"`
+	Ops      []opDump          `json:"ops"`
+}
+
+type typeDump struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Pos  string `json:"pos"`
+	Doc  string `json:"doc,omitempty"`
+}
+
+type opDump struct {
+	Name   string `json:"name"`
+	Pos    string `json:"pos"`
+	Method bool   `json:"method"`
+
+	// Key is what the op is looked up by, which is Type.Method for methods.
+	Key string `json:"key"`
+
+	// Receiver is the receiver type as it is written, and Resolved are the
+	// names of the type specs it was resolved to, which is empty if it could
+	// not be.
+	Receiver string   `json:"receiver,omitempty"`
+	Resolved []string `json:"resolved,omitempty"`
+}
+
+// newCacheDump will return the packages of cache, sorted by import path. If pkg
+// is set, only the matching packages are returned.
+func newCacheDump(fset *token.FileSet, cache *pepperlint.Cache, pkg string) cacheDump {
+	importPaths := []string{}
+	for importPath := range cache.Packages {
+		if matchImportPath(pkg, importPath) {
+			importPaths = append(importPaths, importPath)
+		}
+	}
+	sort.Strings(importPaths)
+
+	dump := cacheDump{
+		Packages: []packageDump{},
+	}
+
+	for _, importPath := range importPaths {
+		p := cache.Packages[importPath]
+		pd := packageDump{
+			ImportPath: importPath,
+			Name:       p.Name,
+			Files:      []fileDump{},
+		}
+
+		for _, f := range p.Files {
+			pd.Files = append(pd.Files, newFileDump(fset, f))
+		}
+
+		sort.Slice(pd.Files, func(i, j int) bool {
+			return pd.Files[i].Filename < pd.Files[j].Filename
+		})
+
+		dump.Packages = append(dump.Packages, pd)
+	}
+
+	return dump
+}
+
+// matchImportPath will return true if pattern is empty, is importPath, or ends
+// with /... and importPath is within it.
+func matchImportPath(pattern, importPath string) bool {
+	if len(pattern) == 0 || pattern == importPath {
+		return true
+	}
+
+	if !strings.HasSuffix(pattern, "/...") {
+		return false
+	}
+
+	prefix := strings.TrimSuffix(pattern, "/...")
+	return importPath == prefix || strings.HasPrefix(importPath, prefix+"/")
+}
+
+func newFileDump(fset *token.FileSet, f *pepperlint.File) fileDump {
+	fd := fileDump{
+		Filename: f.Filename,
+		Test:     f.IsTest(),
+		Imports:  f.Imports,
+		Types:    []typeDump{},
+		Ops:      []opDump{},
+	}
+
+	for name, info := range f.TypeInfos {
+		td := typeDump{
+			Name: name,
+		}
+
+		if info.Spec != nil {
+			td.Type = types.ExprString(info.Spec.Type)
+			td.Pos = fset.Position(info.Spec.Pos()).String()
+		}
+
+		if info.Doc != nil {
+			td.Doc = strings.TrimSpace(info.Doc.Text())
+		}
+
+		fd.Types = append(fd.Types, td)
+	}
+
+	for key, info := range f.OpInfos {
+		od := opDump{
+			Name:   key,
+			Method: info.IsMethod,
+			Key:    key,
+		}
+
+		if info.Decl != nil {
+			od.Name = info.Decl.Name.Name
+			od.Pos = fset.Position(info.Decl.Pos()).String()
+
+			if recv := info.Decl.Recv; recv != nil && len(recv.List) > 0 {
+				od.Receiver = types.ExprString(recv.List[0].Type)
+			}
+		}
+
+		for _, spec := range info.TypeSpecs {
+			od.Resolved = append(od.Resolved, spec.Name.Name)
+		}
+
+		fd.Ops = append(fd.Ops, od)
+	}
+
+	sort.Slice(fd.Types, func(i, j int) bool {
+		return fd.Types[i].Name < fd.Types[j].Name
+	})
+
+	sort.Slice(fd.Ops, func(i, j int) bool {
+		if fd.Ops[i].Method != fd.Ops[j].Method {
+			return !fd.Ops[i].Method
+		}
+
+		return fd.Ops[i].Key < fd.Ops[j].Key
+	})
+
+	return fd
+}
+
+func (d cacheDump) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+
+	for _, pkg := range d.Packages {
+		fmt.Fprintf(tw, "package %s (%s)\n", pkg.ImportPath, pkg.Name)
+
+		for _, f := range pkg.Files {
+			test := ""
+			if f.Test {
+				test = " (test)"
+			}
+			fmt.Fprintf(tw, "  file %s%s\n", f.Filename, test)
+
+			names := []string{}
+			for name := range f.Imports {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				fmt.Fprintf(tw, "    import\t%s\t%s\n", name, strconv.Quote(f.Imports[name]))
+			}
+
+			for _, t := range f.Types {
+				fmt.Fprintf(tw, "    type\t%s\t%s\t%s\n", t.Name, t.Type, t.Pos)
+			}
+
+			for _, op := range f.Ops {
+				if !op.Method {
+					fmt.Fprintf(tw, "    func\t%s\t\t%s\n", op.Name, op.Pos)
+					continue
+				}
+
+				resolved := "unresolved"
+				if len(op.Resolved) > 0 {
+					resolved = strings.Join(op.Resolved, ", ")
+				}
+
+				fmt.Fprintf(tw, "    method\t(%s) %s\t%s\t%s\n", op.Receiver, op.Name, resolved, op.Pos)
+			}
+		}
+	}
+}
+
+// nodePath is the path of nodes that enclose a position, from the file down.
+type nodePath struct {
+	Pos   string     `json:"pos"`
+	Nodes []nodeDump `json:"nodes"`
+}
+
+type nodeDump struct {
+	Type string `json:"type"`
+	Pos  string `json:"pos"`
+	End  string `json:"end"`
+
+	// Validate is the method of rules the node is passed to, which is empty
+	// if rules are not passed the node.
+	Validate string `json:"validate,omitempty"`
+
+	// Text is the first line of the node's source.
+	Text string `json:"text"`
+}
+
+// astPath will return the path of nodes that enclose the position, which is
+// given as file.go:line[:column]. If the column is left out, the first column
+// of the line that is not white space is used.
+func astPath(position string) (nodePath, error) {
+	filename, line, column, err := parsePosition(position)
+	if err != nil {
+		return nodePath{}, err
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nodePath{}, err
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nodePath{}, err
+	}
+
+	tf := fset.File(f.Pos())
+	if line < 1 || line > tf.LineCount() {
+		return nodePath{}, fmt.Errorf("%s only has %d lines", filename, tf.LineCount())
+	}
+
+	offset := tf.Offset(tf.LineStart(line))
+	if column > 0 {
+		offset += column - 1
+	} else {
+		for offset < len(src) && (src[offset] == ' ' || src[offset] == '\t') {
+			offset++
+		}
+	}
+
+	if offset > len(src) {
+		return nodePath{}, fmt.Errorf("%s is past the end of %s", position, filename)
+	}
+
+	pos := tf.Pos(offset)
+	path := nodePath{
+		Pos:   fset.Position(pos).String(),
+		Nodes: []nodeDump{},
+	}
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		if node == nil || pos < node.Pos() || pos >= node.End() {
+			return false
+		}
+
+		// comments are not part of the syntax tree that rules are passed
+		switch node.(type) {
+		case *ast.CommentGroup, *ast.Comment:
+			return false
+		}
+
+		text := string(src[tf.Offset(node.Pos()):tf.Offset(node.End())])
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i] + " ..."
+		}
+
+		path.Nodes = append(path.Nodes, nodeDump{
+			Type:     fmt.Sprintf("%T", node),
+			Pos:      fset.Position(node.Pos()).String(),
+			End:      fset.Position(node.End()).String(),
+			Validate: validateMethod(node),
+			Text:     text,
+		})
+
+		return true
+	})
+
+	return path, nil
+}
+
+// parsePosition will split file.go:line[:column] into its parts. The column is
+// 0 if it is left out.
+func parsePosition(position string) (string, int, int, error) {
+	parts := strings.Split(position, ":")
+
+	numbers := []int{}
+	for len(parts) > 1 && len(numbers) < 2 {
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			break
+		}
+
+		numbers = append([]int{n}, numbers...)
+		parts = parts[:len(parts)-1]
+	}
+
+	if len(numbers) == 0 {
+		return "", 0, 0, fmt.Errorf("invalid position %q, expected file.go:line[:column]", position)
+	}
+
+	filename := strings.Join(parts, ":")
+	if len(numbers) == 1 {
+		return filename, numbers[0], 0, nil
+	}
+
+	return filename, numbers[0], numbers[1], nil
+}
+
+// validateMethod will return the validate method that node is passed to, or
+// an empty string if rules are not passed nodes of its type.
+func validateMethod(node ast.Node) string {
+	name := reflect.TypeOf(node).Elem().Name()
+	if _, ok := nodeTypeName(name); !ok {
+		return ""
+	}
+
+	return "Validate" + name
+}
+
+func (p nodePath) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+
+	for i, node := range p.Nodes {
+		fmt.Fprintf(tw, "%s%s\t%s\t%s\t%s\n",
+			strings.Repeat("  ", i),
+			node.Type,
+			node.Pos,
+			node.Validate,
+			node.Text,
+		)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDumpCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(configPath, []byte("cache_dir: "+filepath.Join(dir, "cache")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// receivers are only resolved within the file their type is declared in
+	files := map[string]string{
+		"foo.go": "package foo\n\nimport f \"fmt\"\n\n// Foo is foo\ntype Foo struct{}\n\nfunc (x *Foo) Print() { f.Println() }\n\nfunc New() *Foo { return nil }\n",
+		"bar.go": "package foo\n\nfunc (x Foo) Bar() {}\n",
+	}
+
+	pkgDir := filepath.Join(dir, "foo")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(pkgDir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if err := dumpCommand([]string{"-cache", "-config-path", configPath, pkgDir}, &buf); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	// columns are padded, so they are compared by their fields
+	out := strings.Join(strings.Fields(buf.String()), " ")
+	for _, s := range []string{
+		"file " + filepath.Join(pkgDir, "bar.go"),
+		"method (Foo) Bar unresolved",
+		"import f \"fmt\"",
+		"type Foo struct{}",
+		"func New",
+		"method (*Foo) Print Foo",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in\n%s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := dumpCommand([]string{"-cache", "-json", "-config-path", configPath, pkgDir}, &buf); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	dump := cacheDump{}
+	if err := json.Unmarshal(buf.Bytes(), &dump); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := 1, len(dump.Packages); e != a {
+		t.Fatalf("expected %d packages, but received %d", e, a)
+	}
+
+	foo := dump.Packages[0].Files[1]
+	if e, a := "Foo is foo", foo.Types[0].Doc; e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	if e, a := []string{"Foo"}, foo.Ops[1].Resolved; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	if e, a := "Foo.Print", foo.Ops[1].Key; e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+}
+
+func TestDumpAST(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := dumpCommand([]string{"-ast", filepath.Join("testdata", "core", "core.go") + ":32:14"}, &buf); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"*ast.File",
+		"  *ast.FuncDecl",
+		"    *ast.BlockStmt",
+		"      *ast.ExprStmt",
+		"        *ast.CallExpr",
+		"          *ast.SelectorExpr",
+		"            *ast.Ident",
+	}
+
+	if e, a := len(expected), len(lines); e != a {
+		t.Fatalf("expected %d nodes, but received\n%s", e, buf.String())
+	}
+
+	for i, line := range lines {
+		if !strings.HasPrefix(line, expected[i]+" ") {
+			t.Errorf("expected %q to start with %q", line, expected[i])
+		}
+	}
+
+	if !strings.HasSuffix(lines[4], "ValidateCallExpr   fmt.Println(foo.DeprecatedField)") {
+		t.Errorf("expected validate method and text, but received %q", lines[4])
+	}
+
+	if !strings.HasSuffix(lines[6], "foo") {
+		t.Errorf("expected foo, but received %q", lines[6])
+	}
+
+	if err := dumpCommand([]string{"-ast", "core.go"}, &buf); err == nil {
+		t.Errorf("expected error for a position without a line")
+	}
+
+	if err := dumpCommand(nil, &buf); err == nil {
+		t.Errorf("expected error without -cache or -ast")
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	cases := []struct {
+		position         string
+		expectedFilename string
+		expectedLine     int
+		expectedColumn   int
+	}{
+		{"foo.go:3", "foo.go", 3, 0},
+		{"foo.go:3:4", "foo.go", 3, 4},
+		{"C:/foo.go:3:4", "C:/foo.go", 3, 4},
+	}
+
+	for _, c := range cases {
+		filename, line, column, err := parsePosition(c.position)
+		if err != nil {
+			t.Fatalf("%q: expected no error, but received %v", c.position, err)
+		}
+
+		if filename != c.expectedFilename || line != c.expectedLine || column != c.expectedColumn {
+			t.Errorf("%q: expected %s %d %d, but received %s %d %d", c.position,
+				c.expectedFilename, c.expectedLine, c.expectedColumn,
+				filename, line, column,
+			)
+		}
+	}
+}
+
+func TestMatchImportPath(t *testing.T) {
+	cases := map[string]bool{
+		"":                true,
+		"foo/bar":         true,
+		"foo/...":         true,
+		"foo/bar/...":     true,
+		"foo/b/...":       false,
+		"foo/bar/baz/...": false,
+	}
+
+	for pattern, expected := range cases {
+		if e, a := expected, matchImportPath(pattern, "foo/bar"); e != a {
+			t.Errorf("%q: expected %t, but received %t", pattern, e, a)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"strings"
+
+	"github.com/go-toolset/pepperlint"
 )
 
 // Flags represent flags passed in via command line. These fields
@@ -14,6 +16,42 @@ type flags struct {
 
 	RuleNames    []string
 	Suppressions []string
+
+	Jobs int
+
+	BuildTags []string
+	GOOS      string
+	GOARCH    string
+
+	// Tests is only set if the tests flag was passed.
+	Tests *bool
+
+	// Cache is only set if the cache flag was passed.
+	Cache *bool
+
+	// Patterns are the files, directories and package patterns to lint.
+	Patterns []string
+
+	// Stdin will lint the contents of stdin as the file StdinFilename.
+	Stdin         bool
+	StdinFilename string
+
+	// Watch will lint the patterns again every time their files change.
+	Watch bool
+
+	// Stats will print where the time of a run was spent, and StatsJSON is the
+	// file the stats are written to as JSON.
+	Stats     bool
+	StatsJSON string
+
+	// CPUProfile and MemProfile are the files the pprof profiles of the run are
+	// written to.
+	CPUProfile string
+	MemProfile string
+
+	// Verbose is the level of entries that are logged to stderr, and is only
+	// set if the v flag was passed.
+	Verbose *pepperlint.Level
 }
 
 func newFlags() flags {
@@ -42,8 +80,120 @@ func newFlags() flags {
 		"path to yaml config",
 	)
 
+	flag.IntVar(
+		&f.Jobs,
+		"j",
+		0,
+		"number of packages to lint in parallel, defaults to 1",
+	)
+
+	buildTags := ""
+	flag.StringVar(
+		&buildTags,
+		"tags",
+		"",
+		"comma separated list of build tags to consider satisfied",
+	)
+
+	flag.StringVar(
+		&f.GOOS,
+		"goos",
+		"",
+		"operating system whose files are linted, defaults to the current one",
+	)
+
+	flag.StringVar(
+		&f.GOARCH,
+		"goarch",
+		"",
+		"architecture whose files are linted, defaults to the current one",
+	)
+
+	tests := flag.Bool(
+		"tests",
+		true,
+		"include _test.go files, defaults to true",
+	)
+
+	cache := flag.Bool(
+		"cache",
+		true,
+		"cache the declarations of included packages on disk, defaults to true",
+	)
+
+	flag.BoolVar(
+		&f.Stdin,
+		"stdin",
+		false,
+		"lint the contents of stdin, which requires -stdin-filename",
+	)
+
+	flag.StringVar(
+		&f.StdinFilename,
+		"stdin-filename",
+		"",
+		"path of the file whose contents are read from stdin",
+	)
+
+	flag.BoolVar(
+		&f.Watch,
+		"watch",
+		false,
+		"lint again every time a linted file changes, until interrupted",
+	)
+
+	flag.BoolVar(
+		&f.Stats,
+		"stats",
+		false,
+		"print the time spent in each phase and rule to stderr",
+	)
+
+	flag.StringVar(
+		&f.StatsJSON,
+		"stats-json",
+		"",
+		"write the time spent in each phase and rule as JSON to a file, or stdout if -",
+	)
+
+	flag.StringVar(
+		&f.CPUProfile,
+		"cpuprofile",
+		"",
+		"write a CPU profile to a file",
+	)
+
+	flag.StringVar(
+		&f.MemProfile,
+		"memprofile",
+		"",
+		"write a memory profile to a file",
+	)
+
+	verbose := levelFlag{}
+	flag.Var(
+		&verbose,
+		"v",
+		"log to stderr at info level, or at the level given as -v=debug, -v=info or -v=warn, and report the nodes rules do not handle",
+	)
+
 	flag.Parse()
 
+	f.Patterns = flag.Args()
+
+	flag.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "v":
+			if verbose.set {
+				f.Verbose = &verbose.level
+			}
+		case "tests":
+			f.Tests = tests
+		case "cache":
+			f.Cache = cache
+		}
+	})
+
 	if len(ruleNames) > 0 {
 		f.RuleNames = strings.Split(ruleNames, ",")
 	}
@@ -52,6 +202,10 @@ func newFlags() flags {
 		f.IncludePkgs = strings.Split(includePkgs, ",")
 	}
 
+	if len(buildTags) > 0 {
+		f.BuildTags = splitBuildTags(buildTags)
+	}
+
 	return f
 }
 
@@ -69,5 +223,80 @@ func (f flags) Merge(config Config) Config {
 		}
 	}
 
+	if f.Jobs > 0 {
+		config.Jobs = f.Jobs
+	}
+
+	if len(f.BuildTags) > 0 {
+		config.BuildTags = f.BuildTags
+	}
+
+	if len(f.GOOS) > 0 {
+		config.GOOS = f.GOOS
+	}
+
+	if len(f.GOARCH) > 0 {
+		config.GOARCH = f.GOARCH
+	}
+
+	if f.Tests != nil {
+		config.Tests = f.Tests
+	}
+
+	if f.Cache != nil {
+		config.Cache = f.Cache
+	}
+
+	if f.Stats || len(f.StatsJSON) > 0 {
+		config.Stats = true
+	}
+
 	return config
 }
+
+// levelFlag is a log level that defaults to the info level when the flag is
+// passed without a value.
+type levelFlag struct {
+	set   bool
+	level pepperlint.Level
+}
+
+func (f *levelFlag) String() string {
+	if f == nil || !f.set {
+		return ""
+	}
+
+	return f.level.String()
+}
+
+func (f *levelFlag) Set(v string) error {
+	switch v {
+	case "true":
+		f.set, f.level = true, pepperlint.InfoLevel
+		return nil
+	case "false":
+		f.set = false
+		return nil
+	}
+
+	level, err := pepperlint.ParseLevel(v)
+	if err != nil {
+		return err
+	}
+
+	f.set, f.level = true, level
+	return nil
+}
+
+// IsBoolFlag allows the flag to be passed without a value.
+func (f *levelFlag) IsBoolFlag() bool {
+	return true
+}
+
+// splitBuildTags will split build tags that are separated by commas or spaces,
+// which are both accepted by the go tool.
+func splitBuildTags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
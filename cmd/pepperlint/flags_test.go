@@ -3,9 +3,13 @@ package main
 import (
 	"reflect"
 	"testing"
+
+	"github.com/go-toolset/pepperlint"
 )
 
 func TestFlagsMerge(t *testing.T) {
+	trueValue, falseValue := true, false
+
 	cases := []struct {
 		name           string
 		flagsConfig    flags
@@ -54,6 +58,57 @@ func TestFlagsMerge(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "jobs case",
+			flagsConfig: flags{
+				Jobs: 4,
+			},
+			config: Config{
+				Jobs: 2,
+			},
+			expectedConfig: Config{
+				Jobs: 4,
+			},
+		},
+		{
+			name: "build constraints case",
+			flagsConfig: flags{
+				BuildTags: []string{"foo"},
+				GOOS:      "windows",
+			},
+			config: Config{
+				BuildTags: []string{"bar"},
+				GOOS:      "linux",
+				GOARCH:    "arm64",
+			},
+			expectedConfig: Config{
+				BuildTags: []string{"foo"},
+				GOOS:      "windows",
+				GOARCH:    "arm64",
+			},
+		},
+		{
+			name: "tests case",
+			flagsConfig: flags{
+				Tests: &falseValue,
+			},
+			config: Config{
+				Tests: &trueValue,
+			},
+			expectedConfig: Config{
+				Tests: &falseValue,
+			},
+		},
+		{
+			name: "stats json case",
+			flagsConfig: flags{
+				StatsJSON: "stats.json",
+			},
+			config: Config{},
+			expectedConfig: Config{
+				Stats: true,
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -66,3 +121,49 @@ func TestFlagsMerge(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitBuildTags(t *testing.T) {
+	cases := map[string][]string{
+		"foo":           {"foo"},
+		"foo,bar":       {"foo", "bar"},
+		"foo bar":       {"foo", "bar"},
+		"foo, bar,,baz": {"foo", "bar", "baz"},
+	}
+
+	for tags, expected := range cases {
+		if e, a := expected, splitBuildTags(tags); !reflect.DeepEqual(e, a) {
+			t.Errorf("%q: expected %v, but received %v", tags, e, a)
+		}
+	}
+}
+
+func TestLevelFlag(t *testing.T) {
+	cases := []struct {
+		value         string
+		expectedSet   bool
+		expectedLevel pepperlint.Level
+		expectedErr   bool
+	}{
+		{value: "true", expectedSet: true, expectedLevel: pepperlint.InfoLevel},
+		{value: "debug", expectedSet: true, expectedLevel: pepperlint.DebugLevel},
+		{value: "WARN", expectedSet: true, expectedLevel: pepperlint.WarnLevel},
+		{value: "false"},
+		{value: "trace", expectedErr: true},
+	}
+
+	for _, c := range cases {
+		f := levelFlag{}
+		err := f.Set(c.value)
+		if e, a := c.expectedErr, err != nil; e != a {
+			t.Fatalf("%q: expected error %t, but received %v", c.value, e, err)
+		}
+
+		if e, a := c.expectedSet, f.set; e != a {
+			t.Errorf("%q: expected set %t, but received %t", c.value, e, a)
+		}
+
+		if e, a := c.expectedLevel, f.level; e != a {
+			t.Errorf("%q: expected %v, but received %v", c.value, e, a)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+const lspUsage = `usage: pepperlint lsp [-config-path path]
+
+lsp runs a language server over stdin and stdout. Open files are linted as
+they change, along with the other files of their directory, and diagnostics
+are published for every file of the directory.
+`
+
+// lspCommand will run the language server until the client exits.
+func lspCommand(args []string, r io.Reader, w io.Writer) error {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, lspUsage)
+	}
+
+	configPath := fs.String("config-path", "", "path to yaml config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := NewConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	opts, err := lintOptions(withCacheDir(config), config.IncludePkgs)
+	if err != nil {
+		return err
+	}
+	defer config.Plugins.Close()
+
+	// included packages are loaded once, and reused for every edit
+	linter, err := pepperlint.NewLinter(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	s := newLSPServer(linter, newLSPConn(r, w))
+	return s.serve()
+}
+
+// lspServer lints open documents as they change. Messages are handled one at a
+// time, in the order they are received.
+type lspServer struct {
+	linter *pepperlint.Linter
+	conn   *lspConn
+
+	// docs contains the contents of every open document, keyed by filename.
+	docs map[string][]byte
+
+	// diags contains the last diagnostics published for each file.
+	diags map[string][]pepperlint.Diagnostic
+
+	shutdown bool
+}
+
+func newLSPServer(linter *pepperlint.Linter, conn *lspConn) *lspServer {
+	return &lspServer{
+		linter: linter,
+		conn:   conn,
+		docs:   map[string][]byte{},
+		diags:  map[string][]pepperlint.Diagnostic{},
+	}
+}
+
+// serve will handle messages until the client sends exit or closes the
+// connection. An error is returned if the client exits without shutting down
+// the server first.
+func (s *lspServer) serve() error {
+	for {
+		req, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit received before shutdown")
+			}
+
+			return nil
+		}
+
+		result, lspErr := s.handle(req)
+		if req.ID == nil {
+			if lspErr != nil {
+				s.logError(lspErr.Message)
+			}
+
+			continue
+		}
+
+		if err := s.conn.reply(req.ID, result, lspErr); err != nil {
+			return err
+		}
+	}
+}
+
+// handle will handle a single request or notification and return its result.
+func (s *lspServer) handle(req lspRequest) (interface{}, *lspError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   lspFullSync,
+				"codeActionProvider": true,
+			},
+			"serverInfo": map[string]string{
+				"name":    "pepperlint",
+				"version": pepperlint.Version,
+			},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params := lspDidOpenParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+
+		filename, err := uriToFilename(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		s.docs[filename] = []byte(params.TextDocument.Text)
+		return nil, s.lint(filename)
+	case "textDocument/didChange":
+		params := lspDidChangeParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+
+		filename, err := uriToFilename(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		// only full document syncs are supported, so the last change
+		// contains the whole document
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[filename] = []byte(params.ContentChanges[n-1].Text)
+		}
+
+		return nil, s.lint(filename)
+	case "textDocument/didSave":
+		params := lspDocumentParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+
+		filename, err := uriToFilename(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, s.lint(filename)
+	case "textDocument/didClose":
+		params := lspDocumentParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+
+		filename, err := uriToFilename(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(s.docs, filename)
+		s.publish(filename, nil)
+		return nil, nil
+	case "textDocument/codeAction":
+		params := lspCodeActionParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+
+		filename, err := uriToFilename(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.codeActions(filename, params.Range), nil
+	}
+
+	if req.ID == nil {
+		// unknown notifications, such as $/cancelRequest, are ignored
+		return nil, nil
+	}
+
+	return nil, &lspError{
+		Code:    lspMethodNotFound,
+		Message: fmt.Sprintf("method %q is not supported", req.Method),
+	}
+}
+
+func decodeParams(req lspRequest, v interface{}) *lspError {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &lspError{
+			Code:    lspInvalidParams,
+			Message: fmt.Sprintf("invalid %s params: %v", req.Method, err),
+		}
+	}
+
+	return nil
+}
+
+// lint will lint the directory of filename with every open document in place
+// of its file on disk, and publish the diagnostics of every file of the
+// directory. Files whose diagnostics were all fixed are published as well, so
+// the client clears them.
+func (s *lspServer) lint(filename string) *lspError {
+	dir := filepath.Dir(filename)
+	result, err := s.linter.Run(context.Background(), []string{dir}, s.docs)
+	if err != nil {
+		return &lspError{
+			Code:    lspInternalError,
+			Message: fmt.Sprintf("unable to lint %s: %v", dir, err),
+		}
+	}
+
+	diags := map[string][]pepperlint.Diagnostic{}
+	for _, diag := range result.Diagnostics {
+		diags[diag.Filename()] = append(diags[diag.Filename()], diag)
+	}
+
+	for published := range s.diags {
+		if _, ok := diags[published]; !ok && filepath.Dir(published) == dir {
+			diags[published] = nil
+		}
+	}
+
+	if _, ok := diags[filename]; !ok {
+		diags[filename] = nil
+	}
+
+	filenames := make([]string, 0, len(diags))
+	for f := range diags {
+		filenames = append(filenames, f)
+	}
+	sort.Strings(filenames)
+
+	for _, f := range filenames {
+		// errors that are not tied to a file cannot be published
+		if len(f) > 0 {
+			s.publish(f, diags[f])
+		}
+	}
+
+	return nil
+}
+
+// publish will send the diagnostics of filename to the client.
+func (s *lspServer) publish(filename string, diags []pepperlint.Diagnostic) {
+	if len(diags) == 0 {
+		delete(s.diags, filename)
+	} else {
+		s.diags[filename] = diags
+	}
+
+	src := s.source(filename)
+	params := lspPublishDiagnosticsParams{
+		URI:         filenameToURI(filename),
+		Diagnostics: []lspDiagnostic{},
+	}
+
+	for _, diag := range diags {
+		params.Diagnostics = append(params.Diagnostics, toLSPDiagnostic(src, diag))
+	}
+
+	if err := s.conn.notify("textDocument/publishDiagnostics", params); err != nil {
+		pepperlint.DefaultLogger.Log(pepperlint.WarnLevel, fmt.Sprintf("unable to publish diagnostics of %s: %v", filename, err))
+	}
+}
+
+// codeActions will return the suggested fixes of every diagnostic within rng,
+// along with an action that inserts an ignore comment for each of them.
+func (s *lspServer) codeActions(filename string, rng lspRange) []lspCodeAction {
+	src := s.source(filename)
+	uri := filenameToURI(filename)
+
+	actions := []lspCodeAction{}
+	for _, diag := range s.diags[filename] {
+		line := diag.LineNumber() - 1
+		if line < rng.Start.Line || line > rng.End.Line {
+			continue
+		}
+
+		lspDiag := toLSPDiagnostic(src, diag)
+		for _, fix := range diag.Fixes() {
+			edit := lspWorkspaceEdit{
+				Changes: map[string][]lspTextEdit{},
+			}
+
+			for _, e := range fix.Edits {
+				editSrc := src
+				if e.Pos.Filename != filename {
+					editSrc = s.source(e.Pos.Filename)
+				}
+
+				editURI := filenameToURI(e.Pos.Filename)
+				edit.Changes[editURI] = append(edit.Changes[editURI], lspTextEdit{
+					Range: lspRange{
+						Start: toLSPPosition(editSrc, e.Pos),
+						End:   toLSPPosition(editSrc, e.End),
+					},
+					NewText: e.NewText,
+				})
+			}
+
+			actions = append(actions, lspCodeAction{
+				Title:       fix.Message,
+				Kind:        "quickfix",
+				Diagnostics: []lspDiagnostic{lspDiag},
+				Edit:        edit,
+			})
+		}
+
+		// the ignore comment is inserted above the line, with the same
+		// indentation
+		text := lineText(src, diag.LineNumber())
+		indent := text[:len(text)-len(strings.TrimLeft(string(text), " \t"))]
+		actions = append(actions, lspCodeAction{
+			Title:       fmt.Sprintf("Ignore %s on this line", diag.Rule),
+			Kind:        "quickfix",
+			Diagnostics: []lspDiagnostic{lspDiag},
+			Edit: lspWorkspaceEdit{
+				Changes: map[string][]lspTextEdit{
+					uri: {
+						{
+							Range: lspRange{
+								Start: lspPosition{Line: line},
+								End:   lspPosition{Line: line},
+							},
+							NewText: string(indent) + pepperlint.IgnoreComment(diag.Rule) + "\n",
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return actions
+}
+
+// source will return the contents of filename, from its open document if it is
+// open and from disk otherwise. nil is returned if it could not be read.
+func (s *lspServer) source(filename string) []byte {
+	if src, ok := s.docs[filename]; ok {
+		return src
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+
+	return src
+}
+
+func (s *lspServer) logError(msg string) {
+	err := s.conn.notify("window/logMessage", lspLogMessageParams{
+		Type:    lspMessageError,
+		Message: msg,
+	})
+
+	if err != nil {
+		pepperlint.DefaultLogger.Log(pepperlint.WarnLevel, fmt.Sprintf("unable to log %q: %v", msg, err))
+	}
+}
+
+func toLSPDiagnostic(src []byte, diag pepperlint.Diagnostic) lspDiagnostic {
+	msg := diag.Error()
+	if errWrap, ok := diag.Err.(*pepperlint.ErrorWrap); ok {
+		msg = errWrap.Message()
+	}
+
+	pos := toLSPPosition(src, diag.Pos)
+	end := pos
+	if diag.End.IsValid() {
+		end = toLSPPosition(src, diag.End)
+	}
+
+	return lspDiagnostic{
+		Range: lspRange{
+			Start: pos,
+			End:   end,
+		},
+		Severity: lspSeverityWarning,
+		Code:     diag.Rule,
+		Source:   "pepperlint",
+		Message:  msg,
+	}
+}
+
+// toLSPPosition will convert a position, whose column is a 1 based byte offset,
+// to a 0 based line and UTF-16 offset as the protocol expects.
+func toLSPPosition(src []byte, pos token.Position) lspPosition {
+	if pos.Line < 1 {
+		return lspPosition{}
+	}
+
+	lspPos := lspPosition{
+		Line: pos.Line - 1,
+	}
+
+	text := lineText(src, pos.Line)
+	col := pos.Column - 1
+	if col < 0 {
+		col = 0
+	} else if col > len(text) {
+		col = len(text)
+	}
+
+	for _, r := range string(text[:col]) {
+		if r >= 0x10000 {
+			lspPos.Character += 2
+		} else {
+			lspPos.Character++
+		}
+	}
+
+	return lspPos
+}
+
+// lineText will return the contents of the 1 based line without its line
+// ending.
+func lineText(src []byte, line int) []byte {
+	for i := 1; i < line; i++ {
+		j := bytes.IndexByte(src, '\n')
+		if j < 0 {
+			return nil
+		}
+
+		src = src[j+1:]
+	}
+
+	if j := bytes.IndexByte(src, '\n'); j >= 0 {
+		src = src[:j]
+	}
+
+	return bytes.TrimSuffix(src, []byte("\r"))
+}
+
+func uriToFilename(uri string) (string, *lspError) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", &lspError{
+			Code:    lspInvalidParams,
+			Message: fmt.Sprintf("unsupported document URI %q", uri),
+		}
+	}
+
+	return filepath.FromSlash(u.Path), nil
+}
+
+func filenameToURI(filename string) string {
+	u := url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(filename),
+	}
+
+	return u.String()
+}
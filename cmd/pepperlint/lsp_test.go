@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// lspTestMessage is any message the server sends.
+type lspTestMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *lspError       `json:"error"`
+}
+
+func TestLSPCommand(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", gopath)
+
+	files := map[string]string{
+		"src/gen/gen.go": "package gen\n\n// Deprecated: use something else\ntype Foo struct{}\n",
+		"src/use/use.go": "package use\n",
+		"config.yaml":    "rules:\n  - rule_name: core/deprecated\nincludepkgs:\n  - gen\ncache: false\n",
+	}
+
+	for name, content := range files {
+		filename := filepath.Join(gopath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	client := newLSPConn(clientR, clientW)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- lspCommand([]string{"-config-path", filepath.Join(gopath, "config.yaml")}, serverR, serverW)
+		serverW.Close()
+	}()
+
+	id := 0
+	send := func(method string, params interface{}, request bool) {
+		msg := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  method,
+			"params":  params,
+		}
+
+		if request {
+			id++
+			msg["id"] = id
+		}
+
+		if err := client.write(msg); err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+	}
+
+	receive := func() lspTestMessage {
+		body, err := client.readBody()
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		msg := lspTestMessage{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		return msg
+	}
+
+	receiveDiagnostics := func() lspPublishDiagnosticsParams {
+		msg := receive()
+		if e, a := "textDocument/publishDiagnostics", msg.Method; e != a {
+			t.Fatalf("expected %q, but received %q", e, a)
+		}
+
+		params := lspPublishDiagnosticsParams{}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		return params
+	}
+
+	send("initialize", map[string]interface{}{}, true)
+	if msg := receive(); msg.ID == nil || *msg.ID != 1 || !strings.Contains(string(msg.Result), `"codeActionProvider":true`) {
+		t.Errorf("expected initialize result, but received %s", msg.Result)
+	}
+	send("initialized", map[string]interface{}{}, false)
+
+	uri := filenameToURI(filepath.Join(gopath, "src", "use", "use.go"))
+	src := "package use\n\nimport \"gen\"\n\nfunc use() {\n\t_ = gen.Foo{}\n}\n"
+	send("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        uri,
+			"languageId": "go",
+			"version":    1,
+			"text":       src,
+		},
+	}, false)
+
+	diags := receiveDiagnostics()
+	if e, a := uri, diags.URI; e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	if e, a := 1, len(diags.Diagnostics); e != a {
+		t.Fatalf("expected %d diagnostics, but received %d", e, a)
+	}
+
+	if e, a := (lspPosition{Line: 5, Character: 5}), diags.Diagnostics[0].Range.Start; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	send("textDocument/codeAction", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri": uri,
+		},
+		"range":   diags.Diagnostics[0].Range,
+		"context": map[string]interface{}{"diagnostics": diags.Diagnostics},
+	}, true)
+
+	msg := receive()
+	actions := []lspCodeAction{}
+	if err := json.Unmarshal(msg.Result, &actions); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := 1, len(actions); e != a {
+		t.Fatalf("expected %d actions, but received %d", e, a)
+	}
+
+	edits := actions[0].Edit.Changes[uri]
+	expectedEdits := []lspTextEdit{
+		{
+			Range: lspRange{
+				Start: lspPosition{Line: 5},
+				End:   lspPosition{Line: 5},
+			},
+			NewText: "\t" + pepperlint.IgnoreComment(diags.Diagnostics[0].Code) + "\n",
+		},
+	}
+	if e, a := expectedEdits, edits; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// applying the action clears the diagnostic
+	lines := strings.SplitAfter(src, "\n")
+	src = strings.Join(lines[:5], "") + edits[0].NewText + strings.Join(lines[5:], "")
+	send("textDocument/didChange", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":     uri,
+			"version": 2,
+		},
+		"contentChanges": []map[string]interface{}{
+			{"text": src},
+		},
+	}, false)
+
+	if diags := receiveDiagnostics(); len(diags.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, but received %v", diags.Diagnostics)
+	}
+
+	send("unknown/method", map[string]interface{}{}, true)
+	if msg := receive(); msg.Error == nil || msg.Error.Code != lspMethodNotFound {
+		t.Errorf("expected method not found error, but received %v", msg.Error)
+	}
+
+	send("shutdown", nil, true)
+	if msg := receive(); string(msg.Result) != "null" {
+		t.Errorf("expected null result, but received %s", msg.Result)
+	}
+
+	send("exit", nil, false)
+	if err := <-done; err != nil {
+		t.Errorf("expected no error, but received %v", err)
+	}
+}
+
+func TestLSPPosition(t *testing.T) {
+	src := []byte("package foo\r\n\nvar s = \"\xf0\x9f\x98\x80é\" + x\n")
+
+	cases := []struct {
+		pos      token.Position
+		expected lspPosition
+	}{
+		{
+			pos:      token.Position{Line: 1, Column: 9},
+			expected: lspPosition{Line: 0, Character: 8},
+		},
+		{
+			// the emoji takes two UTF-16 code units, and é takes one
+			pos:      token.Position{Line: 3, Column: 20},
+			expected: lspPosition{Line: 2, Character: 16},
+		},
+		{
+			pos:      token.Position{Line: 3},
+			expected: lspPosition{Line: 2},
+		},
+	}
+
+	for _, c := range cases {
+		if e, a := c.expected, toLSPPosition(src, c.pos); e != a {
+			t.Errorf("%v: expected %v, but received %v", c.pos, e, a)
+		}
+	}
+}
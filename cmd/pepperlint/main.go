@@ -1,260 +1,242 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
-	"sort"
-
-	"go/ast"
-	"go/parser"
-	"go/token"
+	"text/tabwriter"
 
 	"github.com/go-toolset/pepperlint"
 )
 
-// PackageSetBuilder wil lint the directory specified and walk the directory to grab
-// the necessary dependencies needed for the given rules.
-type PackageSetBuilder struct {
-	pkg         string
-	includeDirs []string
-}
-
-// Packages contains all packages in a given path
-type Packages struct {
-	path string
-	pkgs map[string]*ast.Package
-}
-
-// Container contains two separate Package groups. 'RulesPackage' represents packages
-// that rules are applied to and while the other, 'Packages', will have rules applied
-// during visitation.
-type Container struct {
-	RulesPackages []Packages
-
-	// Packages do not have rules applied to them
-	Packages []Packages
-}
+// lint will lint the packages matched by patterns while walking the pkgs
+// provided to grab necessary metadata from to then validate the matched packages
+// with the gathered metadata. Any file in overlay is linted with its overlaid
+// contents instead of the contents on disk.
+func lint(config Config, pkgs []string, patterns []string, overlay map[string][]byte) (pepperlint.Result, error) {
+	opts, err := lintOptions(config, pkgs)
+	if err != nil {
+		return pepperlint.Result{}, err
+	}
+	defer config.Plugins.Close()
 
-// WithPkg will return a copy of the builder with the dir being
-// the root directory that will be walked during linting
-func (b PackageSetBuilder) WithPkg(pkg string) PackageSetBuilder {
-	b.pkg = pkg
-	return b
+	opts.Patterns = patterns
+	opts.Overlay = overlay
+	return pepperlint.Run(context.Background(), opts)
 }
 
-// WithImports will return a copy of the builder with the imports to be
-// required for determining what the rules are acting against.
-func (b PackageSetBuilder) WithImports(imports []string) PackageSetBuilder {
-	b.includeDirs = imports
-	return b
-}
+// lintOptions will return the options of the config, which every package is
+// linted with, along with the pkgs to include. The plugins, banned rules and
+// pattern rules of the config are registered first, so rules can refer to them.
+func lintOptions(config Config, pkgs []string) (pepperlint.Options, error) {
+	if err := config.Plugins.Register(); err != nil {
+		return pepperlint.Options{}, err
+	}
 
-func (b PackageSetBuilder) addDir(fset *token.FileSet, container Container) Container {
-	// walk root directory to gather all packages in the given directory
-	filepath.Walk(b.pkg, func(path string, info os.FileInfo, err error) error {
-		// only need directories due to the `ParseDir` call.
-		if !info.Mode().IsDir() {
-			return nil
-		}
+	if err := config.Banned.Register(); err != nil {
+		return pepperlint.Options{}, err
+	}
 
-		root, err := parser.ParseDir(fset, path, nil, parser.ParseComments)
-		if err != nil {
-			log.Fatal(err)
-		}
+	if err := config.Patterns.Register(); err != nil {
+		return pepperlint.Options{}, err
+	}
 
-		container.RulesPackages = append(container.RulesPackages, Packages{
-			path: path,
-			pkgs: root,
-		})
+	rules, err := config.CopyRulers()
+	if err != nil {
+		return pepperlint.Options{}, err
+	}
 
-		return nil
-	})
+	opts := loadOptions(config, pkgs)
+	opts.Rules = rules
+	opts.Suppressions = config.Suppressions.Options()
+	opts.Jobs = config.Jobs
+	opts.Stats = config.Stats
+	opts.Logger = config.Logger
+	opts.Relint = config.Relint
 
-	return container
+	return opts, nil
 }
 
-func (b PackageSetBuilder) addFile(fset *token.FileSet, container Container) (Container, error) {
-	root, err := parser.ParseFile(fset, b.pkg, nil, parser.ParseComments)
-	if err != nil {
-		return container, err
+// loadOptions will return the options of the config that determine which files
+// are loaded and how packages are cached, along with the pkgs to include.
+func loadOptions(config Config, pkgs []string) pepperlint.Options {
+	var diskCache *pepperlint.DiskCache
+	if len(config.CacheDir) > 0 {
+		diskCache = pepperlint.NewDiskCache(config.CacheDir)
 	}
 
-	pkgRoot := map[string]*ast.Package{
-		root.Name.Name: &ast.Package{
-			Name: root.Name.Name,
-			Files: map[string]*ast.File{
-				filepath.Base(b.pkg): root,
-			},
-		},
+	return pepperlint.Options{
+		IncludePkgs:      pkgs,
+		BuildTags:        config.BuildTags,
+		GOOS:             config.GOOS,
+		GOARCH:           config.GOARCH,
+		ExcludeTests:     config.Tests != nil && !*config.Tests,
+		IncludeGenerated: config.Generated,
+		DiskCache:        diskCache,
 	}
-
-	container.RulesPackages = append(container.RulesPackages, Packages{
-		path: b.pkg,
-		pkgs: pkgRoot,
-	})
-
-	return container, nil
 }
 
-// Build will build a container which contains all packages we will walk.
-func (b PackageSetBuilder) Build() (Container, *token.FileSet, error) {
-	container := Container{}
-	fset := token.NewFileSet()
-
-	info, err := os.Stat(b.pkg)
-	if err != nil {
-		return container, nil, err
+// withCacheDir will set the cache directory of the config to the default one,
+// unless the config already has one or caching is turned off.
+func withCacheDir(config Config) Config {
+	if config.Cache != nil && !*config.Cache {
+		config.CacheDir = ""
+		return config
 	}
 
-	if info.IsDir() {
-		container = b.addDir(fset, container)
-	} else {
-		container, err = b.addFile(fset, container)
+	if len(config.CacheDir) == 0 {
+		dir, err := pepperlint.DefaultDiskCacheDir()
 		if err != nil {
-			return container, nil, err
+			log.Printf("not caching packages on disk: %v", err)
 		}
-	}
-
-	for _, included := range b.includeDirs {
-		filepath.Walk(included, func(path string, info os.FileInfo, err error) error {
-			if !info.Mode().IsDir() {
-				return nil
-			}
-
-			root, err := parser.ParseDir(fset, path, nil, parser.ParseComments)
-			if err != nil {
-				return err
-			}
 
-			container.Packages = append(container.Packages, Packages{
-				path: path,
-				pkgs: root,
-			})
-			return nil
-		})
+		config.CacheDir = dir
 	}
 
-	return container, fset, nil
+	return config
 }
 
-func walk(v ast.Visitor, p []Packages) {
-	sortedPkgNames := []string{}
-	for _, pkgs := range p {
-		sortedPkgNames = sortedPkgNames[0:0]
-		for name := range pkgs.pkgs {
-			sortedPkgNames = append(sortedPkgNames, name)
-		}
-
-		sort.Strings(sortedPkgNames)
-		for _, name := range sortedPkgNames {
-			pkg := pkgs.pkgs[name]
-			ast.Walk(v, pkg)
-		}
+// printUnhandled will write the nodes that rules do not handle, along with how
+// often and where each was first found.
+func printUnhandled(w io.Writer, nodes []pepperlint.UnhandledNode) {
+	if len(nodes) == 0 {
+		return
 	}
-}
 
-// lint will lint the dir while walking the dirs provided to grab necessary metadata
-// from to then validate the dir with the gathered metadata.
-func lint(config Config, pkgs []string, pkg string) (*pepperlint.Visitor, Container, error) {
-	gopath := filepath.Join(os.Getenv("GOPATH"), "src")
+	fmt.Fprintln(w, "unhandled nodes:")
 
-	// Prepends go path to each package in pkgs
-	for i, p := range pkgs {
-		pkgs[i] = filepath.Join(gopath, p)
-	}
-
-	builder := PackageSetBuilder{}.WithImports(pkgs).WithPkg(pkg)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, node := range nodes {
+		pos := "-"
+		if node.Pos.IsValid() {
+			pos = node.Pos.String()
+		}
 
-	container, fset, err := builder.Build()
-	if err != nil {
-		return nil, Container{}, err
+		fmt.Fprintf(tw, "  %d\t%s\t%s\t%s\t%s\n", node.Count, node.Rule, node.Site, node.Node, pos)
 	}
 
-	cache := pepperlint.NewCache()
+	tw.Flush()
+}
 
-	//rule := core.NewDeprecatedRule(fset)
-	v := pepperlint.NewVisitor(fset, cache, config.Options()...)
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	walk(cache, container.Packages)
-	walk(cache, container.RulesPackages)
-	walk(v, container.RulesPackages)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			if err := cacheCommand(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
 
-	return v, container, nil
-}
+			return
+		case "lsp":
+			if err := lspCommand(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				log.Fatal(err)
+			}
 
-func suppress(suppressions Suppressions, errs pepperlint.Errors) []error {
-	s := map[string]File{}
-	validErrs := []error{}
+			return
+		case "new-rule":
+			if err := newRuleCommand(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
 
-	for _, sup := range suppressions {
-		if sup.File == nil {
-			continue
-		}
+			return
+		case "dump":
+			if err := dumpCommand(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
 
-		s[sup.File.FilePath] = *sup.File
+			return
+		}
 	}
 
-	for _, err := range errs {
-		e, ok := err.(pepperlint.FileError)
-		if !ok {
-			validErrs = append(validErrs, err)
-			continue
-		}
+	f := newFlags()
+	config := buildConfig(f.ConfigPath)
+	config = f.Merge(config)
+
+	config = withCacheDir(config)
 
-		supRule, ok := s[e.Filename()]
-		if !ok {
-			validErrs = append(validErrs, err)
-			continue
+	patterns := f.Patterns
+	var overlay map[string][]byte
+	if f.Stdin {
+		if len(f.StdinFilename) == 0 {
+			log.Fatalf("-stdin-filename needs to be provided with -stdin")
 		}
 
-		if supRule.LineNumber == nil {
-			continue
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		if e.LineNumber() == *supRule.LineNumber {
-			continue
+		patterns = []string{f.StdinFilename}
+		overlay = map[string][]byte{
+			f.StdinFilename: src,
 		}
+	}
 
-		validErrs = append(validErrs, err)
+	if len(patterns) == 0 {
+		log.Fatalf("files, directories or patterns need to be provided")
 	}
 
-	return validErrs
-}
+	stopProfiles, err := startProfiles(f.CPUProfile, f.MemProfile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func main() {
-	if len(os.Args) < 2 {
-		log.Fatalf("directory needs to be provided")
+	// nodes rules do not handle are aggregated into a report, except when
+	// watching, which never finishes. Every package is relinted, since nothing
+	// is logged for packages whose diagnostics are reused from the cache.
+	var report *pepperlint.UnhandledReport
+	if f.Verbose != nil {
+		config.Logger = pepperlint.NewLogger(os.Stderr, *f.Verbose)
+		if !f.Watch {
+			report = pepperlint.NewUnhandledReport(config.Logger)
+			config.Logger = report
+			config.Relint = true
+		}
 	}
 
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if f.Watch {
+		defer stopProfiles()
 
-	f := newFlags()
-	config := buildConfig(f.ConfigPath)
-	config = f.Merge(config)
+		if f.Stdin {
+			log.Fatalf("-watch cannot be used with -stdin")
+		}
 
-	// TODO:
-	// Do we still need to move this into the pkgs variable?
-	// Can we not use config.IncludePkgs instead?
-	pkgs := []string{}
-	for _, p := range config.IncludePkgs {
-		if len(p) == 0 {
-			continue
+		if err := watch(config, config.IncludePkgs, patterns, os.Stdout); err != nil {
+			log.Fatal(err)
 		}
 
-		pkgs = append(pkgs, p)
+		return
 	}
 
-	pkg := os.Args[len(os.Args)-1]
-	v, _, err := lint(config, pkgs, pkg)
+	result, err := lint(config, config.IncludePkgs, patterns, overlay)
+	stopProfiles()
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+
+	if report != nil {
+		printUnhandled(os.Stderr, report.Nodes())
+	}
+
+	if result.Stats != nil {
+		if f.Stats {
+			printStats(os.Stderr, *result.Stats)
+		}
+
+		if len(f.StatsJSON) > 0 {
+			if err := writeStatsJSON(f.StatsJSON, *result.Stats); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
 
-	errs := suppress(config.Suppressions, v.Errors)
-	if len(errs) != 0 {
-		fmt.Fprintf(os.Stderr, "%v", pepperlint.Errors(errs))
+	if len(result.Diagnostics) != 0 {
+		fmt.Fprintf(os.Stderr, "%v", result.Errors())
 		os.Exit(1)
 	}
 }
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/go-toolset/pepperlint"
@@ -54,145 +62,389 @@ func TestMain(t *testing.T) {
 			},
 		}
 
-		v, _, err := lint(config, c.includeDirs, c.mainPackage)
+		result, err := lint(config, c.includeDirs, []string{c.mainPackage}, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		errs := []error{}
-		for _, err := range v.Errors {
-			e := err.(*pepperlint.BatchError)
-			es := e.Errors()
-			for _, err := range es {
-				errs = append(errs, err)
+		if e, a := len(c.expectedLineNumbers), len(result.Diagnostics); e != a {
+			numbers := []int{}
+			for _, diag := range result.Diagnostics {
+				numbers = append(numbers, diag.LineNumber())
 			}
+			t.Fatal(fmt.Sprintf("expected %v, but received %v: %v", e, a, numbers))
 		}
 
-		lerrs := []pepperlint.LineNumber{}
-		for _, err := range errs {
-			e := err.(*pepperlint.BatchError)
-			es := e.Errors()
-
-			for _, err := range es {
-				lerr := err.(pepperlint.LineNumber)
-				lerrs = append(lerrs, lerr)
+		for i, diag := range result.Diagnostics {
+			if e, a := c.expectedLineNumbers[i], diag.LineNumber(); e != a {
+				t.Errorf("expected %v, but received %v", e, a)
 			}
 		}
+		pepperlint.Log("ERRORS %v", result.Diagnostics)
+	}
+}
 
-		if e, a := len(c.expectedLineNumbers), len(lerrs); e != a {
-			numbers := []int{}
-			for _, lerr := range lerrs {
-				numbers = append(numbers, lerr.LineNumber())
-			}
-			t.Fatal(fmt.Sprintf("expected %v, but received %v: %v", e, a, numbers))
+func TestMainJobs(t *testing.T) {
+	lintWithJobs := func(jobs int) string {
+		config := Config{
+			Rules: Rules{
+				{
+					RuleName: "core/deprecated",
+				},
+			},
+			Jobs: jobs,
 		}
 
-		for i, e := range lerrs {
-			if e, a := c.expectedLineNumbers[i], e.LineNumber(); e != a {
-				t.Errorf("expected %v, but received %v", e, a)
-			}
+		includeDirs := []string{
+			"github.com/go-toolset/pepperlint/cmd/pepperlint/testdata/deprecated",
+		}
+
+		result, err := lint(config, includeDirs, []string{"./testdata/..."}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result.Errors().Error()
+	}
+
+	expected := lintWithJobs(1)
+	if len(expected) == 0 {
+		t.Fatalf("expected lint errors")
+	}
+
+	for _, jobs := range []int{2, 4, 8} {
+		if e, a := expected, lintWithJobs(jobs); e != a {
+			t.Errorf("%d jobs: expected %v, but received %v", jobs, e, a)
 		}
-		pepperlint.Log("ERRORS %v", v.Errors)
 	}
 }
 
-type mockError struct {
-	line     int
-	filename string
+func TestMainParseError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"bad.go": "package foo\n\nfunc bar( {\n}\n",
+		"good.go": `package foo
+
+// Deprecated: use something else
+type Foo struct{}
+
+func baz() {
+	_ = Foo{}
 }
+`,
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := Config{
+		Rules: Rules{
+			{
+				RuleName: "core/deprecated",
+			},
+		},
+	}
+
+	result, err := lint(config, []string{}, []string{dir}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	parseErrs := map[string]bool{}
+	for _, diag := range result.Diagnostics {
+		parseErrs[filepath.Base(diag.Filename())] = diag.Rule == pepperlint.ParseErrorRule
+	}
 
-func (m mockError) LineNumber() int {
-	return m.line
+	// the file that parses is still linted
+	if e, a := map[string]bool{"bad.go": true, "good.go": false}, parseErrs; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
 }
 
-func (m mockError) Filename() string {
-	return m.filename
+func TestMainGenerated(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", gopath)
+
+	files := map[string]string{
+		"gen/gen.go": `// Code generated by mockgen. DO NOT EDIT.
+
+package gen
+
+// Deprecated: use something else
+type Foo struct{}
+
+func use() {
+	_ = Foo{}
 }
+`,
+		"use/use.go": `package use
+
+import "gen"
 
-func (m mockError) Error() string {
-	return fmt.Sprintf("%s:%d", m.Filename(), m.LineNumber())
+func use() {
+	_ = gen.Foo{}
 }
+`,
+	}
 
-func TestMainSuppression(t *testing.T) {
-	line := 5
+	for name, content := range files {
+		filename := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	cases := []struct {
-		name           string
-		errors         []error
-		suppressions   Suppressions
-		expectedErrors []error
+		generated bool
+		expected  []string
 	}{
 		{
-			name:           "empty case",
-			expectedErrors: []error{},
+			expected: []string{"use.go:6"},
 		},
 		{
-			name: "simple supression by filename",
-			suppressions: Suppressions{
+			generated: true,
+			expected:  []string{"gen.go:9", "use.go:6"},
+		},
+	}
+
+	for _, c := range cases {
+		config := Config{
+			Rules: Rules{
 				{
-					File: &File{
-						FilePath: "foo.go",
-					},
-				},
-			},
-			errors: []error{
-				mockError{
-					filename: "foo.go",
-				},
-				mockError{
-					filename: "bar.go",
+					RuleName: "core/deprecated",
 				},
 			},
-			expectedErrors: []error{
-				mockError{
-					filename: "bar.go",
-				},
+			Generated: c.generated,
+		}
+
+		result, err := lint(config, []string{}, []string{filepath.Join(gopath, "src", "...")}, nil)
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		positions := []string{}
+		for _, diag := range result.Diagnostics {
+			positions = append(positions, fmt.Sprintf("%s:%d", filepath.Base(diag.Filename()), diag.LineNumber()))
+		}
+
+		if e, a := c.expected, positions; !reflect.DeepEqual(e, a) {
+			t.Errorf("generated %t: expected %v, but received %v", c.generated, e, a)
+		}
+	}
+}
+
+func TestMainDiskCache(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", gopath)
+
+	writeFile := func(name, content string) {
+		filename := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	depSrc := func(deprecated string) string {
+		return fmt.Sprintf(`package dep
+
+// %s: use something else
+type Foo struct{}
+
+// Bar is bar
+type Bar struct{}
+
+func (Foo) do() {
+	// Deprecated: not a doc comment
+}
+`, deprecated)
+	}
+
+	writeFile("dep/dep.go", depSrc("Deprecated"))
+	writeFile("use/use.go", `package use
+
+import "dep"
+
+func use() {
+	_ = dep.Foo{}
+	_ = dep.Bar{}
+}
+`)
+
+	config := Config{
+		Rules: Rules{
+			{
+				RuleName: "core/deprecated",
 			},
 		},
+		CacheDir: filepath.Join(gopath, "cache"),
+	}
+
+	lintLines := func() []int {
+		result, err := lint(config, []string{"dep"}, []string{filepath.Join(gopath, "src", "use")}, nil)
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		lines := []int{}
+		for _, diag := range result.Diagnostics {
+			lines = append(lines, diag.LineNumber())
+		}
+
+		return lines
+	}
+
+	// the first run stores the declarations of dep
+	if e, a := []int{6}, lintLines(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(config.CacheDir, "pkg", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 1, len(entries); e != a {
+		t.Fatalf("expected %v, but received %v", e, a)
+	}
+
+	// the second run loads them from the disk cache, which is shown by
+	// changing what the cache entry deprecates
+	b, err := ioutil.ReadFile(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "not a doc comment") {
+		t.Errorf("expected function bodies to not be cached")
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	file := entry["files"].([]interface{})[0].(map[string]interface{})
+	src, err := base64.StdEncoding.DecodeString(file["source"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src = []byte(strings.Replace(string(src), "// Bar is bar", "// Deprecated: use something else", 1))
+	file["source"] = src
+	if b, err = json.Marshal(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(entries[0], b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := []int{6, 7}, lintLines(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// changing dep will cause it to be parsed again
+	writeFile("dep/dep.go", depSrc("NotDeprecated"))
+	if e, a := []int{}, lintLines(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestPrintUnhandled(t *testing.T) {
+	buf := bytes.Buffer{}
+	printUnhandled(&buf, nil)
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be printed, but received %q", buf.String())
+	}
+
+	printUnhandled(&buf, []pepperlint.UnhandledNode{
+		{
+			Rule:  "core/deprecated",
+			Site:  "FieldRule.ValidateCallExpr",
+			Node:  "*ast.Ident",
+			Count: 12,
+			Pos:   token.Position{Filename: "foo.go", Line: 3, Column: 4},
+		},
 		{
-			name: "simple supression by filename and line",
-			suppressions: Suppressions{
+			Rule:  "core/deprecated",
+			Site:  "OpRule.getInternalTypeSpec",
+			Node:  "*ast.StarExpr",
+			Count: 1,
+		},
+	})
+
+	expected := `unhandled nodes:
+  12  core/deprecated  FieldRule.ValidateCallExpr  *ast.Ident     foo.go:3:4
+  1   core/deprecated  OpRule.getInternalTypeSpec  *ast.StarExpr  -
+`
+	if e, a := expected, buf.String(); e != a {
+		t.Errorf("expected\n%q\nbut received\n%q", e, a)
+	}
+}
+
+func TestLintUnhandledReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lintWithReport := func() []pepperlint.UnhandledNode {
+		report := pepperlint.NewUnhandledReport(nil)
+		config := Config{
+			Rules: Rules{
 				{
-					File: &File{
-						FilePath:   "foo.go",
-						LineNumber: &line,
-					},
-				},
-			},
-			errors: []error{
-				mockError{
-					filename: "bar.go",
-					line:     1,
-				},
-				mockError{
-					filename: "foo.go",
-					line:     5,
-				},
-				mockError{
-					filename: "bar.go",
-					line:     10,
-				},
-			},
-			expectedErrors: []error{
-				mockError{
-					filename: "bar.go",
-					line:     1,
-				},
-				mockError{
-					filename: "bar.go",
-					line:     10,
+					RuleName: "core/deprecated",
 				},
 			},
-		},
+			CacheDir: dir,
+			Logger:   report,
+			Relint:   true,
+		}
+
+		if _, err := lint(config, nil, []string{"./testdata/core"}, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		return report.Nodes()
 	}
 
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			errs := suppress(c.suppressions, c.errors)
+	// the second run would reuse the cached diagnostics if it were not relinted,
+	// and log nothing
+	cold := lintWithReport()
+	if len(cold) == 0 {
+		t.Fatalf("expected unhandled nodes")
+	}
 
-			if e, a := c.expectedErrors, errs; !reflect.DeepEqual(e, a) {
-				t.Errorf("expected %v, but received %v", e, a)
-			}
-		})
+	if e, a := cold, lintWithReport(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
 	}
 }
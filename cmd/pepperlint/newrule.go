@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+const newRuleUsage = `usage: pepperlint new-rule <namespace/name> [-nodes callexpr,assignstmt] [-dir rules] [-registry path] [-import-path path]
+
+new-rule creates the package of a new rule in dir/namespace/name, with a
+validate method for every node type, a test, and a testdata fixture for the
+test to lint. The package is registered by adding it to the registry file.
+
+Node types: %s
+`
+
+// nodeTypeNames are the go/ast types that rules can be validated against. Each
+// has a <Name>Node node type and a Validate<Name> method.
+var nodeTypeNames = []string{
+	"Package", "File",
+	"TypeSpec", "ValueSpec",
+	"GenDecl", "FuncDecl",
+	"CallExpr", "BinaryExpr",
+	"AssignStmt", "BlockStmt", "ReturnStmt", "IncDecStmt", "RangeStmt",
+	"StructType", "Field", "FieldList", "FuncType", "InterfaceType",
+	"ArrayType", "ChanType", "MapType",
+}
+
+var ruleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]*/[a-z][a-z0-9_-]*$`)
+
+// newRule contains what the templates of a new rule need.
+type newRule struct {
+	Name    string
+	Package string
+	Nodes   []string
+}
+
+// NodeTypes will return the node types of the rule as Go code.
+func (r newRule) NodeTypes() string {
+	types := []string{}
+	for _, node := range r.Nodes {
+		types = append(types, "pepperlint."+node+"Node")
+	}
+
+	return strings.Join(types, " | ")
+}
+
+// newRuleCommand will create the package of a new rule.
+func newRuleCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("new-rule", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		fmt.Fprintf(w, newRuleUsage, strings.ToLower(strings.Join(nodeTypeNames, ", ")))
+	}
+
+	nodes := fs.String("nodes", "file", "comma separated node types the rule validates")
+	dir := fs.String("dir", "rules", "directory of rule namespaces")
+	registry := fs.String("registry", filepath.Join("cmd", "pepperlint", "rules_registry.go"), "file that imports every rule")
+	importPath := fs.String("import-path", "", "import path of the new package, which is based on GOPATH if empty")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// the name can come before the flags
+	name := ""
+	if fs.NArg() > 0 {
+		name = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+
+	if len(name) == 0 || fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("expected a single rule name")
+	}
+
+	if !ruleNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid rule name %q, expected namespace/name", name)
+	}
+
+	rule := newRule{
+		Name:    name,
+		Package: strings.NewReplacer("-", "", "_", "").Replace(filepath.Base(name)),
+	}
+
+	// node types given more than once only get a single Validate method
+	seen := map[string]struct{}{}
+	for _, node := range strings.Split(*nodes, ",") {
+		typeName, ok := nodeTypeName(strings.TrimSpace(node))
+		if !ok {
+			return fmt.Errorf("unknown node type %q", node)
+		}
+
+		if _, ok := seen[typeName]; ok {
+			continue
+		}
+		seen[typeName] = struct{}{}
+
+		rule.Nodes = append(rule.Nodes, typeName)
+	}
+
+	pkgDir := filepath.Join(*dir, filepath.FromSlash(name))
+	if _, err := os.Stat(pkgDir); err == nil {
+		return fmt.Errorf("%s already exists", pkgDir)
+	}
+
+	if len(*importPath) == 0 {
+		abs, err := filepath.Abs(pkgDir)
+		if err != nil {
+			return err
+		}
+
+		*importPath = filepath.ToSlash(pepperlint.GetImportPathFromFullPath(abs))
+		if *importPath == filepath.ToSlash(abs) {
+			return fmt.Errorf("%s is not within GOPATH, use -import-path", pkgDir)
+		}
+	}
+
+	files := []struct {
+		name     string
+		template *template.Template
+	}{
+		{rule.Package + ".go", ruleTemplate},
+		{rule.Package + "_test.go", ruleTestTemplate},
+		{filepath.Join("testdata", "example.go"), ruleFixtureTemplate},
+	}
+
+	for _, file := range files {
+		buf := bytes.Buffer{}
+		if err := file.template.Execute(&buf, rule); err != nil {
+			return err
+		}
+
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("unable to format %s: %v", file.name, err)
+		}
+
+		filename := filepath.Join(pkgDir, file.name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(filename, src, 0644); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "created %s\n", filename)
+	}
+
+	if len(*registry) == 0 {
+		return nil
+	}
+
+	added, err := addRegistryImport(*registry, *importPath)
+	if err != nil {
+		return err
+	}
+
+	if added {
+		fmt.Fprintf(w, "registered %s in %s\n", *importPath, *registry)
+	}
+
+	return nil
+}
+
+// nodeTypeName will return the go/ast type name of node, which is matched
+// regardless of case.
+func nodeTypeName(node string) (string, bool) {
+	for _, name := range nodeTypeNames {
+		if strings.EqualFold(name, node) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// addRegistryImport will add a blank import of importPath to the registry file,
+// so the rule's init function registers it. False is returned if the file
+// already imports it.
+func addRegistryImport(filename, importPath string) (bool, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly)
+	if err != nil {
+		return false, err
+	}
+
+	for _, spec := range f.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == importPath {
+			return false, nil
+		}
+	}
+
+	line := "_ " + strconv.Quote(importPath)
+
+	// the import is added to the first import block, or after the package
+	// clause if there is none
+	offset := fset.Position(f.Name.End()).Offset
+	insert := "\n\nimport " + line + "\n"
+	if len(f.Imports) > 0 {
+		decl := f.Decls[0]
+		offset = fset.Position(decl.End()).Offset
+		insert = "\nimport " + line
+		if src[offset-1] == ')' {
+			offset--
+
+			insert = "\n" + line + "\n"
+			if src[offset-1] == '\n' {
+				insert = "\t" + line + "\n"
+			}
+		}
+	}
+
+	out := append(append(append([]byte{}, src[:offset]...), insert...), src[offset:]...)
+	if out, err = format.Source(out); err != nil {
+		return false, err
+	}
+
+	return true, ioutil.WriteFile(filename, out, 0644)
+}
+
+var ruleTemplate = template.Must(template.New("rule").Parse(`package {{.Package}}
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/go-toolset/pepperlint"
+	"github.com/go-toolset/pepperlint/rules"
+)
+
+// Rule is registered as {{printf "%q" .Name}}.
+//
+// TODO: describe what the rule reports.
+type Rule struct {
+	fset   *token.FileSet
+	helper pepperlint.Helper
+}
+
+// NewRule returns a new rule with the given token.FileSet
+func NewRule(fset *token.FileSet) *Rule {
+	return &Rule{
+		fset: fset,
+	}
+}
+{{range .Nodes}}
+// Validate{{.}} will validate every ast.{{.}}. Errors are reported with
+// pepperlint.NewErrorWrap, so they point at the node they are for.
+func (r *Rule) Validate{{.}}(node *ast.{{.}}) error {
+	// TODO: return pepperlint.NewErrorWrap(r.fset, node, "message")
+	return nil
+}
+{{end}}
+// NodeTypes satisfies the pepperlint.NodeFilter interface, so the rule is only
+// called for the node types it validates.
+func (r *Rule) NodeTypes() pepperlint.NodeType {
+	return {{.NodeTypes}}
+}
+
+// CopyRule satisfies the pepperlint.CopyRuler interface. Each package is linted
+// with its own copy of the rule.
+func (r *Rule) CopyRule() pepperlint.Rule {
+	return &Rule{}
+}
+
+// WithCache will create a new helper with the given cache, which is used to
+// look up declarations of the packages being linted and included.
+func (r *Rule) WithCache(cache *pepperlint.Cache) {
+	r.helper = pepperlint.NewHelper(cache)
+}
+
+// WithFileSet will set the token.FileSet to the rule, which is used for the
+// position of errors.
+func (r *Rule) WithFileSet(fset *token.FileSet) {
+	r.fset = fset
+}
+
+func init() {
+	rules.Add({{printf "%q" .Name}}, &Rule{})
+}
+`))
+
+var ruleTestTemplate = template.Must(template.New("test").Parse(`package {{.Package}}
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+func TestRule(t *testing.T) {
+	cases := []struct {
+		name                string
+		filename            string
+		expectedLineNumbers []int
+	}{
+		{
+			name:                "example",
+			filename:            filepath.Join("testdata", "example.go"),
+			expectedLineNumbers: []int{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			node, err := parser.ParseFile(fset, c.filename, nil, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			cache := pepperlint.NewCache()
+
+			// populate cache
+			ast.Walk(cache, node)
+
+			v := pepperlint.NewVisitor(fset, cache, NewRule(fset))
+			ast.Walk(v, node)
+
+			lines := []int{}
+			for _, diag := range v.Errors.Diagnostics() {
+				lines = append(lines, diag.Pos.Line)
+			}
+
+			if e, a := c.expectedLineNumbers, lines; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+`))
+
+var ruleFixtureTemplate = template.Must(template.New("fixture").Parse(`// Package example is linted by the tests of {{printf "%q" .Name}}. Add code
+// the rule should report, and the lines it is reported on to the test.
+package example
+
+import (
+	"fmt"
+)
+
+type Foo struct {
+	Bar    []string
+	Values map[string]int
+}
+
+func (f *Foo) Add(v string) error {
+	f.Bar = append(f.Bar, v)
+	f.Values[v]++
+
+	for _, b := range f.Bar {
+		if b == v {
+			return fmt.Errorf("%s already added", v)
+		}
+	}
+
+	return nil
+}
+`))
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRuleCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry := filepath.Join(dir, "rules_registry.go")
+	registrySrc := "package main\n\nimport (\n\t_ \"github.com/go-toolset/pepperlint/rules/aws\"\n)\n"
+	if err := ioutil.WriteFile(registry, []byte(registrySrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rulesDir := filepath.Join(dir, "rules")
+	args := []string{
+		"team/no-http",
+		"-nodes", "callexpr, AssignStmt",
+		"-dir", rulesDir,
+		"-registry", registry,
+		"-import-path", "example.com/rules/team/nohttp",
+	}
+
+	buf := bytes.Buffer{}
+	if err := newRuleCommand(args, &buf); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	pkgDir := filepath.Join(rulesDir, "team", "no-http")
+	expected := map[string][]string{
+		"nohttp.go": {
+			"package nohttp",
+			"func (r *Rule) ValidateCallExpr(node *ast.CallExpr) error {",
+			"func (r *Rule) ValidateAssignStmt(node *ast.AssignStmt) error {",
+			"return pepperlint.CallExprNode | pepperlint.AssignStmtNode",
+			`rules.Add("team/no-http", &Rule{})`,
+		},
+		"nohttp_test.go": {
+			"package nohttp",
+			`filepath.Join("testdata", "example.go")`,
+		},
+		filepath.Join("testdata", "example.go"): {
+			"package example",
+		},
+	}
+
+	for name, contents := range expected {
+		filename := filepath.Join(pkgDir, name)
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("expected %s to be created, but received %v", name, err)
+		}
+
+		if _, err := parser.ParseFile(token.NewFileSet(), filename, src, 0); err != nil {
+			t.Errorf("expected %s to parse, but received %v", name, err)
+		}
+
+		for _, s := range contents {
+			if !strings.Contains(string(src), s) {
+				t.Errorf("expected %q in %s", s, name)
+			}
+		}
+
+		if !strings.Contains(buf.String(), "created "+filename) {
+			t.Errorf("expected %s to be printed in %q", filename, buf.String())
+		}
+	}
+
+	src, err := ioutil.ReadFile(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := "import (\n\t_ \"example.com/rules/team/nohttp\"\n\t_ \"github.com/go-toolset/pepperlint/rules/aws\"\n)\n"
+	if a := string(src); !strings.Contains(a, e) {
+		t.Errorf("expected registry to contain\n%s\nbut received\n%s", e, a)
+	}
+
+	// the package already exists
+	if err := newRuleCommand(args, &buf); err == nil {
+		t.Errorf("expected error for existing rule")
+	}
+}
+
+func TestNewRuleCommandDuplicateNodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry := filepath.Join(dir, "rules_registry.go")
+	if err := ioutil.WriteFile(registry, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rulesDir := filepath.Join(dir, "rules")
+	args := []string{
+		"team/files",
+		"-nodes", "file,File, callexpr,file",
+		"-dir", rulesDir,
+		"-registry", registry,
+		"-import-path", "example.com/rules/team/files",
+	}
+
+	if err := newRuleCommand(args, &bytes.Buffer{}); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	src, err := ioutil.ReadFile(filepath.Join(rulesDir, "team", "files", "files.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, a := 1, strings.Count(string(src), "func (r *Rule) ValidateFile("); e != a {
+		t.Errorf("expected %d ValidateFile methods, but received %d", e, a)
+	}
+
+	if e, a := "return pepperlint.FileNode | pepperlint.CallExprNode", string(src); !strings.Contains(a, e) {
+		t.Errorf("expected %q in\n%s", e, a)
+	}
+}
+
+func TestNewRuleCommandErrors(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"norule"},
+		{"Team/Rule"},
+		{"team/rule", "-nodes", "unknown"},
+		{"team/rule", "extra"},
+	}
+
+	for _, args := range cases {
+		if err := newRuleCommand(append(args, "-dir", os.TempDir(), "-registry", ""), ioutil.Discard); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestAddRegistryImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		src      string
+		added    bool
+		expected string
+	}{
+		{
+			src:      "package main\n",
+			added:    true,
+			expected: "package main\n\nimport _ \"example.com/foo\"\n",
+		},
+		{
+			src:      "package main\n\nimport _ \"example.com/bar\"\n",
+			added:    true,
+			expected: "package main\n\nimport _ \"example.com/bar\"\nimport _ \"example.com/foo\"\n",
+		},
+		{
+			src:      "package main\n\nimport (\n\t_ \"example.com/foo\"\n)\n",
+			expected: "package main\n\nimport (\n\t_ \"example.com/foo\"\n)\n",
+		},
+	}
+
+	for _, c := range cases {
+		filename := filepath.Join(dir, "registry.go")
+		if err := ioutil.WriteFile(filename, []byte(c.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		added, err := addRegistryImport(filename, "example.com/foo")
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		if e, a := c.added, added; e != a {
+			t.Errorf("expected %t, but received %t", e, a)
+		}
+
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e, a := c.expected, string(src); e != a {
+			t.Errorf("expected\n%q\nbut received\n%q", e, a)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// printStats will write the stats of a run as a table of phases followed by a
+// table of rules.
+func printStats(w io.Writer, stats pepperlint.Stats) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "phase\ttime\tallocated\tallocs")
+	phases := []struct {
+		name  string
+		stats pepperlint.PhaseStats
+	}{
+		{"load", stats.Load},
+		{"cache", stats.Cache},
+		{"lint", stats.Lint},
+	}
+
+	for _, phase := range phases {
+		fmt.Fprintf(tw, "%s\t%v\t%s\t%d\n",
+			phase.name,
+			roundDuration(phase.stats.Time),
+			formatBytes(phase.stats.Bytes),
+			phase.stats.Allocs,
+		)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "rule\ttime\tnodes\tfindings")
+	for _, rule := range stats.Rules {
+		fmt.Fprintf(tw, "%s\t%v\t%d\t%d\n",
+			rule.Rule,
+			roundDuration(rule.Time),
+			rule.Nodes,
+			rule.Findings,
+		)
+	}
+
+	tw.Flush()
+}
+
+// writeStatsJSON will write the stats of a run as JSON to filename, or to
+// stdout if filename is "-".
+func writeStatsJSON(filename string, stats pepperlint.Stats) error {
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if filename == "-" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// roundDuration will round d to a precision that is still readable.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(time.Microsecond)
+	}
+
+	return d.Round(100 * time.Nanosecond)
+}
+
+// formatBytes will return n in the largest unit it is at least one of.
+func formatBytes(n uint64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+
+	v, unit := float64(n), 0
+	for v >= 1024 && unit < len(units)-1 {
+		v /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	return fmt.Sprintf("%.1f %s", v, units[unit])
+}
+
+// startProfiles will start a CPU profile if cpuProfile is set. The returned
+// function stops the CPU profile and writes a heap profile to memProfile if it
+// is set, which needs to be called before exiting for the profiles to be
+// complete.
+func startProfiles(cpuProfile, memProfile string) (func(), error) {
+	var cpu *os.File
+	if len(cpuProfile) > 0 {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		cpu = f
+	}
+
+	stopped := false
+	return func() {
+		if stopped {
+			return
+		}
+		stopped = true
+
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				log.Printf("unable to write CPU profile: %v", err)
+			}
+		}
+
+		if len(memProfile) > 0 {
+			if err := writeHeapProfile(memProfile); err != nil {
+				log.Printf("unable to write memory profile: %v", err)
+			}
+		}
+	}, nil
+}
+
+func writeHeapProfile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	// the profile is of the most recent garbage collection, which would not
+	// include the end of the run otherwise
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
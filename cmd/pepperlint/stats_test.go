@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+var testStats = pepperlint.Stats{
+	Load:  pepperlint.PhaseStats{Time: 1500 * time.Microsecond, Bytes: 3 << 20, Allocs: 100},
+	Cache: pepperlint.PhaseStats{Time: 2 * time.Second, Bytes: 512, Allocs: 4},
+	Lint:  pepperlint.PhaseStats{Time: 800 * time.Microsecond, Bytes: 1536, Allocs: 12},
+	Rules: []pepperlint.RuleStats{
+		{Rule: "core/deprecated", Time: 500 * time.Microsecond, Nodes: 42, Findings: 3},
+		{Rule: "team/sprintf", Time: 100 * time.Microsecond, Nodes: 7},
+	},
+}
+
+func TestPrintStats(t *testing.T) {
+	buf := bytes.Buffer{}
+	printStats(&buf, testStats)
+
+	expected := `phase  time   allocated  allocs
+load   1.5ms  3.0 MB     100
+cache  2s     512 B      4
+lint   800µs  1.5 KB     12
+
+rule             time   nodes  findings
+core/deprecated  500µs  42     3
+team/sprintf     100µs  7      0
+`
+	if e, a := expected, buf.String(); e != a {
+		t.Errorf("expected\n%s\nbut received\n%s", e, a)
+	}
+}
+
+func TestWriteStatsJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "stats.json")
+	if err := writeStatsJSON(filename, testStats); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := pepperlint.Stats{}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := testStats, stats; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestStartProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cpu, mem := filepath.Join(dir, "cpu.prof"), filepath.Join(dir, "mem.prof")
+	stop, err := startProfiles(cpu, mem)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	stop()
+	stop()
+
+	for _, filename := range []string{cpu, mem} {
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatalf("expected %s to be written, but received %v", filename, err)
+		}
+
+		if info.Size() == 0 {
+			t.Errorf("expected %s to not be empty", filename)
+		}
+	}
+
+	if _, err := startProfiles(filepath.Join(dir, "missing", "cpu.prof"), ""); err == nil {
+		t.Errorf("expected error")
+	}
+}
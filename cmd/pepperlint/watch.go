@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// watchInterval is how often the linted files are checked for changes.
+const watchInterval = 500 * time.Millisecond
+
+// watch will lint the patterns every time their files change until interrupted.
+// The diagnostics and a summary of every run are written to w, and the screen
+// is cleared before every run if w is a terminal.
+func watch(config Config, pkgs []string, patterns []string, w io.Writer) error {
+	opts, err := lintOptions(config, pkgs)
+	if err != nil {
+		return err
+	}
+	defer config.Plugins.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	linter, err := pepperlint.NewLinter(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	clear := isTerminal(w)
+	err = linter.Watch(ctx, patterns, watchInterval, func(result pepperlint.Result, err error) {
+		if clear {
+			fmt.Fprint(w, "\033[H\033[2J")
+		}
+
+		printWatchResult(w, result, err, time.Now())
+	})
+
+	if err == context.Canceled {
+		return nil
+	}
+
+	return err
+}
+
+// printWatchResult will write the diagnostics of result, and its stats if it
+// has any, followed by a summary of the run.
+func printWatchResult(w io.Writer, result pepperlint.Result, err error, now time.Time) {
+	if err != nil {
+		fmt.Fprintf(w, "[%s] unable to lint: %v\n", now.Format("15:04:05"), err)
+		return
+	}
+
+	fmt.Fprint(w, result.Errors())
+	if result.Stats != nil {
+		printStats(w, *result.Stats)
+	}
+
+	fmt.Fprintf(w, "[%s] %d diagnostics in %d packages, %d packages linted again\n",
+		now.Format("15:04:05"),
+		len(result.Diagnostics),
+		result.Packages,
+		result.Packages-result.CachedPackages,
+	)
+}
+
+// isTerminal will return true if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"go/token"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+func TestPrintWatchResult(t *testing.T) {
+	result := pepperlint.Result{
+		Diagnostics: []pepperlint.Diagnostic{
+			{
+				Pos: token.Position{Filename: "foo.go", Line: 3},
+				Err: pepperlint.NewErrorWrapAt(token.Position{Filename: "foo.go", Line: 3}, "deprecated"),
+			},
+		},
+		Packages:       4,
+		CachedPackages: 3,
+	}
+
+	buf := bytes.Buffer{}
+	printWatchResult(&buf, result, nil, time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	expected := "foo.go:3: deprecated\n[15:04:05] 1 diagnostics in 4 packages, 1 packages linted again\n"
+	if e, a := expected, buf.String(); e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	buf.Reset()
+	printWatchResult(&buf, pepperlint.Result{}, context.Canceled, time.Now())
+	if !strings.Contains(buf.String(), "unable to lint") {
+		t.Errorf("expected error to be printed, but received %q", buf.String())
+	}
+}
@@ -0,0 +1,69 @@
+package pepperlint
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Control values can be returned by any Validate* method to change how the
+// visitor continues traversal for the rule that returned them. Returning nil
+// continues as normal. Much like filepath.SkipDir, these are never reported
+// as lint errors.
+var (
+	// SkipChildren will prevent the rule from being called on any node nested
+	// within the node that was just validated. Other rules are not affected.
+	SkipChildren = errors.New("skip children")
+
+	// SkipFile will prevent the rule from being called on any remaining node
+	// in the current file.
+	SkipFile = errors.New("skip file")
+)
+
+// splitControl will separate a control value from the lint errors returned
+// by a rule. If a BatchError contains a control value, the control value
+// will be removed from the batch.
+func splitControl(err error) (error, error) {
+	switch err {
+	case nil:
+		return nil, nil
+	case SkipChildren, SkipFile:
+		return nil, err
+	}
+
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		return err, nil
+	}
+
+	var control error
+	errs := []error{}
+	for _, e := range batchErr.Errors() {
+		if e == SkipChildren || e == SkipFile {
+			// SkipFile takes precedence since it covers the children as
+			// well.
+			if control != SkipFile {
+				control = e
+			}
+			continue
+		}
+
+		errs = append(errs, e)
+	}
+
+	if control == nil {
+		return err, nil
+	}
+
+	return NewBatchError(errs...).Return(), control
+}
+
+// ruleKey returns a key that can be used to track the control state of a rule.
+// Rules whose dynamic type is not comparable, such as non-pointer structs with
+// map fields, cannot be tracked and false is returned.
+func ruleKey(rule interface{}) (interface{}, bool) {
+	if rule == nil || !reflect.TypeOf(rule).Comparable() {
+		return nil, false
+	}
+
+	return rule, true
+}
@@ -0,0 +1,132 @@
+package pepperlint
+
+import (
+	"go/token"
+	"sort"
+)
+
+// Diagnostic is a single lint error along with where it occurred and the name
+// of the rule that reported it.
+type Diagnostic struct {
+	Pos  token.Position
+	Rule string
+	Err  error
+
+	// End is where the node the error was reported for ends. It is only
+	// valid if the rule reported the error for a node.
+	End token.Position
+}
+
+func (d Diagnostic) Error() string {
+	return d.Err.Error()
+}
+
+// LineNumber returns the line number of where the error occurred
+func (d Diagnostic) LineNumber() int {
+	return d.Pos.Line
+}
+
+// Filename returns the filename of where the error occurred
+func (d Diagnostic) Filename() string {
+	return d.Pos.Filename
+}
+
+// Diagnostics will flatten any batch errors into a list of diagnostics that
+// is sorted by file, line, column, rule and message. Diagnostics with the same
+// rule, position and message are only returned once. This allows for output
+// that does not depend on the order in which packages, files or rules were
+// visited.
+func (e Errors) Diagnostics() []Diagnostic {
+	diags := []Diagnostic{}
+	for _, err := range e {
+		diags = flattenErrors(diags, "", err)
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].less(diags[j])
+	})
+
+	unique := diags[:0]
+	for i, d := range diags {
+		if i > 0 && d.equal(unique[len(unique)-1]) {
+			continue
+		}
+
+		unique = append(unique, d)
+	}
+
+	return unique
+}
+
+func flattenErrors(diags []Diagnostic, rule string, err error) []Diagnostic {
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		diag := Diagnostic{
+			Pos:  errorPosition(err),
+			Rule: rule,
+			Err:  err,
+		}
+
+		if e, ok := err.(interface {
+			End() token.Position
+		}); ok {
+			diag.End = e.End()
+		}
+
+		return append(diags, diag)
+	}
+
+	if len(batchErr.rule) > 0 {
+		rule = batchErr.rule
+	}
+
+	for _, e := range batchErr.Errors() {
+		diags = flattenErrors(diags, rule, e)
+	}
+
+	return diags
+}
+
+// errorPosition will return as much of the position of where an error occurred
+// as the error provides.
+func errorPosition(err error) token.Position {
+	switch e := err.(type) {
+	case interface {
+		Position() token.Position
+	}:
+		return e.Position()
+	case FileError:
+		return token.Position{
+			Filename: e.Filename(),
+			Line:     e.LineNumber(),
+		}
+	}
+
+	return token.Position{}
+}
+
+func (d Diagnostic) less(other Diagnostic) bool {
+	if d.Pos.Filename != other.Pos.Filename {
+		return d.Pos.Filename < other.Pos.Filename
+	}
+
+	if d.Pos.Line != other.Pos.Line {
+		return d.Pos.Line < other.Pos.Line
+	}
+
+	if d.Pos.Column != other.Pos.Column {
+		return d.Pos.Column < other.Pos.Column
+	}
+
+	if d.Rule != other.Rule {
+		return d.Rule < other.Rule
+	}
+
+	return d.Error() < other.Error()
+}
+
+func (d Diagnostic) equal(other Diagnostic) bool {
+	return d.Pos == other.Pos &&
+		d.Rule == other.Rule &&
+		d.Error() == other.Error()
+}
@@ -0,0 +1,141 @@
+package pepperlint
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+type testPositionError struct {
+	pos token.Position
+	msg string
+}
+
+func (e testPositionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.pos, e.msg)
+}
+
+func (e testPositionError) Position() token.Position {
+	return e.pos
+}
+
+func TestErrorsDiagnostics(t *testing.T) {
+	pos := func(filename string, line, column int) token.Position {
+		return token.Position{
+			Filename: filename,
+			Line:     line,
+			Column:   column,
+		}
+	}
+
+	cases := []struct {
+		name          string
+		errors        Errors
+		expectedDiags []string
+	}{
+		{
+			name:          "empty case",
+			expectedDiags: []string{},
+		},
+		{
+			name: "sort by file, line and column",
+			errors: Errors{
+				&BatchError{
+					rule: "a",
+					errors: []error{
+						testPositionError{pos("b.go", 1, 1), "msg"},
+						testPositionError{pos("a.go", 2, 1), "msg"},
+						testPositionError{pos("a.go", 1, 5), "msg"},
+						testPositionError{pos("a.go", 1, 2), "msg"},
+					},
+				},
+			},
+			expectedDiags: []string{
+				"a a.go:1:2: msg",
+				"a a.go:1:5: msg",
+				"a a.go:2:1: msg",
+				"a b.go:1:1: msg",
+			},
+		},
+		{
+			name: "sort by rule and message",
+			errors: Errors{
+				NewBatchError(
+					&BatchError{
+						rule: "b",
+						errors: []error{
+							testPositionError{pos("a.go", 1, 1), "msg"},
+						},
+					},
+					&BatchError{
+						rule: "a",
+						errors: []error{
+							testPositionError{pos("a.go", 1, 1), "msg 2"},
+							testPositionError{pos("a.go", 1, 1), "msg 1"},
+						},
+					},
+				),
+			},
+			expectedDiags: []string{
+				"a a.go:1:1: msg 1",
+				"a a.go:1:1: msg 2",
+				"b a.go:1:1: msg",
+			},
+		},
+		{
+			name: "duplicates",
+			errors: Errors{
+				&BatchError{
+					rule: "a",
+					errors: []error{
+						testPositionError{pos("a.go", 1, 1), "msg"},
+						testPositionError{pos("a.go", 1, 1), "msg"},
+					},
+				},
+				&BatchError{
+					rule: "a",
+					errors: []error{
+						testPositionError{pos("a.go", 1, 1), "msg"},
+					},
+				},
+				&BatchError{
+					rule: "b",
+					errors: []error{
+						testPositionError{pos("a.go", 1, 1), "msg"},
+					},
+				},
+			},
+			expectedDiags: []string{
+				"a a.go:1:1: msg",
+				"b a.go:1:1: msg",
+			},
+		},
+		{
+			name: "errors without positions",
+			errors: Errors{
+				fmt.Errorf("b"),
+				testPositionError{pos("a.go", 1, 1), "msg"},
+				fmt.Errorf("a"),
+			},
+			expectedDiags: []string{
+				" a",
+				" b",
+				" a.go:1:1: msg",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			diags := []string{}
+			for _, diag := range c.errors.Diagnostics() {
+				diags = append(diags, fmt.Sprintf("%s %v", diag.Rule, diag))
+			}
+
+			if e, a := c.expectedDiags, diags; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package pepperlint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DiskCache stores the declarations of packages on disk, so packages that have
+// not changed since they were last loaded do not need to be parsed in full.
+//
+// Only what the Cache needs is stored, which is every declaration of a package
+// along with its doc comments and the imports of each file. Function bodies
+// and the comments within them are blanked out, while everything else is kept
+// where it was, so declarations loaded from the cache have the same positions
+// as when the package is parsed in full. Entries are keyed by a hash of the
+// package's files and the version of pepperlint, so any change to either will
+// cause the package to be parsed again.
+type DiskCache struct {
+	Dir string
+}
+
+// NewDiskCache returns a disk cache that stores entries within dir.
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{
+		Dir: dir,
+	}
+}
+
+// DefaultDiskCacheDir returns the directory the disk cache is stored in by
+// default, which is $XDG_CACHE_HOME/pepperlint. If XDG_CACHE_HOME is not set,
+// $HOME/.cache is used instead.
+func DefaultDiskCacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); len(dir) > 0 {
+		return filepath.Join(dir, "pepperlint"), nil
+	}
+
+	home := os.Getenv("HOME")
+	if len(home) == 0 {
+		return "", fmt.Errorf("neither XDG_CACHE_HOME nor HOME are set")
+	}
+
+	return filepath.Join(home, ".cache", "pepperlint"), nil
+}
+
+// diskCacheEntry is what is stored on disk for a single package directory.
+type diskCacheEntry struct {
+	Version string          `json:"version"`
+	Files   []diskCacheFile `json:"files"`
+}
+
+// diskCacheFile contains the declarations of a single file as go source, along
+// with the filename the file was parsed from.
+type diskCacheFile struct {
+	Filename string `json:"filename"`
+	Source   []byte `json:"source"`
+}
+
+// key will return the key of the entry for the given files, which must be
+// sorted. srcs contains the contents of each file.
+func (c *DiskCache) key(filenames []string, srcs [][]byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "pepperlint %s\n", Version)
+
+	for i, filename := range filenames {
+		fmt.Fprintf(h, "%s %d\n", filepath.Base(filename), len(srcs[i]))
+		h.Write(srcs[i])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.Dir, "pkg", key[:2], key)
+}
+
+// get will return the entry of key. False is returned if there is no entry, or
+// it could not be read.
+func (c *DiskCache) get(key string, logger Logger) (diskCacheEntry, bool) {
+	b, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return diskCacheEntry{}, false
+	}
+
+	entry := diskCacheEntry{}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		logger.Log(WarnLevel, fmt.Sprintf("unable to read disk cache entry %s: %v", key, err))
+		return diskCacheEntry{}, false
+	}
+
+	if entry.Version != Version {
+		return diskCacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+// put will store the entry under key.
+func (c *DiskCache) put(key string, entry diskCacheEntry) error {
+	entry.Version = Version
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return c.write(c.path(key), b)
+}
+
+// write will write b to path. b is written to a temporary file first, so
+// concurrent runs never read a partially written entry.
+func (c *DiskCache) write(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// addIncludeDir will add the declarations of the packages of an included
+// directory, which are parsed without function bodies. If the set has a disk
+// cache, the declarations are loaded from it when the files have not changed,
+// and stored in it otherwise.
+func (set *packageSet) addIncludeDir(fset *token.FileSet, p []dirPackages, dir string) []dirPackages {
+	batchErr := NewRuleBatchError(ParseErrorRule)
+	filenames, err := set.dirFiles(dir)
+	if err != nil {
+		batchErr.Add(err)
+	}
+
+	srcs := make([][]byte, 0, len(filenames))
+	for _, filename := range filenames {
+		src, err := set.readFile(filename)
+		if err != nil {
+			batchErr.Add(parseErrors(filename, err))
+			src = nil
+		}
+
+		srcs = append(srcs, src)
+	}
+
+	key := ""
+	if set.diskCache != nil && batchErr.Len() == 0 {
+		key = set.diskCache.key(filenames, srcs)
+		if entry, ok := set.diskCache.get(key, set.logger); ok {
+			if pkgs, ok := set.parseDiskCacheEntry(fset, entry); ok {
+				return append(p, newIncludedPackages(dir, pkgs))
+			}
+		}
+	}
+
+	pkgs := map[string]*ast.Package{}
+	entry := diskCacheEntry{}
+	for i, filename := range filenames {
+		if srcs[i] == nil {
+			continue
+		}
+
+		f, err := parseDeclarations(fset, filename, srcs[i])
+		if err != nil {
+			batchErr.Add(parseErrors(filename, err))
+			continue
+		}
+
+		addPackageFile(pkgs, filename, f)
+
+		if set.diskCache == nil {
+			continue
+		}
+
+		// included files are hashed by their declarations, which keeps the
+		// hash the same when the file is loaded from the disk cache
+		src := declarationSource(fset, f, srcs[i])
+		set.hashes[f] = declarationHash(src)
+
+		entry.Files = append(entry.Files, diskCacheFile{
+			Filename: filename,
+			Source:   src,
+		})
+	}
+
+	// only directories without errors are stored, so errors are always
+	// reported
+	if batchErr.Len() > 0 {
+		set.errs.Add(batchErr)
+	} else if set.diskCache != nil {
+		if err := set.diskCache.put(key, entry); err != nil {
+			set.logger.Log(WarnLevel, fmt.Sprintf("unable to write disk cache entry for %s: %v", dir, err))
+		}
+	}
+
+	return append(p, newIncludedPackages(dir, pkgs))
+}
+
+// newIncludedPackages will resolve the declarations of every package of an
+// included directory.
+func newIncludedPackages(dir string, pkgs map[string]*ast.Package) dirPackages {
+	for _, pkg := range pkgs {
+		resolveDeclarations(pkg)
+	}
+
+	return dirPackages{
+		path: dir,
+		pkgs: pkgs,
+	}
+}
+
+// parseDiskCacheEntry will parse the declarations of every file in the entry.
+// False is returned if any of them could not be parsed.
+func (set *packageSet) parseDiskCacheEntry(fset *token.FileSet, entry diskCacheEntry) (map[string]*ast.Package, bool) {
+	pkgs := map[string]*ast.Package{}
+	for _, file := range entry.Files {
+		f, err := parser.ParseFile(fset, file.Filename, file.Source, declarationParseMode)
+		if err != nil {
+			set.logger.Log(WarnLevel, fmt.Sprintf("unable to parse disk cache entry of %s: %v", file.Filename, err))
+			return nil, false
+		}
+
+		set.hashes[f] = declarationHash(file.Source)
+
+		addPackageFile(pkgs, file.Filename, f)
+	}
+
+	return pkgs, true
+}
+
+// readFile will return the contents of filename from the overlay if it was
+// overlaid, and from disk otherwise.
+func (set *packageSet) readFile(filename string) ([]byte, error) {
+	if src, ok := set.overlay.source(filename); ok {
+		return src, nil
+	}
+
+	return ioutil.ReadFile(filename)
+}
+
+// declarationSource will return src, the source of f, with the contents of
+// every function body replaced by spaces. Newlines are kept, so every
+// declaration and doc comment keeps the filename, line, column and offset it
+// has in the original file.
+func declarationSource(fset *token.FileSet, f *ast.File, src []byte) []byte {
+	decls := make([]byte, len(src))
+	copy(decls, src)
+
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		start := fset.Position(fn.Body.Lbrace).Offset + 1
+		end := fset.Position(fn.Body.Rbrace).Offset
+		for i := start; i < end; i++ {
+			if decls[i] != '\n' {
+				decls[i] = ' '
+			}
+		}
+	}
+
+	return decls
+}
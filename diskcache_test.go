@@ -0,0 +1,101 @@
+package pepperlint
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeclarationSource(t *testing.T) {
+	src := `// Package foo is foo
+package foo
+
+import "fmt"
+
+// Foo is foo
+//
+// Deprecated: use Bar
+type Foo struct {
+	// A is a
+	A int
+}
+
+// Bar does bar
+func (f Foo) Bar() {
+	// inside the body
+	fmt.Println("bar")
+}
+
+/* trailing */
+var baz = func() int {
+	return 1
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "foo.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	decls := declarationSource(fset, f, []byte(src))
+
+	for _, s := range []string{"// Package foo is foo", "// Deprecated: use Bar", "// A is a", "// Bar does bar", "func (f Foo) Bar() {\n", `import "fmt"`, "/* trailing */"} {
+		if !strings.Contains(string(decls), s) {
+			t.Errorf("expected %q in %s", s, decls)
+		}
+	}
+
+	for _, s := range []string{"inside the body", "Println"} {
+		if strings.Contains(string(decls), s) {
+			t.Errorf("expected no %q in %s", s, decls)
+		}
+	}
+
+	declsFset := token.NewFileSet()
+	declsFile, err := parser.ParseFile(declsFset, "foo.go", decls, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("expected declarations to parse, but received %v", err)
+	}
+
+	// every declaration is where it is in the original file
+	if e, a := len(f.Decls), len(declsFile.Decls); e != a {
+		t.Fatalf("expected %d, but received %d", e, a)
+	}
+
+	for i, decl := range f.Decls {
+		if e, a := fset.Position(decl.Pos()), declsFset.Position(declsFile.Decls[i].Pos()); e != a {
+			t.Errorf("%d: expected %v, but received %v", i, e, a)
+		}
+	}
+}
+
+func TestDefaultDiskCacheDir(t *testing.T) {
+	defer os.Setenv("XDG_CACHE_HOME", os.Getenv("XDG_CACHE_HOME"))
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+
+	os.Setenv("XDG_CACHE_HOME", filepath.FromSlash("/xdg"))
+	os.Setenv("HOME", filepath.FromSlash("/home"))
+
+	dir, err := DefaultDiskCacheDir()
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := filepath.FromSlash("/xdg/pepperlint"), dir; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	os.Setenv("XDG_CACHE_HOME", "")
+	dir, err = DefaultDiskCacheDir()
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := filepath.FromSlash("/home/.cache/pepperlint"), dir; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
@@ -0,0 +1,572 @@
+package pepperlint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math/bits"
+	"runtime/debug"
+	"time"
+)
+
+// dispatch is built once per visitor and contains what is needed to send a
+// node to its rules without any allocations when nothing is reported.
+type dispatch struct {
+	// nodeTypes is the set of node types that have at least one rule.
+	nodeTypes NodeType
+
+	// keys holds the key used to track the control values of each rule. It
+	// is indexed by node type and then by the index of the rule in its list.
+	// A nil key means the rule's control values cannot be tracked.
+	keys [numNodeTypes][]interface{}
+
+	// names holds the name of each rule, indexed the same way as keys.
+	names [numNodeTypes][]string
+
+	// trackable is the number of distinct rules that have a key and total
+	// is the number of distinct rules overall.
+	trackable int
+	total     int
+}
+
+func newDispatch(rules Rules) dispatch {
+	d := dispatch{
+		nodeTypes: rules.NodeTypes(),
+	}
+
+	seen := map[interface{}]struct{}{}
+	rules.eachList(func(t NodeType, list []Rule) {
+		keys := make([]interface{}, len(list))
+		names := make([]string, len(list))
+		for i, rule := range list {
+			names[i] = ruleName(rule)
+
+			key, ok := ruleKey(rule)
+			if !ok {
+				// rules that cannot be compared are always distinct
+				d.total++
+				continue
+			}
+
+			keys[i] = key
+			seen[key] = struct{}{}
+		}
+
+		d.keys[nodeTypeIndex(t)] = keys
+		d.names[nodeTypeIndex(t)] = names
+	})
+	d.trackable = len(seen)
+	d.total += len(seen)
+
+	return d
+}
+
+// ruleName will return the name of the rule that is attached to its errors.
+func ruleName(rule Rule) string {
+	if namer, ok := rule.(RuleNamer); ok {
+		return namer.RuleName()
+	}
+
+	return fmt.Sprintf("%T", rule)
+}
+
+func nodeTypeIndex(t NodeType) int {
+	return bits.TrailingZeros32(uint32(t))
+}
+
+// skipping will return whether the rule at index i of the node type's rule
+// list has asked to skip the node currently being visited.
+func (v *Visitor) skipping(t NodeType, i int) bool {
+	if len(v.skipped) == 0 {
+		return false
+	}
+
+	return v.skippingKey(v.dispatch.keys[nodeTypeIndex(t)][i])
+}
+
+func (v *Visitor) skippingKey(key interface{}) bool {
+	if key == nil {
+		return false
+	}
+
+	depth, ok := v.skipped[key]
+	if !ok {
+		return false
+	}
+
+	if v.depth > depth {
+		return true
+	}
+
+	// the subtree that was skipped has been left
+	delete(v.skipped, key)
+	return false
+}
+
+// skippingAll will return true if every rule is skipping the node currently
+// being visited, in which case there is no need to walk its children.
+func (v *Visitor) skippingAll() bool {
+	// a rule that cannot be tracked can never be skipped
+	if len(v.skipped) < v.dispatch.total {
+		return false
+	}
+
+	for key := range v.skipped {
+		if !v.skippingKey(key) {
+			return false
+		}
+	}
+
+	return len(v.skipped) == v.dispatch.total
+}
+
+// leave will end the visit of the node at the current depth, along with any
+// subtree rules asked to skip from it. ast.Walk only calls Visit with nil for
+// nodes whose children were walked, so it is called directly otherwise.
+func (v *Visitor) leave() {
+	v.unskip(v.depth)
+	v.depth--
+}
+
+// unskip will remove the control values that were returned at depth or
+// deeper.
+func (v *Visitor) unskip(depth int) {
+	if len(v.skipped) == 0 {
+		return
+	}
+
+	for key, d := range v.skipped {
+		if d >= depth {
+			delete(v.skipped, key)
+		}
+	}
+}
+
+// call will call fn, which validates node with the rule at index i of the
+// node type's rule list. The call is timed if stats are being collected.
+func (v *Visitor) call(t NodeType, i int, node ast.Node, fn func() error) error {
+	if v.stats == nil {
+		return v.protect(t, i, node, fn)
+	}
+
+	start := time.Now()
+	err := v.protect(t, i, node, fn)
+	v.stats.record(v.dispatch.names[nodeTypeIndex(t)][i], time.Since(start), err)
+
+	return err
+}
+
+// protect will call fn. If the rule panics, the panic is recovered and
+// returned as an InternalError and its stack is logged. The rule is then
+// skipped for the remainder of the file, as its state can no longer be
+// trusted, and validates again from the next file.
+func (v *Visitor) protect(t NodeType, i int, node ast.Node, fn func() error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		var pos token.Position
+		if v.FileSet != nil && node != nil {
+			pos = v.FileSet.Position(node.Pos())
+		}
+
+		internalErr := &InternalError{
+			Rule:  v.dispatch.names[nodeTypeIndex(t)][i],
+			Pos:   pos,
+			Value: r,
+			Stack: debug.Stack(),
+		}
+
+		if logger := loggerOrDefault(v.logger); logger.Enabled(DebugLevel) {
+			logger.Log(DebugLevel, fmt.Sprintf("rule panicked: %v", r),
+				RuleField(internalErr.Rule),
+				PosField(pos),
+				Field{Key: "stack", Value: string(internalErr.Stack)},
+			)
+		}
+
+		err = NewBatchError(internalErr, SkipFile)
+	}()
+
+	return fn()
+}
+
+// validated will handle the error returned by the rule at index i of the node
+// type's rule list. Control values are recorded against the rule and any lint
+// errors are added to the batch, which is only allocated once an error has
+// been found.
+func (v *Visitor) validated(t NodeType, i int, err error, batchError *BatchError) *BatchError {
+	if err == nil {
+		return batchError
+	}
+
+	err, control := splitControl(err)
+	if err != nil {
+		if batchError == nil {
+			batchError = NewBatchError()
+		}
+
+		batchError.Add(v.named(t, i, err))
+	}
+
+	if control == nil {
+		return batchError
+	}
+
+	key := v.dispatch.keys[nodeTypeIndex(t)][i]
+	if key == nil {
+		loggerOrDefault(v.logger).Log(WarnLevel, fmt.Sprintf("rule is not comparable and cannot return %v", control),
+			RuleField(v.dispatch.names[nodeTypeIndex(t)][i]),
+		)
+		return batchError
+	}
+
+	switch control {
+	case SkipChildren:
+		v.skipped[key] = v.depth
+	case SkipFile:
+		v.skipped[key] = v.fileDepth
+	}
+
+	return batchError
+}
+
+// named will attach the name of the rule at index i of the node type's rule
+// list to the errors it returned.
+func (v *Visitor) named(t NodeType, i int, err error) error {
+	name := v.dispatch.names[nodeTypeIndex(t)][i]
+
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		return NewRuleBatchError(name, err)
+	}
+
+	if len(batchErr.rule) == 0 {
+		batchErr.rule = name
+	}
+
+	return batchErr
+}
+
+func (v *Visitor) addErrors(batchError *BatchError) {
+	if batchError != nil {
+		v.Errors.Add(batchError)
+	}
+}
+
+func (v *Visitor) validatePackage(pkg *ast.Package) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.PackageRules {
+		if v.skipping(PackageNode, i) {
+			continue
+		}
+
+		err := v.call(PackageNode, i, pkg, func() error {
+			return rule.ValidatePackage(pkg)
+		})
+		batchError = v.validated(PackageNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateFile(f *ast.File) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.FileRules {
+		if v.skipping(FileNode, i) {
+			continue
+		}
+
+		err := v.call(FileNode, i, f, func() error {
+			return rule.ValidateFile(f)
+		})
+		batchError = v.validated(FileNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateTypeSpec(spec *ast.TypeSpec) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.TypeSpecRules {
+		if v.skipping(TypeSpecNode, i) {
+			continue
+		}
+
+		err := v.call(TypeSpecNode, i, spec, func() error {
+			return rule.ValidateTypeSpec(spec)
+		})
+		batchError = v.validated(TypeSpecNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateValueSpec(spec *ast.ValueSpec) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.ValueSpecRules {
+		if v.skipping(ValueSpecNode, i) {
+			continue
+		}
+
+		err := v.call(ValueSpecNode, i, spec, func() error {
+			return rule.ValidateValueSpec(spec)
+		})
+		batchError = v.validated(ValueSpecNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateGenDecl(decl *ast.GenDecl) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.GenDeclRules {
+		if v.skipping(GenDeclNode, i) {
+			continue
+		}
+
+		err := v.call(GenDeclNode, i, decl, func() error {
+			return rule.ValidateGenDecl(decl)
+		})
+		batchError = v.validated(GenDeclNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateFuncDecl(fnDecl *ast.FuncDecl) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.FuncDeclRules {
+		if v.skipping(FuncDeclNode, i) {
+			continue
+		}
+
+		err := v.call(FuncDeclNode, i, fnDecl, func() error {
+			return rule.ValidateFuncDecl(fnDecl)
+		})
+		batchError = v.validated(FuncDeclNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateCallExpr(expr *ast.CallExpr) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.CallExprRules {
+		if v.skipping(CallExprNode, i) {
+			continue
+		}
+
+		err := v.call(CallExprNode, i, expr, func() error {
+			return rule.ValidateCallExpr(expr)
+		})
+		batchError = v.validated(CallExprNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateBinaryExpr(expr *ast.BinaryExpr) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.BinaryExprRules {
+		if v.skipping(BinaryExprNode, i) {
+			continue
+		}
+
+		err := v.call(BinaryExprNode, i, expr, func() error {
+			return rule.ValidateBinaryExpr(expr)
+		})
+		batchError = v.validated(BinaryExprNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateAssignStmt(stmt *ast.AssignStmt) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.AssignStmtRules {
+		if v.skipping(AssignStmtNode, i) {
+			continue
+		}
+
+		err := v.call(AssignStmtNode, i, stmt, func() error {
+			return rule.ValidateAssignStmt(stmt)
+		})
+		batchError = v.validated(AssignStmtNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateBlockStmt(stmt *ast.BlockStmt) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.BlockStmtRules {
+		if v.skipping(BlockStmtNode, i) {
+			continue
+		}
+
+		err := v.call(BlockStmtNode, i, stmt, func() error {
+			return rule.ValidateBlockStmt(stmt)
+		})
+		batchError = v.validated(BlockStmtNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateReturnStmt(stmt *ast.ReturnStmt) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.ReturnStmtRules {
+		if v.skipping(ReturnStmtNode, i) {
+			continue
+		}
+
+		err := v.call(ReturnStmtNode, i, stmt, func() error {
+			return rule.ValidateReturnStmt(stmt)
+		})
+		batchError = v.validated(ReturnStmtNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateIncDecStmt(stmt *ast.IncDecStmt) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.IncDecStmtRules {
+		if v.skipping(IncDecStmtNode, i) {
+			continue
+		}
+
+		err := v.call(IncDecStmtNode, i, stmt, func() error {
+			return rule.ValidateIncDecStmt(stmt)
+		})
+		batchError = v.validated(IncDecStmtNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateRangeStmt(stmt *ast.RangeStmt) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.RangeStmtRules {
+		if v.skipping(RangeStmtNode, i) {
+			continue
+		}
+
+		err := v.call(RangeStmtNode, i, stmt, func() error {
+			return rule.ValidateRangeStmt(stmt)
+		})
+		batchError = v.validated(RangeStmtNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateStructType(s *ast.StructType) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.StructTypeRules {
+		if v.skipping(StructTypeNode, i) {
+			continue
+		}
+
+		err := v.call(StructTypeNode, i, s, func() error {
+			return rule.ValidateStructType(s)
+		})
+		batchError = v.validated(StructTypeNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateField(field *ast.Field) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.FieldRules {
+		if v.skipping(FieldNode, i) {
+			continue
+		}
+
+		err := v.call(FieldNode, i, field, func() error {
+			return rule.ValidateField(field)
+		})
+		batchError = v.validated(FieldNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateFieldList(fields *ast.FieldList) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.FieldListRules {
+		if v.skipping(FieldListNode, i) {
+			continue
+		}
+
+		err := v.call(FieldListNode, i, fields, func() error {
+			return rule.ValidateFieldList(fields)
+		})
+		batchError = v.validated(FieldListNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateFuncType(fn *ast.FuncType) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.FuncTypeRules {
+		if v.skipping(FuncTypeNode, i) {
+			continue
+		}
+
+		err := v.call(FuncTypeNode, i, fn, func() error {
+			return rule.ValidateFuncType(fn)
+		})
+		batchError = v.validated(FuncTypeNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateInterfaceType(iface *ast.InterfaceType) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.InterfaceTypeRules {
+		if v.skipping(InterfaceTypeNode, i) {
+			continue
+		}
+
+		err := v.call(InterfaceTypeNode, i, iface, func() error {
+			return rule.ValidateInterfaceType(iface)
+		})
+		batchError = v.validated(InterfaceTypeNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateArrayType(array *ast.ArrayType) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.ArrayTypeRules {
+		if v.skipping(ArrayTypeNode, i) {
+			continue
+		}
+
+		err := v.call(ArrayTypeNode, i, array, func() error {
+			return rule.ValidateArrayType(array)
+		})
+		batchError = v.validated(ArrayTypeNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateChanType(ch *ast.ChanType) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.ChanTypeRules {
+		if v.skipping(ChanTypeNode, i) {
+			continue
+		}
+
+		err := v.call(ChanTypeNode, i, ch, func() error {
+			return rule.ValidateChanType(ch)
+		})
+		batchError = v.validated(ChanTypeNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
+
+func (v *Visitor) validateMapType(m *ast.MapType) {
+	var batchError *BatchError
+	for i, rule := range v.Rules.MapTypeRules {
+		if v.skipping(MapTypeNode, i) {
+			continue
+		}
+
+		err := v.call(MapTypeNode, i, m, func() error {
+			return rule.ValidateMapType(m)
+		})
+		batchError = v.validated(MapTypeNode, i, err, batchError)
+	}
+	v.addErrors(batchError)
+}
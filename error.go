@@ -31,8 +31,10 @@ type Filename interface {
 // found in.
 type ErrorWrap struct {
 	pos    token.Position
+	end    token.Position
 	prefix string
 	msg    string
+	fixes  []SuggestedFix
 }
 
 // NewErrorWrap will return a new error and construct a prefix based on the node
@@ -44,6 +46,7 @@ func NewErrorWrap(fset *token.FileSet, node ast.Node, msg string) *ErrorWrap {
 
 	return &ErrorWrap{
 		pos:    pos,
+		end:    fset.Position(node.End()),
 		prefix: prefix,
 		msg:    msg,
 	}
@@ -63,10 +66,76 @@ func (e *ErrorWrap) Filename() string {
 	return e.pos.Filename
 }
 
+// Position will return the full position of where the error occurred
+func (e *ErrorWrap) Position() token.Position {
+	return e.pos
+}
+
+// End will return the position of where the node the error was reported for
+// ends. The position is not valid if the error was not reported for a node.
+func (e *ErrorWrap) End() token.Position {
+	return e.end
+}
+
+// WithEnd will set where the error ends, for errors that are not reported for
+// a node. The error is returned, so it can be chained with NewErrorWrapAt.
+func (e *ErrorWrap) WithEnd(end token.Position) *ErrorWrap {
+	e.end = end
+	return e
+}
+
+// Message will return the error message without the position prefix
+func (e *ErrorWrap) Message() string {
+	return e.msg
+}
+
+// NewErrorWrapAt will return a new error for the position provided. This is
+// useful when an error is not tied to an ast.Node, such as parse errors.
+func NewErrorWrapAt(pos token.Position, msg string) *ErrorWrap {
+	return &ErrorWrap{
+		pos:    pos,
+		prefix: pos.String(),
+		msg:    msg,
+	}
+}
+
+// InternalError is reported in place of any errors when a rule panics while
+// validating a node.
+type InternalError struct {
+	Rule  string
+	Pos   token.Position
+	Value interface{}
+
+	// Stack is the stack of the goroutine the rule panicked on. It is left out
+	// of the message, which needs to be the same from run to run, and is only
+	// logged.
+	Stack []byte
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("%s: internal error: rule %s panicked: %v", e.Pos, e.Rule, e.Value)
+}
+
+// LineNumber return the line number of the node the rule panicked on
+func (e *InternalError) LineNumber() int {
+	return e.Pos.Line
+}
+
+// Filename will return the filename of the node the rule panicked on
+func (e *InternalError) Filename() string {
+	return e.Pos.Filename
+}
+
+// Position will return the full position of the node the rule panicked on
+func (e *InternalError) Position() token.Position {
+	return e.Pos
+}
+
 // BatchError groups a set of errors together usually to organize
 // them by Validator but is not limited to.
 type BatchError struct {
 	errors []error
+	rule   string
 }
 
 // NewBatchError returns a new BatchError
@@ -76,6 +145,15 @@ func NewBatchError(errs ...error) *BatchError {
 	}
 }
 
+// NewRuleBatchError returns a new BatchError of errors that were reported by
+// the named rule.
+func NewRuleBatchError(rule string, errs ...error) *BatchError {
+	return &BatchError{
+		errors: errs,
+		rule:   rule,
+	}
+}
+
 // Add will add a new error to the BatchError
 func (e *BatchError) Add(errs ...error) {
 	e.errors = append(e.errors, errs...)
@@ -86,6 +164,12 @@ func (e *BatchError) Errors() []error {
 	return e.errors
 }
 
+// Rule returns the name of the rule that reported the errors. An empty string
+// is returned if the errors were not reported by a single rule.
+func (e *BatchError) Rule() string {
+	return e.rule
+}
+
 // Len returns the length of the errors contained in the BatchError
 func (e *BatchError) Len() int {
 	return len(e.errors)
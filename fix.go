@@ -0,0 +1,57 @@
+package pepperlint
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// TextEdit replaces the text between Pos and End with NewText. Pos and End are
+// equal for edits that only insert text.
+type TextEdit struct {
+	Pos     token.Position `json:"pos"`
+	End     token.Position `json:"end"`
+	NewText string         `json:"new_text"`
+}
+
+// NewTextEdit will return an edit that replaces node with newText.
+func NewTextEdit(fset *token.FileSet, node ast.Node, newText string) TextEdit {
+	return TextEdit{
+		Pos:     fset.Position(node.Pos()),
+		End:     fset.Position(node.End()),
+		NewText: newText,
+	}
+}
+
+// SuggestedFix is a set of edits that will fix a diagnostic. Message describes
+// the fix, such as "replace Foo with Bar".
+type SuggestedFix struct {
+	Message string     `json:"message"`
+	Edits   []TextEdit `json:"edits"`
+}
+
+// Fixer is implemented by errors that suggest fixes for themselves.
+type Fixer interface {
+	Fixes() []SuggestedFix
+}
+
+// WithFix will add fix to the fixes suggested by the error. The error is
+// returned, so it can be chained with NewErrorWrap.
+func (e *ErrorWrap) WithFix(fix SuggestedFix) *ErrorWrap {
+	e.fixes = append(e.fixes, fix)
+	return e
+}
+
+// Fixes will return the fixes suggested for the error.
+func (e *ErrorWrap) Fixes() []SuggestedFix {
+	return e.fixes
+}
+
+// Fixes will return the fixes suggested by the rule that reported the
+// diagnostic, if any.
+func (d Diagnostic) Fixes() []SuggestedFix {
+	if fixer, ok := d.Err.(Fixer); ok {
+		return fixer.Fixes()
+	}
+
+	return nil
+}
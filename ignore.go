@@ -0,0 +1,154 @@
+package pepperlint
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// IgnoreDirective is the comment that suppresses diagnostics inline. It may be
+// followed by a comma separated list of rules, in which case only diagnostics
+// of those rules are suppressed, and otherwise every diagnostic is. The
+// directive applies to its own line and the line after it, so it can either
+// trail the offending code or precede it.
+//
+//	//pepperlint:ignore *deprecated.OpRule
+//	svc.DeprecatedOp()
+const IgnoreDirective = "//pepperlint:ignore"
+
+// IgnoreComment will return the comment that suppresses the diagnostics of
+// rule inline.
+func IgnoreComment(rule string) string {
+	if len(rule) == 0 {
+		return IgnoreDirective
+	}
+
+	return IgnoreDirective + " " + rule
+}
+
+// ignoreDirective is a single ignore comment along with the line it applies
+// to. No rules means every rule is ignored.
+type ignoreDirective struct {
+	line  int
+	rules []string
+}
+
+// ignoreDirectives will return the ignore comments of every file of the
+// packages, keyed by filename.
+func ignoreDirectives(fset *token.FileSet, pkgs []*ast.Package) map[string][]ignoreDirective {
+	directives := map[string][]ignoreDirective{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			var codeLines map[int]bool
+			for _, group := range f.Comments {
+				for _, c := range group.List {
+					rules, ok := parseIgnoreDirective(c.Text)
+					if !ok {
+						continue
+					}
+
+					// lines with code are only looked up once a file is
+					// found to have directives, which few files do
+					if codeLines == nil {
+						codeLines = fileCodeLines(fset, f)
+					}
+
+					pos := fset.Position(c.Pos())
+					line := pos.Line
+					if !codeLines[line] {
+						line++
+					}
+
+					directives[pos.Filename] = append(directives[pos.Filename], ignoreDirective{
+						line:  line,
+						rules: rules,
+					})
+				}
+			}
+		}
+	}
+
+	return directives
+}
+
+// fileCodeLines will return every line of f that a node starts or ends on.
+// Comments are not counted as code.
+func fileCodeLines(fset *token.FileSet, f *ast.File) map[int]bool {
+	lines := map[int]bool{}
+	tf := fset.File(f.Pos())
+	if tf == nil {
+		return lines
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil:
+			return false
+		case *ast.CommentGroup, *ast.Comment:
+			return false
+		}
+
+		lines[tf.Line(n.Pos())] = true
+		lines[tf.Line(n.End())] = true
+		return true
+	})
+
+	return lines
+}
+
+// parseIgnoreDirective will return the rules of an ignore comment. False is
+// returned if the comment is not an ignore comment.
+func parseIgnoreDirective(text string) ([]string, bool) {
+	if !strings.HasPrefix(text, IgnoreDirective) {
+		return nil, false
+	}
+
+	rest := text[len(IgnoreDirective):]
+	if len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' {
+		return nil, false
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return nil, true
+	}
+
+	return strings.Split(fields[0], ","), true
+}
+
+// ignore will return every diagnostic that is not suppressed by an ignore
+// comment.
+func ignore(directives map[string][]ignoreDirective, diags []Diagnostic) []Diagnostic {
+	if len(directives) == 0 {
+		return diags
+	}
+
+	valid := []Diagnostic{}
+	for _, diag := range diags {
+		if !ignored(directives[diag.Filename()], diag) {
+			valid = append(valid, diag)
+		}
+	}
+
+	return valid
+}
+
+func ignored(directives []ignoreDirective, diag Diagnostic) bool {
+	for _, d := range directives {
+		if diag.LineNumber() != d.line {
+			continue
+		}
+
+		if len(d.rules) == 0 {
+			return true
+		}
+
+		for _, rule := range d.rules {
+			if rule == diag.Rule {
+				return true
+			}
+		}
+	}
+
+	return false
+}
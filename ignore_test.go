@@ -0,0 +1,91 @@
+package pepperlint
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseIgnoreDirective(t *testing.T) {
+	cases := []struct {
+		text          string
+		expectedRules []string
+		expectedOK    bool
+	}{
+		{
+			text:       "//pepperlint:ignore",
+			expectedOK: true,
+		},
+		{
+			text:          "//pepperlint:ignore foo",
+			expectedRules: []string{"foo"},
+			expectedOK:    true,
+		},
+		{
+			text:          "//pepperlint:ignore foo,bar",
+			expectedRules: []string{"foo", "bar"},
+			expectedOK:    true,
+		},
+		{
+			text: "//pepperlint:ignorefoo",
+		},
+		{
+			text: "// pepperlint:ignore",
+		},
+		{
+			text: "/* pepperlint:ignore */",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			rules, ok := parseIgnoreDirective(c.text)
+			if e, a := c.expectedOK, ok; e != a {
+				t.Errorf("expected %t, but received %t", e, a)
+			}
+
+			if e, a := c.expectedRules, rules; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+
+func TestRunIgnore(t *testing.T) {
+	src := `package foo
+
+type Foo struct {
+	A int //pepperlint:ignore
+	B int
+	//pepperlint:ignore *pepperlint.testFieldPositions
+	C int
+	//pepperlint:ignore other
+	D int
+
+	E int
+}
+`
+
+	filename := filepath.Join("testdata", "ignore", "foo.go")
+	result, err := Run(context.Background(), Options{
+		Patterns: []string{filename},
+		Overlay: map[string][]byte{
+			filename: []byte(src),
+		},
+		Rules: []CopyRuler{&testFieldPositions{}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	names := []string{}
+	for _, diag := range result.Diagnostics {
+		names = append(names, fmt.Sprintf("%d:%s", diag.LineNumber(), diag.Err.(*ErrorWrap).Message()))
+	}
+
+	if e, a := []string{"5:B", "9:D", "11:E"}, names; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
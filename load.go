@@ -0,0 +1,690 @@
+package pepperlint
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ParseErrorRule is the rule name that parse errors are reported under.
+const ParseErrorRule = "parse"
+
+// dirPackages contains all packages in a given path
+type dirPackages struct {
+	path string
+	pkgs map[string]*ast.Package
+}
+
+// packageSet contains two separate package groups. 'lint' represents packages
+// that rules are applied to, while 'include' packages are only cached so rules
+// can look up declarations from them.
+type packageSet struct {
+	lint    []dirPackages
+	include []dirPackages
+
+	// errs contains any errors found while parsing packages
+	errs Errors
+
+	overlay overlay
+
+	// buildContext determines which files of a directory are parsed.
+	buildContext *build.Context
+
+	// excludeTests will leave test files out of directories.
+	excludeTests bool
+
+	// diskCache stores the declarations of included packages across runs.
+	diskCache *DiskCache
+
+	// logger is given the warnings of the disk cache.
+	logger Logger
+
+	// hashes contains the hashes of every parsed file. Included files are
+	// only hashed with a disk cache.
+	hashes map[*ast.File]fileHash
+
+	// files contains every file that was parsed by the set, and prevFiles
+	// the files of a previous set, which are reused when their contents
+	// have not changed.
+	files     map[string]parsedFile
+	prevFiles map[string]parsedFile
+
+	// includeHashes contains the hashes of included files that were loaded
+	// by a previous set, which are only read.
+	includeHashes map[*ast.File]fileHash
+}
+
+// parsedFile is a file along with the contents it was parsed from.
+type parsedFile struct {
+	f    *ast.File
+	src  []byte
+	hash fileHash
+}
+
+// overlay contains file contents that shadow the files on disk. Files are
+// keyed by their absolute path.
+type overlay map[string][]byte
+
+func newOverlay(files map[string][]byte) overlay {
+	o := overlay{}
+	for filename, src := range files {
+		o[absPath(filename)] = src
+	}
+
+	return o
+}
+
+// source will return the overlaid contents of filename. False is returned if
+// the file is not overlaid and should be read from disk.
+func (o overlay) source(filename string) ([]byte, bool) {
+	src, ok := o[absPath(filename)]
+	return src, ok
+}
+
+// names will return the base names of every overlaid file within dir.
+func (o overlay) names(dir string) []string {
+	dir = absPath(dir)
+
+	names := []string{}
+	for filename := range o {
+		if filepath.Dir(filename) == dir {
+			names = append(names, filepath.Base(filename))
+		}
+	}
+
+	return names
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	return abs
+}
+
+// newPackageSet will return an empty package set that files are added to with
+// the build context and overlay of the options.
+func newPackageSet(opts Options, o overlay, prevFiles map[string]parsedFile) packageSet {
+	set := packageSet{
+		overlay:      o,
+		excludeTests: opts.ExcludeTests,
+		diskCache:    opts.DiskCache,
+		logger:       loggerOrDefault(opts.Logger),
+		hashes:       map[*ast.File]fileHash{},
+		files:        map[string]parsedFile{},
+		prevFiles:    prevFiles,
+	}
+
+	buildContext := opts.buildContext()
+
+	// build constraints are read from the overlay for overlaid files
+	buildContext.OpenFile = func(path string) (io.ReadCloser, error) {
+		if src, ok := o.source(path); ok {
+			return ioutil.NopCloser(bytes.NewReader(src)), nil
+		}
+
+		return os.Open(path)
+	}
+	set.buildContext = &buildContext
+
+	return set
+}
+
+// loadPackages will parse every package matched by the option's patterns
+// along with every package within the include directories. Files that could
+// not be parsed are left out of the package set and reported in the set's
+// errors. Files in prevFiles whose contents have not changed are reused
+// instead of being parsed again.
+func loadPackages(ctx context.Context, fset *token.FileSet, opts Options, o overlay, includeDirs []string, prevFiles map[string]parsedFile) (packageSet, error) {
+	set := newPackageSet(opts, o, prevFiles)
+
+	// file targets are grouped by their absolute directory, so files from the
+	// same directory make up one package and files from different directories
+	// never share one
+	dirs := map[string]struct{}{}
+	fileDirs := []string{}
+	files := map[string][]string{}
+
+	seen := map[string]struct{}{}
+	for _, pattern := range opts.Patterns {
+		targets, err := set.expandPattern(ctx, pattern)
+		if err != nil {
+			return set, err
+		}
+
+		for _, target := range targets {
+			abs := absPath(target)
+			if _, ok := seen[abs]; ok {
+				continue
+			}
+			seen[abs] = struct{}{}
+
+			isDir := false
+			if _, ok := o.source(target); !ok {
+				info, err := os.Stat(target)
+				if err != nil {
+					return set, err
+				}
+
+				isDir = info.IsDir()
+			}
+
+			if isDir {
+				dirs[abs] = struct{}{}
+				set.lint = set.addDir(fset, set.lint, target)
+				continue
+			}
+
+			dir := filepath.Dir(abs)
+			if _, ok := files[dir]; !ok {
+				fileDirs = append(fileDirs, dir)
+			}
+			files[dir] = append(files[dir], target)
+		}
+	}
+
+	for _, dir := range fileDirs {
+		// the files are already linted as part of their directory
+		if _, ok := dirs[dir]; ok {
+			continue
+		}
+
+		set.lint = set.addFiles(fset, set.lint, dir, files[dir])
+	}
+
+	// included directories are always walked recursively
+	for _, included := range includeDirs {
+		dirs, err := set.walkDirs(ctx, included, func(string) bool { return true })
+		if err != nil {
+			return set, err
+		}
+
+		for _, dir := range dirs {
+			set.include = set.addIncludeDir(fset, set.include, dir)
+		}
+	}
+
+	return set, nil
+}
+
+// expandPattern will return the files and directories matched by pattern in
+// the same way `go list` does. A pattern containing "..." is a wildcard that
+// matches any string, including the empty string and strings containing
+// slashes, so "./..." matches every directory within the current directory.
+// Patterns that are not found on disk are looked up in $GOPATH/src.
+func (set *packageSet) expandPattern(ctx context.Context, pattern string) ([]string, error) {
+	if !isLocalPattern(pattern) && !set.exists(patternRoot(pattern)) {
+		pattern = filepath.Join(os.Getenv("GOPATH"), "src", pattern)
+	}
+
+	if !strings.Contains(pattern, "...") {
+		return []string{pattern}, nil
+	}
+
+	pattern = filepath.Clean(pattern)
+	root := patternRoot(pattern)
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	return set.walkDirs(ctx, root, matchPattern(pattern))
+}
+
+// exists will return true if path is overlaid or exists on disk.
+func (set *packageSet) exists(path string) bool {
+	if _, ok := set.overlay.source(path); ok {
+		return true
+	}
+
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// isLocalPattern will return true if the pattern is a relative or absolute
+// path, rather than an import path.
+func isLocalPattern(pattern string) bool {
+	return build.IsLocalImport(pattern) || filepath.IsAbs(pattern)
+}
+
+// patternRoot will return the directory a pattern has to be walked from, which
+// is the directory before the first wildcard.
+func patternRoot(pattern string) string {
+	i := strings.Index(pattern, "...")
+	if i < 0 {
+		return pattern
+	}
+
+	dir := pattern[:i]
+	j := strings.LastIndex(dir, string(filepath.Separator))
+	if j < 0 {
+		return "."
+	}
+
+	if j == 0 {
+		return string(filepath.Separator)
+	}
+
+	return dir[:j]
+}
+
+// matchPattern will return a function that reports whether a path matches the
+// pattern. As with `go list`, a trailing "/..." also matches the directory
+// itself, so "foo/..." matches "foo".
+func matchPattern(pattern string) func(string) bool {
+	re := regexp.QuoteMeta(filepath.ToSlash(pattern))
+	re = strings.Replace(re, `\.\.\.`, `.*`, -1)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+
+	reg := regexp.MustCompile(`^` + re + `$`)
+	return func(path string) bool {
+		return reg.MatchString(filepath.ToSlash(path))
+	}
+}
+
+// skipDir will return true for directories that are not walked into, which
+// are the same directories the go tool ignores.
+func skipDir(name string) bool {
+	return name == "vendor" ||
+		name == "testdata" ||
+		strings.HasPrefix(name, ".") ||
+		strings.HasPrefix(name, "_")
+}
+
+// walkDirs will walk root and return every directory that matches. Any
+// directory skipped by skipDir is not walked, unless it is the root itself.
+func (set *packageSet) walkDirs(ctx context.Context, root string, match func(string) bool) ([]string, error) {
+	dirs := []string{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if err != nil {
+			set.errs.Add(parseErrors(path, err))
+			return nil
+		}
+
+		if !info.Mode().IsDir() {
+			return nil
+		}
+
+		if path != root && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+
+		if match(path) {
+			dirs = append(dirs, path)
+		}
+
+		return nil
+	})
+
+	return dirs, err
+}
+
+// addDir will add the packages of the given directory. Sub directories are not
+// added.
+func (set *packageSet) addDir(fset *token.FileSet, p []dirPackages, dir string) []dirPackages {
+	pkgs, err := set.parseDir(fset, dir)
+	if err != nil {
+		set.errs.Add(err)
+	}
+
+	return append(p, dirPackages{
+		path: dir,
+		pkgs: pkgs,
+	})
+}
+
+// addFiles will add the packages of the given files, which are all within
+// dir. Files are keyed by their path within dir, which is absolute, so the
+// package's import path is found the same way it would be for dir itself.
+func (set *packageSet) addFiles(fset *token.FileSet, p []dirPackages, dir string, filenames []string) []dirPackages {
+	pkgs := map[string]*ast.Package{}
+	for _, filename := range filenames {
+		f, err := set.parseFile(fset, filename)
+		if err != nil {
+			set.errs.Add(parseErrors(filename, err))
+			continue
+		}
+
+		pkg, ok := pkgs[f.Name.Name]
+		if !ok {
+			pkg = &ast.Package{
+				Name:  f.Name.Name,
+				Files: map[string]*ast.File{},
+			}
+			pkgs[f.Name.Name] = pkg
+		}
+
+		pkg.Files[filepath.Join(dir, filepath.Base(filename))] = f
+	}
+
+	if len(pkgs) == 0 {
+		return p
+	}
+
+	return append(p, dirPackages{
+		path: dir,
+		pkgs: pkgs,
+	})
+}
+
+// parseFile will parse filename from the overlay if it was overlaid, and from
+// disk otherwise. If the file was parsed by a previous set from the same
+// contents, that file is returned instead.
+func (set *packageSet) parseFile(fset *token.FileSet, filename string) (*ast.File, error) {
+	src, err := set.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if prev, ok := set.prevFiles[filename]; ok && prev.hash.content == sha256.Sum256(src) {
+		set.files[filename] = prev
+		set.hashes[prev.f] = prev.hash
+		return prev.f, nil
+	}
+
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return f, err
+	}
+
+	hash := newFileHash(fset, f, src)
+	set.files[filename] = parsedFile{
+		f:    f,
+		src:  src,
+		hash: hash,
+	}
+	set.hashes[f] = hash
+
+	return f, nil
+}
+
+// parseDeclarations will parse src with the statements of every function
+// body, and the comments within them, dropped. Included packages are only
+// cached, and the cache never walks into function bodies, so only their
+// declarations need to be kept. Bodies are left empty rather than removed, so
+// they keep their position, as they do when loaded from the disk cache.
+func parseDeclarations(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	f, err := parser.ParseFile(fset, filename, src, declarationParseMode)
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := []*ast.BlockStmt{}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			bodies = append(bodies, fn.Body)
+			fn.Body = &ast.BlockStmt{
+				Lbrace: fn.Body.Lbrace,
+				Rbrace: fn.Body.Rbrace,
+			}
+		}
+	}
+
+	f.Comments = commentsOutside(f.Comments, bodies)
+
+	// unresolved identifiers are mostly found within bodies, and would keep
+	// them from being freed
+	f.Unresolved = nil
+
+	return f, nil
+}
+
+// commentsOutside will return every comment group that is not within any of
+// the blocks. Both comments and blocks must be sorted by position.
+func commentsOutside(comments []*ast.CommentGroup, blocks []*ast.BlockStmt) []*ast.CommentGroup {
+	outside := []*ast.CommentGroup{}
+	i := 0
+	for _, c := range comments {
+		for i < len(blocks) && blocks[i].End() <= c.Pos() {
+			i++
+		}
+
+		if i < len(blocks) && blocks[i].Pos() <= c.Pos() {
+			continue
+		}
+
+		outside = append(outside, c)
+	}
+
+	return outside
+}
+
+// resolveDeclarations will resolve the identifiers of the package's
+// declarations that refer to other declarations of the package, such as the
+// receivers of methods and the types of struct fields. Unlike the parser,
+// which only resolves identifiers within a single file, identifiers are
+// resolved across every file of the package. Identifiers that were already
+// resolved are left as is.
+func resolveDeclarations(pkg *ast.Package) {
+	scope := map[string]*ast.Object{}
+	declare := func(ident *ast.Ident, kind ast.ObjKind, decl interface{}) {
+		if ident == nil || ident.Name == "_" {
+			return
+		}
+
+		obj := ident.Obj
+		if obj == nil {
+			obj = ast.NewObj(kind, ident.Name)
+			obj.Decl = decl
+			ident.Obj = obj
+		}
+
+		if _, ok := scope[ident.Name]; !ok {
+			scope[ident.Name] = obj
+		}
+	}
+
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					declare(decl.Name, ast.Fun, decl)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						declare(spec.Name, ast.Typ, spec)
+					case *ast.ValueSpec:
+						kind := ast.Var
+						if decl.Tok == token.CONST {
+							kind = ast.Con
+						}
+
+						for _, name := range spec.Names {
+							declare(name, kind, spec)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv != nil {
+					resolveIdents(decl.Recv, scope)
+				}
+				resolveIdents(decl.Type, scope)
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						resolveIdents(spec.Type, scope)
+					case *ast.ValueSpec:
+						if spec.Type != nil {
+							resolveIdents(spec.Type, scope)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// resolveIdents will resolve every unresolved identifier within node that is
+// declared in scope. Field names and selectors are never resolved.
+func resolveIdents(node ast.Node, scope map[string]*ast.Object) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// the selector refers to another package
+			return false
+		case *ast.Field:
+			if n.Type != nil {
+				resolveIdents(n.Type, scope)
+			}
+			return false
+		case *ast.Ident:
+			if n.Obj == nil {
+				n.Obj = scope[n.Name]
+			}
+		}
+
+		return true
+	})
+}
+
+// parseDir behaves like parser.ParseDir, except that every file that could not
+// be parsed is reported instead of only the first, and overlaid files are
+// parsed in place of, or in addition to, the files on disk. Files are filtered
+// by the set's build context, so only files that would be built for the
+// context's tags, GOOS and GOARCH are parsed.
+func (set *packageSet) parseDir(fset *token.FileSet, path string) (map[string]*ast.Package, error) {
+	pkgs := map[string]*ast.Package{}
+
+	filenames, err := set.dirFiles(path)
+	batchErr := NewRuleBatchError(ParseErrorRule)
+	if err != nil {
+		batchErr.Add(err)
+	}
+
+	for _, filename := range filenames {
+		f, err := set.parseFile(fset, filename)
+		if err != nil {
+			batchErr.Add(parseErrors(filename, err))
+			continue
+		}
+
+		addPackageFile(pkgs, filename, f)
+	}
+
+	return pkgs, batchErr.Return()
+}
+
+// dirFiles will return the sorted filenames of every go file in the directory
+// that matches the set's build context.
+func (set *packageSet) dirFiles(path string) ([]string, error) {
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, parseErrors(path, err)
+	}
+
+	names := set.overlay.names(path)
+	for _, info := range infos {
+		if !info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+
+	filenames := []string{}
+	batchErr := NewRuleBatchError(ParseErrorRule)
+	for i, name := range names {
+		if !strings.HasSuffix(name, ".go") || (i > 0 && name == names[i-1]) {
+			continue
+		}
+
+		if set.excludeTests && IsTestFilename(name) {
+			continue
+		}
+
+		filename := filepath.Join(path, name)
+		match, err := set.buildContext.MatchFile(path, name)
+		if err != nil {
+			batchErr.Add(parseErrors(filename, err))
+			continue
+		}
+
+		if match {
+			filenames = append(filenames, filename)
+		}
+	}
+
+	return filenames, batchErr.Return()
+}
+
+// addPackageFile will add f to the package it belongs to, creating the package
+// if it does not exist yet.
+func addPackageFile(pkgs map[string]*ast.Package, filename string, f *ast.File) {
+	pkg, ok := pkgs[f.Name.Name]
+	if !ok {
+		pkg = &ast.Package{
+			Name:  f.Name.Name,
+			Files: map[string]*ast.File{},
+		}
+		pkgs[f.Name.Name] = pkg
+	}
+
+	pkg.Files[filename] = f
+}
+
+// parseErrors will return a batch error with an error for each syntax error
+// found in the file. Any other error is reported against the path.
+func parseErrors(path string, err error) error {
+	batchErr := NewRuleBatchError(ParseErrorRule)
+
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		batchErr.Add(NewErrorWrapAt(token.Position{Filename: path}, err.Error()))
+		return batchErr
+	}
+
+	for _, e := range list {
+		batchErr.Add(NewErrorWrapAt(e.Pos, e.Msg))
+	}
+
+	return batchErr
+}
+
+// sortedPackages will return every package in p in the order they are walked.
+func sortedPackages(p []dirPackages) []*ast.Package {
+	sorted := []*ast.Package{}
+	sortedPkgNames := []string{}
+	for _, pkgs := range p {
+		sortedPkgNames = sortedPkgNames[0:0]
+		for name := range pkgs.pkgs {
+			sortedPkgNames = append(sortedPkgNames, name)
+		}
+
+		sort.Strings(sortedPkgNames)
+		for _, name := range sortedPkgNames {
+			sorted = append(sorted, pkgs.pkgs[name])
+		}
+	}
+
+	return sorted
+}
@@ -0,0 +1,149 @@
+package pepperlint
+
+import (
+	"context"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDeclarations(t *testing.T) {
+	srcs := map[string]string{
+		"foo.go": `package foo
+
+// Foo embeds Bar
+type Foo struct {
+	Bar
+}
+
+// Do does
+func (f *Foo) Do() int {
+	// within the body
+	return 1
+}
+`,
+		"bar.go": `package foo
+
+type Bar struct {
+	Baz *Baz
+}
+
+type Baz int
+`,
+	}
+
+	fset := token.NewFileSet()
+	pkg := &ast.Package{
+		Name:  "foo",
+		Files: map[string]*ast.File{},
+	}
+
+	for filename, src := range srcs {
+		f, err := parseDeclarations(fset, filename, []byte(src))
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		pkg.Files[filename] = f
+	}
+	resolveDeclarations(pkg)
+
+	foo := pkg.Files["foo.go"]
+	fn := foo.Decls[1].(*ast.FuncDecl)
+	if fn.Body == nil || len(fn.Body.List) != 0 {
+		t.Fatalf("expected the statements of the body to be dropped")
+	}
+
+	if e, a := 12, fset.Position(fn.Body.End()).Line; e != a {
+		t.Errorf("expected the body to end on line %d, but received %d", e, a)
+	}
+
+	comments := []string{}
+	for _, c := range foo.Comments {
+		comments = append(comments, c.Text())
+	}
+
+	if e, a := []string{"Foo embeds Bar\n", "Do does\n"}, comments; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	cache := NewCache()
+	WalkPackage(cache, pkg)
+
+	fooPkg, ok := cache.CurrentPackage()
+	if !ok {
+		t.Fatalf("expected package foo to be cached")
+	}
+
+	fooInfo, _ := fooPkg.Files.GetTypeInfo("Foo")
+	barInfo, _ := fooPkg.Files.GetTypeInfo("Bar")
+	bazInfo, _ := fooPkg.Files.GetTypeInfo("Baz")
+
+	op, ok := fooPkg.Files.GetOpInfo("Do")
+	if !ok {
+		t.Fatalf("expected Do to be cached")
+	}
+
+	if !op.HasReceiverType(fooInfo.Spec) {
+		t.Errorf("expected Do to have a receiver of type Foo")
+	}
+
+	embedded := fooInfo.Spec.Type.(*ast.StructType).Fields.List[0].Type.(*ast.Ident)
+	if embedded.Obj == nil || embedded.Obj.Decl != barInfo.Spec {
+		t.Errorf("expected Bar to be resolved across files")
+	}
+
+	field := barInfo.Spec.Type.(*ast.StructType).Fields.List[0]
+	if field.Names[0].Obj != nil && field.Names[0].Obj.Decl == bazInfo.Spec {
+		t.Errorf("expected field name to not be resolved")
+	}
+
+	if ident := field.Type.(*ast.StarExpr).X.(*ast.Ident); ident.Obj == nil || ident.Obj.Decl != bazInfo.Spec {
+		t.Errorf("expected Baz to be resolved")
+	}
+}
+
+// BenchmarkLoadIncluded compares parsing included directories in full with
+// only parsing their declarations. As with the visitor benchmarks,
+// PEPPERLINT_BENCH_CORPUS can be set to load a larger code base.
+func BenchmarkLoadIncluded(b *testing.B) {
+	root := os.Getenv("PEPPERLINT_BENCH_CORPUS")
+	if len(root) == 0 {
+		root = filepath.Join(build.Default.GOROOT, "src", "go")
+	}
+
+	set := newPackageSet(Options{}, overlay{}, nil)
+
+	dirs, err := set.walkDirs(context.Background(), root, func(string) bool { return true })
+	if err != nil || len(dirs) == 0 {
+		b.Skipf("unable to load benchmark corpus %q: %v", root, err)
+	}
+
+	loaders := map[string]func(*token.FileSet, []dirPackages, string) []dirPackages{
+		"full":         set.addDir,
+		"declarations": set.addIncludeDir,
+	}
+
+	for _, name := range []string{"full", "declarations"} {
+		load := loaders[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fset := token.NewFileSet()
+				p := []dirPackages{}
+				for _, dir := range dirs {
+					p = load(fset, p, dir)
+				}
+
+				cache := NewCache()
+				for _, pkg := range sortedPackages(p) {
+					WalkPackage(cache, pkg)
+				}
+			}
+		})
+	}
+}
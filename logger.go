@@ -1,18 +1,350 @@
 package pepperlint
 
 import (
-	"log"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// Log will be used to log only if PEPPERLINT_DEBUG is set
+// Level is the severity of a log entry.
+type Level int
+
+// Levels of log entries, from the most verbose to the least.
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	}
+
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel will return the level with the given name.
+func ParseLevel(name string) (Level, error) {
+	for _, l := range []Level{DebugLevel, InfoLevel, WarnLevel} {
+		if strings.EqualFold(l.String(), name) {
+			return l, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// Field is a key value pair that is attached to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// RuleField will return a field with the name of a rule.
+func RuleField(name string) Field {
+	return Field{Key: "rule", Value: name}
+}
+
+// NodeField will return a field with the type of a node.
+func NodeField(node interface{}) Field {
+	return Field{Key: "node", Value: fmt.Sprintf("%T", node)}
+}
+
+// PosField will return a field with a position.
+func PosField(pos token.Position) Field {
+	return Field{Key: "pos", Value: pos}
+}
+
+// Logger logs entries with a level and any number of fields. Loggers need to
+// be safe to use from multiple goroutines, as packages are linted in parallel.
+//
+// Enabled reports whether entries of a level are logged at all, so callers on
+// hot paths can skip building the message and fields of entries that would be
+// dropped.
+type Logger interface {
+	Enabled(level Level) bool
+	Log(level Level, msg string, fields ...Field)
+}
+
+// LoggerOption can be implemented by rules to be given the logger of the
+// visitor, which has the rule's name attached to every entry.
+type LoggerOption interface {
+	WithLogger(Logger)
+}
+
+// WithLogger will return an option that sets the logger of a visitor, which
+// is given to every rule that implements LoggerOption. DefaultLogger is used
+// if a visitor is not given one.
+func WithLogger(l Logger) Option {
+	return loggerOption{logger: l}
+}
+
+type loggerOption struct {
+	logger Logger
+}
+
+// DefaultLogger is used when no logger is provided. Entries of every level
+// are written to stderr if PEPPERLINT_DEBUG is set, and are discarded
+// otherwise.
+var DefaultLogger Logger
+
+// Log will log at the debug level of DefaultLogger.
+//
+// Deprecated: Use a Logger instead.
 var Log func(format string, args ...interface{})
 
 func init() {
 	if v := os.Getenv("PEPPERLINT_DEBUG"); len(v) != 0 {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
-		Log = log.Printf
+		DefaultLogger = NewLogger(os.Stderr, DebugLevel)
 	} else {
-		Log = func(format string, args ...interface{}) {}
+		DefaultLogger = NewLogger(ioutil.Discard, WarnLevel)
 	}
+
+	Log = func(format string, args ...interface{}) {
+		if DefaultLogger.Enabled(DebugLevel) {
+			DefaultLogger.Log(DebugLevel, fmt.Sprintf(format, args...))
+		}
+	}
+}
+
+// loggerOrDefault will return l, or DefaultLogger if l is nil.
+func loggerOrDefault(l Logger) Logger {
+	if l == nil {
+		return DefaultLogger
+	}
+
+	return l
+}
+
+type textLogger struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// NewLogger will return a logger that writes entries of level and above to w,
+// one per line, with fields written as key=value.
+func NewLogger(w io.Writer, level Level) Logger {
+	return &textLogger{
+		w:     w,
+		level: level,
+	}
+}
+
+func (l *textLogger) Enabled(level Level) bool {
+	return level >= l.level && l.w != ioutil.Discard
+}
+
+func (l *textLogger) Log(level Level, msg string, fields ...Field) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	line := strings.ToUpper(level.String()) + " " + msg
+	for _, field := range fields {
+		value := fmt.Sprint(field.Value)
+		if strings.ContainsAny(value, " \t\n\"=") || len(value) == 0 {
+			value = strconv.Quote(value)
+		}
+
+		line += " " + field.Key + "=" + value
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	fmt.Fprintln(l.w, line)
+}
+
+// withFields is a logger that attaches fields to every entry.
+type withFields struct {
+	logger Logger
+	fields []Field
+}
+
+// With will return a logger that attaches fields to every entry logged to l.
+func With(l Logger, fields ...Field) Logger {
+	if w, ok := l.(withFields); ok {
+		l = w.logger
+		fields = append(append(make([]Field, 0, len(w.fields)+len(fields)), w.fields...), fields...)
+	}
+
+	// the capacity is capped, so a logger appending to the fields it is given
+	// never writes over them
+	return withFields{
+		logger: l,
+		fields: fields[:len(fields):len(fields)],
+	}
+}
+
+func (l withFields) Enabled(level Level) bool {
+	return l.logger.Enabled(level)
+}
+
+func (l withFields) Log(level Level, msg string, fields ...Field) {
+	if !l.logger.Enabled(level) {
+		return
+	}
+
+	if len(fields) == 0 {
+		l.logger.Log(level, msg, l.fields...)
+		return
+	}
+
+	all := make([]Field, 0, len(l.fields)+len(fields))
+	l.logger.Log(level, msg, append(append(all, l.fields...), fields...)...)
+}
+
+const unhandledMessage = "unhandled node"
+
+// Unhandled will log at the debug level that site came across a node it does
+// not handle. Entries are aggregated by an UnhandledReport instead of being
+// logged one by one. The position of the node is logged if it is an ast.Node
+// and fset is not nil.
+func Unhandled(l Logger, fset *token.FileSet, site string, node interface{}) {
+	l = loggerOrDefault(l)
+	if !l.Enabled(DebugLevel) {
+		return
+	}
+
+	fields := []Field{{Key: "site", Value: site}, NodeField(node)}
+	if n, ok := node.(ast.Node); ok && fset != nil && n != nil && n.Pos().IsValid() {
+		fields = append(fields, PosField(fset.Position(n.Pos())))
+	}
+
+	l.Log(DebugLevel, unhandledMessage, fields...)
+}
+
+// UnhandledNode is a kind of node that a rule does not handle.
+type UnhandledNode struct {
+	Rule string
+	Site string
+	Node string
+
+	// Count is the number of times the node was not handled, and Pos is the
+	// first position it was not handled at, if known.
+	Count int
+	Pos   token.Position
+}
+
+// UnhandledReport is a logger that aggregates the entries logged by Unhandled,
+// and passes any other entry on to Logger.
+type UnhandledReport struct {
+	Logger Logger
+
+	lock  sync.Mutex
+	nodes map[UnhandledNode]*UnhandledNode
+}
+
+// NewUnhandledReport will return a report that passes every entry other than
+// unhandled nodes on to l, which may be nil.
+func NewUnhandledReport(l Logger) *UnhandledReport {
+	return &UnhandledReport{
+		Logger: l,
+		nodes:  map[UnhandledNode]*UnhandledNode{},
+	}
+}
+
+// Enabled satisfies the Logger interface. Unhandled nodes are logged at the
+// debug level, which is always enabled so they can be aggregated.
+func (r *UnhandledReport) Enabled(level Level) bool {
+	return level == DebugLevel || (r.Logger != nil && r.Logger.Enabled(level))
+}
+
+// Log satisfies the Logger interface.
+func (r *UnhandledReport) Log(level Level, msg string, fields ...Field) {
+	if msg != unhandledMessage {
+		if r.Logger != nil {
+			r.Logger.Log(level, msg, fields...)
+		}
+
+		return
+	}
+
+	key := UnhandledNode{}
+	var pos token.Position
+	for _, field := range fields {
+		switch field.Key {
+		case "rule":
+			key.Rule = fmt.Sprint(field.Value)
+		case "site":
+			key.Site = fmt.Sprint(field.Value)
+		case "node":
+			key.Node = fmt.Sprint(field.Value)
+		case "pos":
+			pos, _ = field.Value.(token.Position)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	node, ok := r.nodes[key]
+	if !ok {
+		node = &UnhandledNode{Rule: key.Rule, Site: key.Site, Node: key.Node}
+		r.nodes[key] = node
+	}
+
+	node.Count++
+
+	// the earliest position is kept, so the report is the same regardless of
+	// the order packages are linted in
+	if pos.IsValid() && (!node.Pos.IsValid() || positionLess(pos, node.Pos)) {
+		node.Pos = pos
+	}
+}
+
+// Nodes will return every unhandled node, the most frequent first.
+func (r *UnhandledReport) Nodes() []UnhandledNode {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	nodes := make([]UnhandledNode, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		nodes = append(nodes, *node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+
+		if a.Site != b.Site {
+			return a.Site < b.Site
+		}
+
+		return a.Node < b.Node
+	})
+
+	return nodes
+}
+
+func positionLess(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+
+	return a.Column < b.Column
 }
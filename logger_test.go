@@ -0,0 +1,153 @@
+package pepperlint
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	buf := bytes.Buffer{}
+	l := With(NewLogger(&buf, InfoLevel), RuleField("core/deprecated"))
+
+	l.Log(DebugLevel, "dropped")
+	l.Log(InfoLevel, "linted", Field{Key: "packages", Value: 2})
+	l.Log(WarnLevel, "unable to cache", Field{Key: "err", Value: "no space left"}, PosField(token.Position{Filename: "foo.go", Line: 3, Column: 4}))
+
+	expected := `INFO linted rule=core/deprecated packages=2
+WARN unable to cache rule=core/deprecated err="no space left" pos=foo.go:3:4
+`
+	if e, a := expected, buf.String(); e != a {
+		t.Errorf("expected\n%s\nbut received\n%s", e, a)
+	}
+}
+
+func TestLoggerWith(t *testing.T) {
+	buf := bytes.Buffer{}
+	parent := With(NewLogger(&buf, InfoLevel), Field{Key: "a", Value: 1})
+
+	// loggers made from the same parent do not share their fields
+	b := With(parent, Field{Key: "b", Value: 2})
+	c := With(parent, Field{Key: "c", Value: 3})
+	b.Log(InfoLevel, "b")
+	c.Log(InfoLevel, "c")
+
+	expected := "INFO b a=1 b=2\nINFO c a=1 c=3\n"
+	if e, a := expected, buf.String(); e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+}
+
+func TestLoggerDisabled(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "foo.go", "package foo\n\nvar a = b\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := f.Decls[0]
+
+	l := With(NewLogger(ioutil.Discard, DebugLevel), RuleField("core/deprecated"))
+	if l.Enabled(WarnLevel) {
+		t.Errorf("expected a discarded logger to not be enabled")
+	}
+
+	// nothing is allocated for entries that are dropped
+	allocs := testing.AllocsPerRun(100, func() {
+		Unhandled(l, fset, "testUnhandledRule.ValidateAssignStmt", node)
+		l.Log(DebugLevel, "dropped")
+	})
+
+	if e, a := 0.0, allocs; e != a {
+		t.Errorf("expected %v allocations, but received %v", e, a)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []Level{DebugLevel, InfoLevel, WarnLevel} {
+		l, err := ParseLevel(level.String())
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		if e, a := level, l; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+	}
+
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// testUnhandledRule reports every identifier it is called with as unhandled.
+type testUnhandledRule struct {
+	fset   *token.FileSet
+	logger Logger
+}
+
+func (r *testUnhandledRule) ValidateAssignStmt(stmt *ast.AssignStmt) error {
+	for _, expr := range stmt.Rhs {
+		Unhandled(r.logger, r.fset, "testUnhandledRule.ValidateAssignStmt", expr)
+	}
+
+	return nil
+}
+
+func (r *testUnhandledRule) CopyRule() Rule {
+	return &testUnhandledRule{}
+}
+
+func (r *testUnhandledRule) WithFileSet(fset *token.FileSet) {
+	r.fset = fset
+}
+
+func (r *testUnhandledRule) WithLogger(logger Logger) {
+	r.logger = logger
+}
+
+func TestUnhandledReport(t *testing.T) {
+	src := "package foo\n\nfunc foo() {\n\ta := b\n\tc := 1\n\td := e\n}\n"
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "foo.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	report := NewUnhandledReport(NewLogger(&buf, DebugLevel))
+
+	v := NewVisitor(fset, NewCache(), &testUnhandledRule{}, WithLogger(report))
+	ast.Walk(v, f)
+
+	report.Log(InfoLevel, "passed on")
+
+	expected := []UnhandledNode{
+		{
+			Rule:  "*pepperlint.testUnhandledRule",
+			Site:  "testUnhandledRule.ValidateAssignStmt",
+			Node:  "*ast.Ident",
+			Count: 2,
+			Pos:   token.Position{Filename: "foo.go", Offset: 32, Line: 4, Column: 7},
+		},
+		{
+			Rule:  "*pepperlint.testUnhandledRule",
+			Site:  "testUnhandledRule.ValidateAssignStmt",
+			Node:  "*ast.BasicLit",
+			Count: 1,
+			Pos:   token.Position{Filename: "foo.go", Offset: 40, Line: 5, Column: 7},
+		},
+	}
+
+	if e, a := expected, report.Nodes(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %+v, but received %+v", e, a)
+	}
+
+	if e, a := "INFO passed on\n", buf.String(); e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+}
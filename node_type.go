@@ -0,0 +1,90 @@
+package pepperlint
+
+import (
+	"reflect"
+)
+
+// NodeType is a bit set of the node types that rules can be validated
+// against. Each bit corresponds to a list in Rules.
+type NodeType uint32
+
+// Node types, one for each rule list in Rules.
+const (
+	PackageNode NodeType = 1 << iota
+	FileNode
+
+	// Specifications
+	TypeSpecNode
+	ValueSpecNode
+
+	// Declarations
+	GenDeclNode
+	FuncDeclNode
+
+	// Expressions
+	CallExprNode
+	BinaryExprNode
+
+	// Statements
+	AssignStmtNode
+	BlockStmtNode
+	ReturnStmtNode
+	IncDecStmtNode
+	RangeStmtNode
+
+	// Complex Types
+	StructTypeNode
+	FieldNode
+	FieldListNode
+	FuncTypeNode
+	InterfaceTypeNode
+
+	// Container Types
+	ArrayTypeNode
+	ChanTypeNode
+	MapTypeNode
+
+	// AllNodes is every node type a rule can be validated against.
+	AllNodes NodeType = 1<<iota - 1
+
+	numNodeTypes = iota - 1
+)
+
+// Has will return true if any of the node types in t are in n.
+func (n NodeType) Has(t NodeType) bool {
+	return n&t != 0
+}
+
+// NodeFilter can be implemented by a rule to declare up front which node types
+// it needs to see. The rule will only be added to the rule lists of those node
+// types, even if it satisfies the interfaces of others.
+type NodeFilter interface {
+	NodeTypes() NodeType
+}
+
+// NodeTypes will return the set of node types that have at least one rule.
+func (r Rules) NodeTypes() NodeType {
+	var n NodeType
+
+	lists := reflect.ValueOf(r)
+	for i := 0; i < lists.NumField(); i++ {
+		if lists.Field(i).Len() > 0 {
+			n |= ruleListType(i)
+		}
+	}
+
+	return n
+}
+
+// Filter will return a copy of the rule set with every rule list that is not
+// in n removed.
+func (r Rules) Filter(n NodeType) Rules {
+	lists := reflect.ValueOf(&r).Elem()
+	for i := 0; i < lists.NumField(); i++ {
+		if list := lists.Field(i); !n.Has(ruleListType(i)) {
+			list.Set(reflect.Zero(list.Type()))
+		}
+	}
+
+	return r
+}
@@ -13,7 +13,9 @@ type Option interface{}
 // if this needs to be a more specific type.
 type Rule interface{}
 
-// RulesAdder will add rules to sets of rules
+// RulesAdder will add rules to sets of rules. This only needs to be
+// implemented by rules that group other rules together, as any other
+// rule will be added by Rules.Register.
 type RulesAdder interface {
 	AddRules(*Rules)
 }
@@ -35,3 +37,9 @@ type FileSetOption interface {
 type CopyRuler interface {
 	CopyRule() Rule
 }
+
+// RuleNamer can be implemented by a rule to set the name of the rule that is
+// attached to the errors it reports. The rule's type is used otherwise.
+type RuleNamer interface {
+	RuleName() string
+}
@@ -0,0 +1,10 @@
+//go:build !go1.17
+// +build !go1.17
+
+package pepperlint
+
+import "go/parser"
+
+// declarationParseMode is the mode included files are parsed with. Objects are
+// resolved by the parser, since it cannot skip resolution before go1.17.
+const declarationParseMode = parser.ParseComments
@@ -0,0 +1,11 @@
+//go:build go1.17
+// +build go1.17
+
+package pepperlint
+
+import "go/parser"
+
+// declarationParseMode is the mode included files are parsed with. Objects are
+// not resolved by the parser, since resolveDeclarations only resolves what the
+// cache needs once function bodies are dropped.
+const declarationParseMode = parser.ParseComments | parser.SkipObjectResolution
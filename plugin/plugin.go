@@ -0,0 +1,415 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// Plugin is a rule that is implemented by an external executable. The
+// executable is started the first time a file is validated, and is shared by
+// every copy of the rule until Close is called.
+type Plugin struct {
+	Name    string
+	Command string
+	Args    []string
+	Config  map[string]string
+
+	fset  *token.FileSet
+	cache *pepperlint.Cache
+
+	proc *process
+}
+
+// New will return a plugin that is named name and runs command with args.
+// Config is sent to the plugin when it is started.
+func New(name, command string, args []string, config map[string]string) *Plugin {
+	p := &Plugin{
+		Name:    name,
+		Command: command,
+		Args:    args,
+		Config:  config,
+	}
+	p.proc = &process{plugin: p}
+
+	return p
+}
+
+// CopyRule satisfies the pepperlint.CopyRuler interface. Copies share the
+// plugin's process.
+func (p *Plugin) CopyRule() pepperlint.Rule {
+	cp := *p
+	cp.fset = nil
+	cp.cache = nil
+
+	return &cp
+}
+
+// RuleName satisfies the pepperlint.RuleNamer interface.
+func (p *Plugin) RuleName() string {
+	return p.Name
+}
+
+// NodeTypes satisfies the pepperlint.NodeFilter interface. Plugins are only
+// sent whole files.
+func (p *Plugin) NodeTypes() pepperlint.NodeType {
+	return pepperlint.FileNode
+}
+
+// WithFileSet satisfies the pepperlint.FileSetOption interface.
+func (p *Plugin) WithFileSet(fset *token.FileSet) {
+	p.fset = fset
+}
+
+// WithCache satisfies the pepperlint.CacheOption interface.
+func (p *Plugin) WithCache(cache *pepperlint.Cache) {
+	p.cache = cache
+}
+
+// CacheKey satisfies the pepperlint.CacheKeyer interface. The key changes
+// whenever the plugin's executable is modified, so diagnostics are not reused
+// after a plugin is upgraded.
+func (p *Plugin) CacheKey() string {
+	key := []string{p.Name, p.Command}
+	key = append(key, p.Args...)
+
+	names := make([]string, 0, len(p.Config))
+	for name := range p.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key = append(key, name+"="+p.Config[name])
+	}
+
+	if path, err := exec.LookPath(p.Command); err == nil {
+		if info, err := os.Stat(path); err == nil {
+			key = append(key, strconv.FormatInt(info.ModTime().UnixNano(), 10), strconv.FormatInt(info.Size(), 10))
+		}
+	}
+
+	b, _ := json.Marshal(key)
+	return string(b)
+}
+
+// Close will stop the plugin's process, if it was started.
+func (p *Plugin) Close() error {
+	return p.proc.close()
+}
+
+// ValidateFile satisfies the pepperlint.FileRule interface. The file is sent to
+// the plugin and any diagnostics the plugin returns are reported.
+func (p *Plugin) ValidateFile(f *ast.File) error {
+	nodes, err := p.proc.start()
+	if err != nil {
+		return err
+	}
+
+	file, err := p.file(f, nodes)
+	if err != nil {
+		return err
+	}
+
+	resp := FileResponse{}
+	if err := p.proc.call(Request{Type: FileRequest, File: &file}, &resp); err != nil {
+		return err
+	}
+
+	if len(resp.Error) > 0 {
+		return fmt.Errorf("plugin %s: %s", p.Name, resp.Error)
+	}
+
+	tf := p.fset.File(f.Pos())
+	position := func(pos Position) token.Position {
+		if pos.Line == 0 && pos.Offset >= 0 && pos.Offset <= tf.Size() {
+			return tf.Position(tf.Pos(pos.Offset))
+		}
+
+		return token.Position{
+			Filename: file.Filename,
+			Offset:   pos.Offset,
+			Line:     pos.Line,
+			Column:   pos.Column,
+		}
+	}
+
+	batchError := pepperlint.NewBatchError()
+	for _, diag := range resp.Diagnostics {
+		err := pepperlint.NewErrorWrapAt(position(diag.Pos), diag.Message)
+		for _, fix := range diag.Fixes {
+			err.WithFix(fix.suggestedFix(position))
+		}
+
+		batchError.Add(err)
+	}
+
+	return batchError.Return()
+}
+
+// file will return the file that is sent to the plugin for f, along with any
+// of its nodes whose types are in nodeTypes.
+func (p *Plugin) file(f *ast.File, nodeTypes map[string]bool) (File, error) {
+	tf := p.fset.File(f.Pos())
+	file := File{
+		Filename:  tf.Name(),
+		Package:   f.Name.Name,
+		Generated: pepperlint.IsGenerated(f),
+		Imports:   map[string]string{},
+	}
+
+	if p.cache != nil {
+		file.ImportPath = p.cache.CurrentPkgImportPath
+		if cf, ok := p.cache.CurrentFile(); ok && cf.ASTFile == f {
+			file.Imports = cf.Imports
+		}
+	}
+	file.Test = pepperlint.IsTestFilename(file.Filename)
+
+	var src []byte
+	if p.cache != nil {
+		src, _ = p.cache.Source(f)
+	}
+
+	if src == nil {
+		b, err := ioutil.ReadFile(file.Filename)
+		if err != nil {
+			return File{}, fmt.Errorf("plugin %s: %v", p.Name, err)
+		}
+		src = b
+	}
+	file.Source = string(src)
+
+	position := func(pos token.Pos) Position {
+		position := tf.Position(pos)
+		return Position{
+			Offset: position.Offset,
+			Line:   position.Line,
+			Column: position.Column,
+		}
+	}
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+
+		typ := reflect.TypeOf(node).Elem().Name()
+		if !nodeTypes[typ] {
+			return true
+		}
+
+		file.Nodes = append(file.Nodes, Node{
+			Type: typ,
+			Pos:  position(node.Pos()),
+			End:  position(node.End()),
+			Name: nodeName(node, file.Imports),
+		})
+
+		return true
+	})
+
+	return file, nil
+}
+
+// nodeName will return the name of what node refers to or declares.
+func nodeName(node ast.Node, imports map[string]string) string {
+	switch t := node.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		if ident, ok := t.X.(*ast.Ident); ok {
+			if importPath, ok := imports[ident.Name]; ok && ident.Obj == nil {
+				return importPath + "." + t.Sel.Name
+			}
+		}
+
+		if x := nodeName(t.X, imports); len(x) > 0 {
+			return x + "." + t.Sel.Name
+		}
+
+		return t.Sel.Name
+	case *ast.CallExpr:
+		return nodeName(t.Fun, imports)
+	case *ast.StarExpr:
+		return nodeName(t.X, imports)
+	case *ast.FuncDecl:
+		if t.Recv != nil && len(t.Recv.List) > 0 {
+			return nodeName(t.Recv.List[0].Type, imports) + "." + t.Name.Name
+		}
+
+		return t.Name.Name
+	case *ast.TypeSpec:
+		return t.Name.Name
+	case *ast.ImportSpec:
+		path, _ := strconv.Unquote(t.Path.Value)
+		return path
+	}
+
+	return ""
+}
+
+// process is a running plugin. Requests are sent one at a time, as plugins are
+// not required to handle more than one.
+type process struct {
+	plugin *Plugin
+
+	lock    sync.Mutex
+	started bool
+	err     error
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	r     *bufio.Reader
+	nodes map[string]bool
+}
+
+// start will start the plugin if it has not been started, and return the node
+// types it asked for.
+func (proc *process) start() (map[string]bool, error) {
+	proc.lock.Lock()
+	defer proc.lock.Unlock()
+
+	if proc.started {
+		return proc.nodes, proc.err
+	}
+	proc.started = true
+
+	p := proc.plugin
+	proc.cmd = exec.Command(p.Command, p.Args...)
+	proc.cmd.Stderr = os.Stderr
+
+	stdin, err := proc.cmd.StdinPipe()
+	if err != nil {
+		return nil, proc.fail(err)
+	}
+
+	stdout, err := proc.cmd.StdoutPipe()
+	if err != nil {
+		return nil, proc.fail(err)
+	}
+
+	if err := proc.cmd.Start(); err != nil {
+		return nil, proc.fail(err)
+	}
+
+	proc.stdin = stdin
+	proc.r = bufio.NewReader(stdout)
+
+	resp := InitResponse{}
+	req := Request{
+		Type:    InitRequest,
+		Version: Version,
+		Name:    p.Name,
+		Config:  p.Config,
+	}
+
+	if err := proc.roundTrip(req, &resp); err != nil {
+		return nil, proc.fail(err)
+	}
+
+	if len(resp.Error) > 0 {
+		return nil, proc.fail(errors.New(resp.Error))
+	}
+
+	proc.nodes = map[string]bool{}
+	for _, node := range resp.Nodes {
+		proc.nodes[node] = true
+	}
+
+	return proc.nodes, nil
+}
+
+// call will send req to the plugin and decode its reply into resp.
+func (proc *process) call(req Request, resp interface{}) error {
+	proc.lock.Lock()
+	defer proc.lock.Unlock()
+
+	if proc.err != nil {
+		return proc.err
+	}
+
+	if err := proc.roundTrip(req, resp); err != nil {
+		return proc.fail(err)
+	}
+
+	return nil
+}
+
+func (proc *process) roundTrip(req Request, resp interface{}) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	if _, err := proc.stdin.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	line, err := proc.r.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF {
+			return errors.New("plugin exited")
+		}
+
+		return err
+	}
+
+	if err := json.Unmarshal(line, resp); err != nil {
+		return fmt.Errorf("unable to decode reply: %v", err)
+	}
+
+	return nil
+}
+
+// fail will stop the plugin, so every later request returns err.
+func (proc *process) fail(err error) error {
+	proc.err = fmt.Errorf("plugin %s: %v", proc.plugin.Name, err)
+	proc.stop()
+
+	return proc.err
+}
+
+func (proc *process) close() error {
+	proc.lock.Lock()
+	defer proc.lock.Unlock()
+
+	if !proc.started {
+		return nil
+	}
+
+	if proc.err == nil {
+		proc.err = fmt.Errorf("plugin %s: closed", proc.plugin.Name)
+	}
+
+	return proc.stop()
+}
+
+// stop will close the plugin's stdin and wait for it to exit.
+func (proc *process) stop() error {
+	if proc.cmd == nil || proc.cmd.Process == nil {
+		return nil
+	}
+
+	cmd := proc.cmd
+	proc.cmd = nil
+
+	if proc.stdin != nil {
+		proc.stdin.Close()
+	}
+
+	return cmd.Wait()
+}
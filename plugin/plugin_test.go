@@ -0,0 +1,196 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"go/parser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// TestMain allows the test binary to be run as a plugin, which reports calls
+// to functions of net/http.
+func TestMain(m *testing.M) {
+	if os.Getenv("PEPPERLINT_TEST_PLUGIN") != "1" {
+		os.Exit(m.Run())
+	}
+
+	prefix := ""
+	s := Server{
+		Nodes: []string{"CallExpr"},
+		Init: func(config map[string]string) error {
+			if config["fail"] == "true" {
+				return errors.New("bad config")
+			}
+
+			prefix = config["prefix"]
+			return nil
+		},
+		Check: func(f File) ([]Diagnostic, error) {
+			diags := []Diagnostic{}
+			for _, node := range f.Nodes {
+				if !strings.HasPrefix(node.Name, "net/http.") {
+					continue
+				}
+
+				diags = append(diags, Diagnostic{
+					Pos:     node.Pos,
+					Message: prefix + node.Name,
+					Fixes: []Fix{
+						{
+							Message: "remove call",
+							Edits: []Edit{
+								{Pos: node.Pos, End: node.End, NewText: "nil"},
+							},
+						},
+					},
+				})
+			}
+
+			return diags, nil
+		},
+	}
+
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		os.Exit(1)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	defer os.Setenv("PEPPERLINT_TEST_PLUGIN", os.Getenv("PEPPERLINT_TEST_PLUGIN"))
+	os.Setenv("PEPPERLINT_TEST_PLUGIN", "1")
+
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "foo.go")
+	src := "package foo\n\nimport web \"net/http\"\n\nfunc foo() {\n\tweb.Get(\"a\")\n\tbar()\n}\n\nfunc bar() {}\n"
+
+	cases := []struct {
+		config   map[string]string
+		expected []string
+		fixes    []string
+		err      string
+	}{
+		{
+			config:   map[string]string{"prefix": "banned "},
+			expected: []string{"foo.go:6:2: banned net/http.Get"},
+			fixes:    []string{"6:2-6:14 nil"},
+		},
+		{
+			config: map[string]string{"fail": "true"},
+			err:    "plugin private/nohttp: bad config",
+		},
+	}
+
+	for _, c := range cases {
+		p := New("private/nohttp", os.Args[0], nil, c.config)
+
+		result, err := pepperlint.Run(context.Background(), pepperlint.Options{
+			Patterns: []string{dir},
+			Overlay: map[string][]byte{
+				filename: []byte(src),
+			},
+			Rules: []pepperlint.CopyRuler{p},
+		})
+		p.Close()
+
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		diags := []string{}
+		fixes := []string{}
+		errs := []string{}
+		for _, diag := range result.Diagnostics {
+			wrap, ok := diag.Err.(*pepperlint.ErrorWrap)
+			if !ok {
+				errs = append(errs, diag.Err.Error())
+				continue
+			}
+
+			if e, a := "private/nohttp", diag.Rule; e != a {
+				t.Errorf("expected %q, but received %q", e, a)
+			}
+
+			diags = append(diags, filepath.Base(diag.Pos.Filename)+":"+
+				strconv.Itoa(diag.Pos.Line)+":"+strconv.Itoa(diag.Pos.Column)+": "+wrap.Message())
+
+			for _, fix := range diag.Fixes() {
+				for _, edit := range fix.Edits {
+					fixes = append(fixes, strconv.Itoa(edit.Pos.Line)+":"+strconv.Itoa(edit.Pos.Column)+"-"+
+						strconv.Itoa(edit.End.Line)+":"+strconv.Itoa(edit.End.Column)+" "+edit.NewText)
+				}
+			}
+		}
+
+		if len(c.err) > 0 {
+			if len(errs) == 0 || !strings.Contains(errs[0], c.err) {
+				t.Errorf("expected error %q, but received %v", c.err, errs)
+			}
+
+			continue
+		}
+
+		if len(errs) > 0 {
+			t.Errorf("expected no errors, but received %v", errs)
+		}
+
+		if e, a := c.expected, diags; !reflect.DeepEqual(e, a) {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+
+		if e, a := c.fixes, fixes; !reflect.DeepEqual(e, a) {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+	}
+}
+
+func TestNodeName(t *testing.T) {
+	imports := map[string]string{"web": "net/http"}
+	cases := []struct {
+		node     string
+		expected string
+	}{
+		{"web.Get", "net/http.Get"},
+		{"web.Get()", "net/http.Get"},
+		{"client.Do()", "client.Do"},
+		{"a.b.c", "a.b.c"},
+		{"foo", "foo"},
+	}
+
+	for _, c := range cases {
+		expr, err := parser.ParseExpr(c.node)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e, a := c.expected, nodeName(expr, imports); e != a {
+			t.Errorf("%s: expected %q, but received %q", c.node, e, a)
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	a := New("private/nohttp", os.Args[0], nil, map[string]string{"a": "1", "b": "2"})
+	b := New("private/nohttp", os.Args[0], nil, map[string]string{"b": "2", "a": "1"})
+	c := New("private/nohttp", os.Args[0], nil, map[string]string{"a": "2", "b": "2"})
+
+	if a.CacheKey() != b.CacheKey() {
+		t.Errorf("expected keys to match, %q and %q", a.CacheKey(), b.CacheKey())
+	}
+
+	if a.CacheKey() == c.CacheKey() {
+		t.Errorf("expected keys to differ, %q", a.CacheKey())
+	}
+}
@@ -0,0 +1,138 @@
+// Package plugin allows rules to be implemented by executables that run
+// outside of pepperlint, so rules can be added without rebuilding pepperlint.
+//
+// A plugin is started once and is sent one JSON message per line on its stdin,
+// to which it replies with one JSON message per line on its stdout. The first
+// message is an init request, and the plugin replies with the node types it
+// wants to be sent. Every other message is a file request containing a linted
+// file along with its nodes of those types, and the plugin replies with the
+// diagnostics of the file. The plugin should exit once its stdin is closed.
+//
+//	-> {"type":"init","version":1,"name":"private/nohttp","config":{"strict":"true"}}
+//	<- {"nodes":["CallExpr"]}
+//	-> {"type":"file","file":{"filename":"/src/foo/foo.go","source":"package foo...","nodes":[...]}}
+//	<- {"diagnostics":[{"pos":{"line":10,"column":2},"message":"http.Get is not allowed"}]}
+//
+// Plugins written in Go can use Server, which implements the protocol.
+package plugin
+
+import (
+	"go/token"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// Version is the version of the protocol, which is sent in the init request.
+const Version = 1
+
+// Request types
+const (
+	InitRequest = "init"
+	FileRequest = "file"
+)
+
+// Request is a message sent to a plugin. Config, Name and Version are only set
+// for init requests, and File is only set for file requests.
+type Request struct {
+	Type    string            `json:"type"`
+	Version int               `json:"version,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Config  map[string]string `json:"config,omitempty"`
+	File    *File             `json:"file,omitempty"`
+}
+
+// InitResponse is the reply to an init request. Nodes are the node types the
+// plugin wants to be sent, named after their go/ast type, such as "CallExpr".
+// If Error is set, the plugin is not used.
+type InitResponse struct {
+	Nodes []string `json:"nodes"`
+	Error string   `json:"error,omitempty"`
+}
+
+// File is a linted file.
+type File struct {
+	Filename   string `json:"filename"`
+	Package    string `json:"package"`
+	ImportPath string `json:"import_path"`
+	Test       bool   `json:"test"`
+	Generated  bool   `json:"generated"`
+
+	// Source is the contents of the file, which may not match what is on
+	// disk if the file is being edited.
+	Source string `json:"source"`
+
+	// Imports maps the name each package is imported as to its import path.
+	Imports map[string]string `json:"imports"`
+
+	// Nodes are the nodes of the file whose types the plugin asked for, in
+	// the order they appear in the file.
+	Nodes []Node `json:"nodes"`
+}
+
+// Node is a node of a file.
+type Node struct {
+	// Type is the node's go/ast type, such as "CallExpr".
+	Type string `json:"type"`
+
+	Pos Position `json:"pos"`
+	End Position `json:"end"`
+
+	// Name is the name of what the node refers to or declares, if it has one.
+	// Selectors of imported packages are qualified by import path, so a call
+	// to http.Get is named "net/http.Get". Function declarations are
+	// qualified by their receiver type, such as "Client.Do".
+	Name string `json:"name,omitempty"`
+}
+
+// Position is a position within a file. Offset is a 0 based byte offset, while
+// Line and Column are 1 based, with Column counted in bytes. Plugins only
+// need to set either Offset, or Line and Column.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// FileResponse is the reply to a file request. If Error is set, it is reported
+// in place of the diagnostics.
+type FileResponse struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// Diagnostic is a single issue found by a plugin.
+type Diagnostic struct {
+	Pos     Position `json:"pos"`
+	Message string   `json:"message"`
+	Fixes   []Fix    `json:"fixes,omitempty"`
+}
+
+// Fix is a set of edits that fix a diagnostic.
+type Fix struct {
+	Message string `json:"message"`
+	Edits   []Edit `json:"edits"`
+}
+
+// Edit replaces the text between Pos and End with NewText.
+type Edit struct {
+	Pos     Position `json:"pos"`
+	End     Position `json:"end"`
+	NewText string   `json:"new_text"`
+}
+
+// suggestedFix will convert a fix to a pepperlint fix.
+func (fix Fix) suggestedFix(position func(Position) token.Position) pepperlint.SuggestedFix {
+	suggested := pepperlint.SuggestedFix{
+		Message: fix.Message,
+	}
+
+	for _, edit := range fix.Edits {
+		suggested.Edits = append(suggested.Edits, pepperlint.TextEdit{
+			Pos:     position(edit.Pos),
+			End:     position(edit.End),
+			NewText: edit.NewText,
+		})
+	}
+
+	return suggested
+}
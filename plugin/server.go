@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Server implements the plugin side of the protocol.
+//
+//	func main() {
+//		s := plugin.Server{
+//			Nodes: []string{"CallExpr"},
+//			Check: check,
+//		}
+//
+//		if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+type Server struct {
+	// Nodes are the node types the plugin wants to be sent.
+	Nodes []string
+
+	// Init is called with the plugin's configuration before any file is
+	// checked. If an error is returned, the plugin is not used.
+	Init func(config map[string]string) error
+
+	// Check returns the diagnostics of a file.
+	Check func(File) ([]Diagnostic, error)
+}
+
+// Serve will read requests from r and write replies to w until r is closed.
+func (s Server) Serve(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			return nil
+		}
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		req := Request{}
+		if err := json.Unmarshal(line, &req); err != nil {
+			return fmt.Errorf("unable to decode request: %v", err)
+		}
+
+		if err := enc.Encode(s.reply(req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s Server) reply(req Request) interface{} {
+	switch req.Type {
+	case InitRequest:
+		if req.Version != Version {
+			return InitResponse{
+				Error: fmt.Sprintf("unsupported protocol version %d", req.Version),
+			}
+		}
+
+		if s.Init != nil {
+			if err := s.Init(req.Config); err != nil {
+				return InitResponse{Error: err.Error()}
+			}
+		}
+
+		return InitResponse{Nodes: s.Nodes}
+	case FileRequest:
+		if req.File == nil || s.Check == nil {
+			return FileResponse{}
+		}
+
+		diags, err := s.Check(*req.File)
+		if err != nil {
+			return FileResponse{Error: err.Error()}
+		}
+
+		return FileResponse{Diagnostics: diags}
+	}
+
+	return FileResponse{
+		Error: fmt.Sprintf("unknown request type %q", req.Type),
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !go1.18
+// +build !go1.18
+
+package pepperlint
+
+import "go/ast"
+
+// indexListX will return false, since receivers cannot have more than one type
+// parameter before go1.18.
+func indexListX(expr ast.Expr) (ast.Expr, bool) {
+	return nil, false
+}
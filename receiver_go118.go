@@ -0,0 +1,16 @@
+//go:build go1.18
+// +build go1.18
+
+package pepperlint
+
+import "go/ast"
+
+// indexListX will return the type of a receiver with more than one type
+// parameter, such as Foo[T, U].
+func indexListX(expr ast.Expr) (ast.Expr, bool) {
+	if t, ok := expr.(*ast.IndexListExpr); ok {
+		return t.X, true
+	}
+
+	return nil, false
+}
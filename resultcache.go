@@ -0,0 +1,326 @@
+package pepperlint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// CacheKeyer can be implemented by a rule to set the key its configuration is
+// cached under. Otherwise, the rule's type and its exported fields are used.
+// Diagnostics are only reused by runs with the same rules and keys.
+type CacheKeyer interface {
+	CacheKey() string
+}
+
+// fileHash contains the hashes of a file's contents. The export hash ignores
+// function bodies, so it only changes when the file's declarations change.
+type fileHash struct {
+	content [sha256.Size]byte
+	export  [sha256.Size]byte
+}
+
+// newFileHash will hash the source of f.
+func newFileHash(fset *token.FileSet, f *ast.File, src []byte) fileHash {
+	h := fileHash{
+		content: sha256.Sum256(src),
+	}
+
+	tf := fset.File(f.Pos())
+	if tf == nil || tf.Size() != len(src) {
+		h.export = h.content
+		return h
+	}
+
+	export := sha256.New()
+	last := 0
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		start, end := tf.Offset(fn.Body.Lbrace), tf.Offset(fn.Body.Rbrace)+1
+		if start < last || end > len(src) {
+			continue
+		}
+
+		export.Write(src[last:start])
+		last = end
+	}
+	export.Write(src[last:])
+	copy(h.export[:], export.Sum(nil))
+
+	return h
+}
+
+// declarationHash will return the hash of a file that only contains
+// declarations, as returned by declarationSource.
+func declarationHash(src []byte) fileHash {
+	sum := sha256.Sum256(src)
+	return fileHash{
+		content: sum,
+		export:  sum,
+	}
+}
+
+// rulesKey will return the key of the configuration of every rule.
+func rulesKey(rules []CopyRuler) (string, error) {
+	h := sha256.New()
+	for _, rule := range rules {
+		fmt.Fprintf(h, "%T\n", rule)
+
+		if keyer, ok := rule.(CacheKeyer); ok {
+			fmt.Fprintf(h, "%s\n", keyer.CacheKey())
+			continue
+		}
+
+		b, err := json.Marshal(rule)
+		if err != nil {
+			return "", fmt.Errorf("unable to build cache key of rule %T: %v", rule, err)
+		}
+		fmt.Fprintf(h, "%s\n", b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resultKeys will return the key of the diagnostics of each of the packages.
+// A package's key is built from the contents of its files, the export hashes
+// of every package it imports, directly or not, and the rules key.
+func (set *packageSet) resultKeys(pkgs []*ast.Package, rulesKey string, opts Options) []string {
+	byImportPath := map[string][]*ast.Package{}
+	for _, pkg := range append(sortedPackages(set.include), sortedPackages(set.lint)...) {
+		importPath := GetImportPathFromPackage(pkg)
+		byImportPath[importPath] = append(byImportPath[importPath], pkg)
+	}
+
+	fingerprints := map[string]string{}
+	var fingerprint func(importPath string) string
+	fingerprint = func(importPath string) string {
+		if fp, ok := fingerprints[importPath]; ok {
+			return fp
+		}
+
+		// guards against import cycles, which only exist in broken code
+		fingerprints[importPath] = ""
+
+		h := sha256.New()
+		for _, pkg := range byImportPath[importPath] {
+			set.writeHashes(h, pkg, true)
+		}
+
+		for _, dep := range packageImports(byImportPath[importPath]...) {
+			fmt.Fprintf(h, "%s %s\n", strconv.Quote(dep), fingerprint(dep))
+		}
+
+		fingerprints[importPath] = hex.EncodeToString(h.Sum(nil))
+		return fingerprints[importPath]
+	}
+
+	keys := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		h := sha256.New()
+		fmt.Fprintf(h, "pepperlint %s\nrules %s\ngenerated %t\n", Version, rulesKey, opts.IncludeGenerated)
+		fmt.Fprintf(h, "package %s %s\n", pkg.Name, GetImportPathFromPackage(pkg))
+		set.writeHashes(h, pkg, false)
+
+		for _, dep := range packageImports(pkg) {
+			fmt.Fprintf(h, "%s %s\n", strconv.Quote(dep), fingerprint(dep))
+		}
+
+		keys[i] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return keys
+}
+
+// writeHashes will write the hashes of every file of pkg to h, in order of the
+// file names.
+func (set *packageSet) writeHashes(h hash.Hash, pkg *ast.Package, export bool) {
+	filenames := make([]string, 0, len(pkg.Files))
+	for filename := range pkg.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		fh, ok := set.hashes[pkg.Files[filename]]
+		if !ok {
+			fh = set.includeHashes[pkg.Files[filename]]
+		}
+		sum := fh.content
+		if export {
+			sum = fh.export
+		}
+
+		fmt.Fprintf(h, "%s %x\n", filepath.Base(filename), sum)
+	}
+}
+
+// packageImports will return the sorted import paths of every file of the
+// packages.
+func packageImports(pkgs ...*ast.Package) []string {
+	seen := map[string]struct{}{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, spec := range f.Imports {
+				importPath, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+
+				seen[importPath] = struct{}{}
+			}
+		}
+	}
+
+	imports := make([]string, 0, len(seen))
+	for importPath := range seen {
+		imports = append(imports, importPath)
+	}
+	sort.Strings(imports)
+
+	return imports
+}
+
+// resultCacheEntry contains the diagnostics of a single package.
+type resultCacheEntry struct {
+	Version     string             `json:"version"`
+	Diagnostics []cachedDiagnostic `json:"diagnostics"`
+}
+
+type cachedDiagnostic struct {
+	Pos     token.Position `json:"pos"`
+	End     token.Position `json:"end"`
+	Rule    string         `json:"rule"`
+	Message string         `json:"message"`
+	Fixes   []SuggestedFix `json:"fixes,omitempty"`
+}
+
+func (c *DiskCache) resultPath(key string) string {
+	return filepath.Join(c.Dir, "results", key[:2], key)
+}
+
+// getResult will return the errors that were stored under key. False is
+// returned if there are none.
+func (c *DiskCache) getResult(key string, logger Logger) (Errors, bool) {
+	b, err := ioutil.ReadFile(c.resultPath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	entry := resultCacheEntry{}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		logger.Log(WarnLevel, fmt.Sprintf("unable to read result cache entry %s: %v", key, err))
+		return nil, false
+	}
+
+	if entry.Version != Version {
+		return nil, false
+	}
+
+	errs := Errors{}
+	for _, diag := range entry.Diagnostics {
+		errWrap := NewErrorWrapAt(diag.Pos, diag.Message).WithEnd(diag.End)
+		for _, fix := range diag.Fixes {
+			errWrap.WithFix(fix)
+		}
+
+		errs = append(errs, NewRuleBatchError(diag.Rule, errWrap))
+	}
+
+	return errs, true
+}
+
+// putResult will store the diagnostics of errs under key. Only diagnostics
+// that are made of an ErrorWrap can be restored exactly, so nothing is stored
+// if errs contains any other error, such as an InternalError.
+func (c *DiskCache) putResult(key string, errs Errors) error {
+	entry := resultCacheEntry{
+		Version: Version,
+	}
+
+	for _, diag := range errs.Diagnostics() {
+		errWrap, ok := diag.Err.(*ErrorWrap)
+		if !ok {
+			return nil
+		}
+
+		entry.Diagnostics = append(entry.Diagnostics, cachedDiagnostic{
+			Pos:     diag.Pos,
+			End:     diag.End,
+			Rule:    diag.Rule,
+			Message: errWrap.Message(),
+			Fixes:   errWrap.Fixes(),
+		})
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return c.write(c.resultPath(key), b)
+}
+
+// DiskCacheStats contains the number of entries and size of a disk cache.
+type DiskCacheStats struct {
+	Packages int
+	Results  int
+	Size     int64
+}
+
+// Stats will return the number of entries in the cache and their total size.
+func (c *DiskCache) Stats() (DiskCacheStats, error) {
+	stats := DiskCacheStats{}
+	counts := map[string]*int{
+		"pkg":     &stats.Packages,
+		"results": &stats.Results,
+	}
+
+	for dir, count := range counts {
+		err := filepath.Walk(filepath.Join(c.Dir, dir), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			if info.Mode().IsRegular() {
+				*count++
+				stats.Size += info.Size()
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// Clean will remove every entry of the cache.
+func (c *DiskCache) Clean() error {
+	for _, dir := range []string{"pkg", "results"} {
+		if err := os.RemoveAll(filepath.Join(c.Dir, dir)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
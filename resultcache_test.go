@@ -0,0 +1,188 @@
+package pepperlint
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunResultCache(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", gopath)
+
+	writeFile := func(name, content string) {
+		filename := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("a/a.go", "package a\n\nimport \"b\"\n\ntype A struct {\n\tA b.B\n}\n")
+	writeFile("b/b.go", "package b\n\ntype B struct {\n\tB int\n}\n\nfunc f() {}\n")
+
+	diskCache := NewDiskCache(filepath.Join(gopath, "cache"))
+	opts := Options{
+		Patterns:  []string{filepath.Join(gopath, "src", "...")},
+		Rules:     []CopyRuler{&testFieldPositions{}},
+		DiskCache: diskCache,
+	}
+
+	cases := []struct {
+		name           string
+		change         func()
+		rules          []CopyRuler
+		expectedCached int
+		expectedFields []string
+	}{
+		{
+			name:           "cold",
+			expectedFields: []string{"A", "B"},
+		},
+		{
+			name:           "warm",
+			expectedCached: 2,
+			expectedFields: []string{"A", "B"},
+		},
+		{
+			name: "function body changed",
+			change: func() {
+				writeFile("b/b.go", "package b\n\ntype B struct {\n\tB int\n}\n\nfunc f() { f() }\n")
+			},
+			expectedCached: 1,
+			expectedFields: []string{"A", "B"},
+		},
+		{
+			name: "declaration changed",
+			change: func() {
+				writeFile("b/b.go", "package b\n\ntype B struct {\n\tB int\n\tC int\n}\n\nfunc f() { f() }\n")
+			},
+			expectedFields: []string{"A", "B", "C"},
+		},
+		{
+			name:           "rules changed",
+			rules:          []CopyRuler{&testFieldPositions{}, &testTestFiles{}},
+			expectedFields: []string{"A", "B", "C"},
+		},
+	}
+
+	for _, c := range cases {
+		if c.change != nil {
+			c.change()
+		}
+
+		runOpts := opts
+		if c.rules != nil {
+			runOpts.Rules = c.rules
+		}
+
+		result, err := Run(context.Background(), runOpts)
+		if err != nil {
+			t.Fatalf("%s: expected no error, but received %v", c.name, err)
+		}
+
+		if e, a := 2, result.Packages; e != a {
+			t.Errorf("%s: expected %v, but received %v", c.name, e, a)
+		}
+
+		if e, a := c.expectedCached, result.CachedPackages; e != a {
+			t.Errorf("%s: expected %v, but received %v", c.name, e, a)
+		}
+
+		fields := []string{}
+		for _, diag := range result.Diagnostics {
+			fields = append(fields, diag.Err.(*ErrorWrap).Message())
+
+			if e, a := "*pepperlint.testFieldPositions", diag.Rule; e != a {
+				t.Errorf("%s: expected %v, but received %v", c.name, e, a)
+			}
+		}
+
+		if e, a := c.expectedFields, fields; !reflect.DeepEqual(e, a) {
+			t.Errorf("%s: expected %v, but received %v", c.name, e, a)
+		}
+	}
+
+	stats, err := diskCache.Stats()
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	// every package that was linted has its own entry
+	if e, a := 7, stats.Results; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	if stats.Size == 0 {
+		t.Errorf("expected size to be set")
+	}
+
+	if err := diskCache.Clean(); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if stats, err = diskCache.Stats(); err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := (DiskCacheStats{}), stats; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestRunResultCacheFixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "foo.go")
+	if err := ioutil.WriteFile(filename, []byte("package foo\n\ntype Foo struct {\n\tbar int\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{
+		Patterns:  []string{filename},
+		Rules:     []CopyRuler{&testFieldFixes{}},
+		DiskCache: NewDiskCache(filepath.Join(dir, "cache")),
+	}
+
+	fixes := [][]SuggestedFix{}
+	for i := 0; i < 2; i++ {
+		result, err := Run(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		if e, a := i, result.CachedPackages; e != a {
+			t.Errorf("expected %d cached packages, but received %d", e, a)
+		}
+
+		if e, a := 1, len(result.Diagnostics); e != a {
+			t.Fatalf("expected %d diagnostics, but received %d", e, a)
+		}
+
+		fixes = append(fixes, result.Diagnostics[0].Fixes())
+	}
+
+	if e, a := "BAR", fixes[0][0].Edits[0].NewText; e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	if e, a := fixes[0], fixes[1]; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected cached fixes %v, but received %v", e, a)
+	}
+}
@@ -1,7 +1,12 @@
 package pepperlint
 
+import (
+	"reflect"
+)
+
 // Rules contain a set of all rule types that will be ran
-// during visitation.
+// during visitation. There is one list per node type, declared in the same
+// order as the node types.
 type Rules struct {
 	PackageRules PackageRules
 	FileRules    FileRules
@@ -40,28 +45,62 @@ type Rules struct {
 	MapTypeRules   MapTypeRules
 }
 
+// ruleListType will return the node type of the rule list at index i of
+// Rules. Rules has one list per node type, declared in the same order as the
+// node types, so the lists can be walked as a table rather than by name.
+func ruleListType(i int) NodeType {
+	return 1 << uint(i)
+}
+
 // Merge will merge two rule sets together.
 func (r *Rules) Merge(otherRules Rules) *Rules {
-	r.PackageRules = append(r.PackageRules, otherRules.PackageRules...)
-	r.FuncDeclRules = append(r.FuncDeclRules, otherRules.FuncDeclRules...)
-	r.GenDeclRules = append(r.GenDeclRules, otherRules.GenDeclRules...)
-	r.TypeSpecRules = append(r.TypeSpecRules, otherRules.TypeSpecRules...)
-	r.ValueSpecRules = append(r.ValueSpecRules, otherRules.ValueSpecRules...)
-	r.AssignStmtRules = append(r.AssignStmtRules, otherRules.AssignStmtRules...)
-	r.BlockStmtRules = append(r.BlockStmtRules, otherRules.BlockStmtRules...)
-	r.IncDecStmtRules = append(r.IncDecStmtRules, otherRules.IncDecStmtRules...)
-	r.RangeStmtRules = append(r.RangeStmtRules, otherRules.RangeStmtRules...)
-	r.StructTypeRules = append(r.StructTypeRules, otherRules.StructTypeRules...)
-	r.FieldRules = append(r.FieldRules, otherRules.FieldRules...)
-	r.FuncTypeRules = append(r.FuncTypeRules, otherRules.FuncTypeRules...)
-	r.InterfaceTypeRules = append(r.InterfaceTypeRules, otherRules.InterfaceTypeRules...)
-	r.ArrayTypeRules = append(r.ArrayTypeRules, otherRules.ArrayTypeRules...)
-	r.ChanTypeRules = append(r.ChanTypeRules, otherRules.ChanTypeRules...)
-	r.MapTypeRules = append(r.MapTypeRules, otherRules.MapTypeRules...)
-	r.CallExprRules = append(r.CallExprRules, otherRules.CallExprRules...)
-	r.BinaryExprRules = append(r.BinaryExprRules, otherRules.BinaryExprRules...)
-	r.ReturnStmtRules = append(r.ReturnStmtRules, otherRules.ReturnStmtRules...)
-	r.FileRules = append(r.FileRules, otherRules.FileRules...)
+	lists := reflect.ValueOf(r).Elem()
+	others := reflect.ValueOf(otherRules)
+	for i := 0; i < lists.NumField(); i++ {
+		list := lists.Field(i)
+		list.Set(reflect.AppendSlice(list, others.Field(i)))
+	}
 
 	return r
 }
+
+// Register will add the rule to every rule list whose interface the rule
+// satisfies. If the rule is a NodeFilter, it will only be added to the lists
+// of the node types it returns.
+func (r *Rules) Register(rule Rule) *Rules {
+	if rule == nil {
+		return r
+	}
+
+	types := AllNodes
+	if filter, ok := rule.(NodeFilter); ok {
+		types = filter.NodeTypes()
+	}
+
+	v := reflect.ValueOf(rule)
+	lists := reflect.ValueOf(r).Elem()
+	for i := 0; i < lists.NumField(); i++ {
+		list := lists.Field(i)
+		if !types.Has(ruleListType(i)) || !v.Type().Implements(list.Type().Elem()) {
+			continue
+		}
+
+		list.Set(reflect.Append(list, v))
+	}
+
+	return r
+}
+
+// eachList will call fn with every rule list and the node type it is
+// validated against.
+func (r Rules) eachList(fn func(NodeType, []Rule)) {
+	lists := reflect.ValueOf(r)
+	for i := 0; i < lists.NumField(); i++ {
+		list := make([]Rule, lists.Field(i).Len())
+		for j := range list {
+			list[j] = lists.Field(i).Index(j).Interface()
+		}
+
+		fn(ruleListType(i), list)
+	}
+}
@@ -73,6 +73,7 @@ type DynamoDBExpressionRule struct {
 
 	fset   *token.FileSet
 	helper pepperlint.Helper
+	logger pepperlint.Logger
 }
 
 // NewDynamoDBExpressionRule returns a new rule with the given token.FileSet
@@ -130,7 +131,7 @@ func (r DynamoDBExpressionRule) ValidateAssignStmt(stmt *ast.AssignStmt) error {
 				}
 			}
 		default:
-			pepperlint.Log("TODO: dynamodb_expression_rule ValidateAssignStmt: %T", t)
+			pepperlint.Unhandled(r.logger, r.fset, "DynamoDBExpressionRule.ValidateAssignStmt", t)
 		}
 	}
 
@@ -193,20 +194,18 @@ func (r DynamoDBExpressionRule) IsUsingExpression(obj object, elt ast.Expr) []as
 
 		return r.IsUsingExpression(v, eltType.Value)
 	default:
-		pepperlint.Log("TODO: dynamodb_expression_rules.IsUsingExpression %T", eltType)
+		pepperlint.Unhandled(r.logger, r.fset, "DynamoDBExpressionRule.IsUsingExpression", eltType)
 	}
 
 	return nil
 }
 
-// AddRules will add the DeprecatedFieldRule to the given visitor
+// AddRules will register the DynamoDBExpressionRule with the given rules.
+//
+// Deprecated: Rules are added by the interfaces they implement, use
+// Rules.Register instead.
 func (r *DynamoDBExpressionRule) AddRules(visitorRules *pepperlint.Rules) {
-	rules := pepperlint.Rules{
-		AssignStmtRules: pepperlint.AssignStmtRules{r},
-		FileRules:       pepperlint.FileRules{r},
-	}
-
-	visitorRules.Merge(rules)
+	visitorRules.Register(r)
 }
 
 // WithCache will create a new helper with the given cache. This is used
@@ -276,17 +275,17 @@ func (r DynamoDBExpressionRule) UsingExpressionsPackage(expr ast.Expr) bool {
 				}
 
 				rhs := decl.Rhs[fieldIndex]
-				if hasExpressionBuilder(pkgName, rhs) {
+				if r.hasExpressionBuilder(pkgName, rhs) {
 					return true
 				}
 			}
 
 			return false
 		default:
-			pepperlint.Log("TODO: dynamodb_expression_rules.UsingExpressionsPackage %T", fun)
+			pepperlint.Unhandled(r.logger, r.fset, "DynamoDBExpressionRule.UsingExpressionsPackage", fun)
 		}
 	default:
-		pepperlint.Log("TODO: dynamodb_expression_rules.UsingExpressionsPackage %T", t)
+		pepperlint.Unhandled(r.logger, r.fset, "DynamoDBExpressionRule.UsingExpressionsPackage", t)
 	}
 
 	return false
@@ -302,6 +301,12 @@ func (r *DynamoDBExpressionRule) WithFileSet(fset *token.FileSet) {
 	r.fset = fset
 }
 
+// WithLogger sets the logger that nodes the rule does not handle are logged
+// to.
+func (r *DynamoDBExpressionRule) WithLogger(logger pepperlint.Logger) {
+	r.logger = logger
+}
+
 // getExpressoinsPkgName will attempt to get the package name of the dynamodb.expressions
 // package. This needs to be done due to local overrides of package names. If a dynamodb import
 // path could not be found in the pepperlint.File, then false will be returned.
@@ -321,14 +326,14 @@ func getExpressionsPkgName(f *pepperlint.File) (string, bool) {
 
 // hasExpressionBuilder will iterate through the expr AST and check to see if
 // an expression builder is within it.
-func hasExpressionBuilder(pkgName string, expr ast.Expr) bool {
+func (r DynamoDBExpressionRule) hasExpressionBuilder(pkgName string, expr ast.Expr) bool {
 	switch t := expr.(type) {
 	case *ast.CallExpr:
-		return hasExpressionBuilder(pkgName, t.Fun)
+		return r.hasExpressionBuilder(pkgName, t.Fun)
 	case *ast.SelectorExpr:
 		ident, ok := t.X.(*ast.Ident)
 		if !ok {
-			return hasExpressionBuilder(pkgName, t.X)
+			return r.hasExpressionBuilder(pkgName, t.X)
 		}
 
 		if ident.Name != pkgName {
@@ -343,7 +348,7 @@ func hasExpressionBuilder(pkgName string, expr ast.Expr) bool {
 		// that is being used to generate expressions.
 		return true
 	default:
-		pepperlint.Log("TODO: dynamodb_expression_rules.hasExpressionBuilder %T", t)
+		pepperlint.Unhandled(r.logger, r.fset, "DynamoDBExpressionRule.hasExpressionBuilder", t)
 	}
 
 	return false
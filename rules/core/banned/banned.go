@@ -0,0 +1,448 @@
+// Package banned contains a rule that reports uses of selectors and imports
+// that are listed in its configuration, which allows for most "do not use X"
+// rules to be written without any Go code.
+package banned
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// Selector is a function, type, variable, method or field that is not allowed
+// to be used. Selectors are written as the import path of a package followed
+// by the name within it, such as "net/http.Get", "net/http.Client.Do" or
+// "net/http.Request.Body".
+type Selector struct {
+	Selector string `yaml:"selector"`
+
+	// Message replaces the default message of the diagnostic.
+	Message string `yaml:"message"`
+
+	// Replacement is suggested as a fix. It is written like Selector, and is
+	// only suggested for package level selectors when the file already
+	// imports its package. For methods and fields, only the last name of the
+	// replacement is used.
+	Replacement string `yaml:"replacement"`
+
+	Scope `yaml:",inline"`
+}
+
+// Import is a package that is not allowed to be imported.
+type Import struct {
+	Path string `yaml:"path"`
+
+	// Message replaces the default message of the diagnostic.
+	Message string `yaml:"message"`
+
+	// Replacement is the import path suggested as a fix.
+	Replacement string `yaml:"replacement"`
+
+	Scope `yaml:",inline"`
+}
+
+// Scope restricts which packages a selector or import is banned in. Packages
+// are import paths, and a trailing "/..." matches every package below a path.
+type Scope struct {
+	// Packages are the packages the ban applies to. If it is empty, the ban
+	// applies to every package.
+	Packages []string `yaml:"packages"`
+
+	// Allow are the packages that are exempt from the ban.
+	Allow []string `yaml:"allow"`
+}
+
+// applies will return true if the ban applies to the package of importPath.
+func (s Scope) applies(importPath string) bool {
+	importPath = strings.TrimSuffix(importPath, "_test")
+
+	for _, pattern := range s.Allow {
+		if matchPackage(pattern, importPath) {
+			return false
+		}
+	}
+
+	if len(s.Packages) == 0 {
+		return true
+	}
+
+	for _, pattern := range s.Packages {
+		if matchPackage(pattern, importPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchPackage(pattern, importPath string) bool {
+	if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+		return importPath == prefix || strings.HasPrefix(importPath, prefix+"/")
+	}
+
+	return pattern == importPath
+}
+
+// selectorKey is a selector split into the import path of its package and the
+// names within it.
+type selectorKey struct {
+	importPath string
+	name       string
+	member     string
+}
+
+// parseSelector will split a selector, such as "net/http.Client.Do", into its
+// import path and names.
+func parseSelector(selector string) (selectorKey, error) {
+	slash := strings.LastIndex(selector, "/")
+	parts := strings.Split(selector[slash+1:], ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return selectorKey{}, fmt.Errorf("invalid selector %q", selector)
+	}
+
+	for _, part := range parts {
+		if len(part) == 0 {
+			return selectorKey{}, fmt.Errorf("invalid selector %q", selector)
+		}
+	}
+
+	key := selectorKey{
+		importPath: selector[:slash+1] + parts[0],
+		name:       parts[1],
+	}
+
+	if len(parts) == 3 {
+		key.member = parts[2]
+	}
+
+	return key, nil
+}
+
+// Rule reports every use of its selectors and imports.
+type Rule struct {
+	name      string
+	selectors map[selectorKey]Selector
+	imports   []Import
+	key       string
+
+	fset  *token.FileSet
+	cache *pepperlint.Cache
+}
+
+// New will return a rule named name that bans selectors and imports. An error is
+// returned if a selector or replacement is not valid.
+func New(name string, selectors []Selector, imports []Import) (*Rule, error) {
+	r := &Rule{
+		name:      name,
+		selectors: map[selectorKey]Selector{},
+		imports:   imports,
+	}
+
+	for _, s := range selectors {
+		key, err := parseSelector(s.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(s.Replacement) > 0 && len(key.member) == 0 {
+			if _, err := parseSelector(s.Replacement); err != nil {
+				return nil, err
+			}
+		}
+
+		r.selectors[key] = s
+	}
+
+	for _, imp := range imports {
+		if len(imp.Path) == 0 {
+			return nil, fmt.Errorf("banned imports must have a path")
+		}
+	}
+
+	// the key is built from the config as it was given, so it does not depend
+	// on how selectors are stored
+	key, err := json.Marshal(struct {
+		Name      string
+		Selectors []Selector
+		Imports   []Import
+	}{name, selectors, imports})
+	if err != nil {
+		return nil, err
+	}
+	r.key = string(key)
+
+	return r, nil
+}
+
+// CopyRule satisfies the pepperlint.CopyRuler interface.
+func (r *Rule) CopyRule() pepperlint.Rule {
+	return &Rule{
+		name:      r.name,
+		selectors: r.selectors,
+		imports:   r.imports,
+		key:       r.key,
+	}
+}
+
+// RuleName satisfies the pepperlint.RuleNamer interface.
+func (r *Rule) RuleName() string {
+	return r.name
+}
+
+// CacheKey satisfies the pepperlint.CacheKeyer interface.
+func (r *Rule) CacheKey() string {
+	return r.key
+}
+
+// NodeTypes satisfies the pepperlint.NodeFilter interface. Files are walked by
+// the rule itself, since selectors and imports are not validated on their own.
+func (r *Rule) NodeTypes() pepperlint.NodeType {
+	return pepperlint.FileNode
+}
+
+// WithFileSet satisfies the pepperlint.FileSetOption interface.
+func (r *Rule) WithFileSet(fset *token.FileSet) {
+	r.fset = fset
+}
+
+// WithCache satisfies the pepperlint.CacheOption interface.
+func (r *Rule) WithCache(cache *pepperlint.Cache) {
+	r.cache = cache
+}
+
+// ValidateFile will report every banned import and selector used in f.
+func (r *Rule) ValidateFile(f *ast.File) error {
+	file, ok := r.cache.CurrentFile()
+	if !ok || file.ASTFile != f {
+		return nil
+	}
+
+	importPath := r.cache.CurrentPkgImportPath
+	batchError := pepperlint.NewBatchError()
+
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch t := node.(type) {
+		case *ast.ImportSpec:
+			if err := r.validateImport(t, importPath); err != nil {
+				batchError.Add(err)
+			}
+
+			return false
+		case *ast.SelectorExpr:
+			if err := r.validateSelector(t, file.Imports, importPath); err != nil {
+				batchError.Add(err)
+			}
+		}
+
+		return true
+	})
+
+	return batchError.Return()
+}
+
+func (r *Rule) validateImport(spec *ast.ImportSpec, importPath string) error {
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return nil
+	}
+
+	for _, imp := range r.imports {
+		if !matchPackage(imp.Path, path) || !imp.applies(importPath) {
+			continue
+		}
+
+		msg := imp.Message
+		if len(msg) == 0 {
+			msg = fmt.Sprintf("import of %q is not allowed", path)
+			if len(imp.Replacement) > 0 {
+				msg += fmt.Sprintf(", use %q instead", imp.Replacement)
+			}
+		}
+
+		err := pepperlint.NewErrorWrap(r.fset, spec.Path, msg)
+		if len(imp.Replacement) > 0 {
+			err.WithFix(pepperlint.SuggestedFix{
+				Message: fmt.Sprintf("import %q", imp.Replacement),
+				Edits: []pepperlint.TextEdit{
+					pepperlint.NewTextEdit(r.fset, spec.Path, strconv.Quote(imp.Replacement)),
+				},
+			})
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *Rule) validateSelector(sel *ast.SelectorExpr, imports map[string]string, importPath string) error {
+	key, node, ok := r.selectorKey(sel, imports)
+	if !ok {
+		return nil
+	}
+
+	s, ok := r.selectors[key]
+	if !ok || !s.applies(importPath) {
+		return nil
+	}
+
+	msg := s.Message
+	if len(msg) == 0 {
+		msg = fmt.Sprintf("use of %q is not allowed", s.Selector)
+		if len(s.Replacement) > 0 {
+			msg += fmt.Sprintf(", use %q instead", s.Replacement)
+		}
+	}
+
+	err := pepperlint.NewErrorWrap(r.fset, node, msg)
+	if text, ok := r.replacement(s, key, imports); ok {
+		err.WithFix(pepperlint.SuggestedFix{
+			Message: fmt.Sprintf("replace with %s", text),
+			Edits: []pepperlint.TextEdit{
+				pepperlint.NewTextEdit(r.fset, node, text),
+			},
+		})
+	}
+
+	return err
+}
+
+// selectorKey will return the key of what sel refers to, along with the node
+// that is reported. Package level selectors report the whole selector, while
+// methods and fields only report their name.
+func (r *Rule) selectorKey(sel *ast.SelectorExpr, imports map[string]string) (selectorKey, ast.Node, bool) {
+	if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+		if path, ok := imports[ident.Name]; ok {
+			return selectorKey{importPath: path, name: sel.Sel.Name}, sel, true
+		}
+	}
+
+	path, typeName, ok := typeOf(sel.X, imports, r.cache.CurrentPkgImportPath, 0)
+	if !ok {
+		return selectorKey{}, nil, false
+	}
+
+	return selectorKey{importPath: path, name: typeName, member: sel.Sel.Name}, sel.Sel, true
+}
+
+// replacement will return the text that replaces a banned selector, if a fix
+// can be suggested.
+func (r *Rule) replacement(s Selector, key selectorKey, imports map[string]string) (string, bool) {
+	if len(s.Replacement) == 0 {
+		return "", false
+	}
+
+	if len(key.member) > 0 {
+		i := strings.LastIndex(s.Replacement, ".")
+		return s.Replacement[i+1:], true
+	}
+
+	replacement, err := parseSelector(s.Replacement)
+	if err != nil {
+		return "", false
+	}
+
+	for name, path := range imports {
+		if path == replacement.importPath && name != "_" && name != "." {
+			text := name + "." + replacement.name
+			if len(replacement.member) > 0 {
+				text += "." + replacement.member
+			}
+
+			return text, true
+		}
+	}
+
+	return "", false
+}
+
+// maxTypeDepth limits how many declarations typeOf will follow to find the type
+// of an expression.
+const maxTypeDepth = 8
+
+// typeOf will return the import path and name of the type of expr, as far as
+// it can be determined from declarations within the file.
+func typeOf(expr ast.Expr, imports map[string]string, currentPath string, depth int) (string, string, bool) {
+	if depth > maxTypeDepth {
+		return "", "", false
+	}
+	depth++
+
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return typeOf(t.X, imports, currentPath, depth)
+	case *ast.StarExpr:
+		return typeOf(t.X, imports, currentPath, depth)
+	case *ast.UnaryExpr:
+		if t.Op == token.AND {
+			return typeOf(t.X, imports, currentPath, depth)
+		}
+	case *ast.CompositeLit:
+		return namedType(t.Type, imports, currentPath)
+	case *ast.Ident:
+		if t.Obj == nil {
+			return "", "", false
+		}
+
+		switch decl := t.Obj.Decl.(type) {
+		case *ast.Field:
+			return namedType(decl.Type, imports, currentPath)
+		case *ast.ValueSpec:
+			if decl.Type != nil {
+				return namedType(decl.Type, imports, currentPath)
+			}
+
+			for i, name := range decl.Names {
+				if name.Name == t.Name && len(decl.Values) == len(decl.Names) {
+					return typeOf(decl.Values[i], imports, currentPath, depth)
+				}
+			}
+		case *ast.AssignStmt:
+			if len(decl.Lhs) != len(decl.Rhs) {
+				return "", "", false
+			}
+
+			for i, lhs := range decl.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok && ident.Name == t.Name {
+					return typeOf(decl.Rhs[i], imports, currentPath, depth)
+				}
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// namedType will return the import path and name of a type expression, such as
+// *http.Client.
+func namedType(expr ast.Expr, imports map[string]string, currentPath string) (string, string, bool) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return namedType(t.X, imports, currentPath)
+	case *ast.ParenExpr:
+		return namedType(t.X, imports, currentPath)
+	case *ast.SelectorExpr:
+		ident, ok := t.X.(*ast.Ident)
+		if !ok || ident.Obj != nil {
+			return "", "", false
+		}
+
+		path, ok := imports[ident.Name]
+		return path, t.Sel.Name, ok
+	case *ast.Ident:
+		if t.Obj == nil {
+			return "", "", false
+		}
+
+		if _, ok := t.Obj.Decl.(*ast.TypeSpec); ok {
+			return strings.TrimSuffix(currentPath, "_test"), t.Name, true
+		}
+	}
+
+	return "", "", false
+}
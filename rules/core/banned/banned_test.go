@@ -0,0 +1,146 @@
+package banned_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-toolset/pepperlint"
+	"github.com/go-toolset/pepperlint/rules/core/banned"
+)
+
+const code = `package bar
+
+import (
+	"io/ioutil"
+	web "net/http"
+	"os"
+	"unsafe"
+)
+
+type client struct {
+	c *web.Client
+}
+
+func foo(c *web.Client, req *web.Request) {
+	web.Get("a")
+	ioutil.ReadFile("a")
+	os.Exit(1)
+
+	c.Do(req)
+	_ = req.Body
+
+	resp := &web.Response{}
+	_ = resp.Body
+
+	var other web.Client
+	other.Do(req)
+
+	_ = unsafe.Pointer(nil)
+}
+`
+
+func TestRule(t *testing.T) {
+	selectors := []banned.Selector{
+		{Selector: "net/http.Get", Message: "use the shared client"},
+		{Selector: "io/ioutil.ReadFile", Replacement: "os.ReadFile"},
+		{Selector: "net/http.Client.Do", Replacement: "net/http.Client.DoContext"},
+		{Selector: "net/http.Request.Body"},
+		{Selector: "net/http.Response.Body", Scope: banned.Scope{Packages: []string{"example.com/other/..."}}},
+		{Selector: "os.Exit", Scope: banned.Scope{Allow: []string{"example.com/foo/..."}}},
+	}
+	imports := []banned.Import{
+		{Path: "unsafe", Replacement: "example.com/foo/safe", Scope: banned.Scope{Packages: []string{"example.com/foo/..."}}},
+	}
+
+	rule, err := banned.New("team/banned", selectors, imports)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "bar.go", code, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := pepperlint.NewCache()
+	cache.CurrentPkgImportPath = "example.com/foo/bar"
+	ast.Walk(cache, f)
+
+	v := pepperlint.NewVisitor(fset, cache, rule.CopyRule())
+	ast.Walk(v, f)
+
+	expected := []string{
+		`7:2: import of "unsafe" is not allowed, use "example.com/foo/safe" instead [import "example.com/foo/safe"]`,
+		`15:2: use the shared client`,
+		`16:2: use of "io/ioutil.ReadFile" is not allowed, use "os.ReadFile" instead [replace with os.ReadFile]`,
+		`19:4: use of "net/http.Client.Do" is not allowed, use "net/http.Client.DoContext" instead [replace with DoContext]`,
+		`20:10: use of "net/http.Request.Body" is not allowed`,
+		`26:8: use of "net/http.Client.Do" is not allowed, use "net/http.Client.DoContext" instead [replace with DoContext]`,
+	}
+
+	actual := []string{}
+	for _, diag := range v.Errors.Diagnostics() {
+		s := fmt.Sprintf("%d:%d: %s", diag.Pos.Line, diag.Pos.Column, diag.Err.(*pepperlint.ErrorWrap).Message())
+		for _, fix := range diag.Fixes() {
+			s += " [" + fix.Message + "]"
+		}
+
+		if e, a := "team/banned", diag.Rule; e != a {
+			t.Errorf("expected %q, but received %q", e, a)
+		}
+
+		actual = append(actual, s)
+	}
+
+	if e, a := expected, actual; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected\n%v\nbut received\n%v", e, a)
+	}
+}
+
+func TestNewInvalidSelector(t *testing.T) {
+	cases := []banned.Selector{
+		{Selector: "http"},
+		{Selector: "net/http.Client.Do.Foo"},
+		{Selector: "net/http..Do"},
+		{Selector: "net/http.Get", Replacement: "Get"},
+	}
+
+	for _, c := range cases {
+		if _, err := banned.New("team/banned", []banned.Selector{c}, nil); err == nil {
+			t.Errorf("%v: expected error", c)
+		}
+	}
+}
+
+func TestRuleCacheKey(t *testing.T) {
+	newKey := func(selectors []banned.Selector) string {
+		r, err := banned.New("team/banned", selectors, []banned.Import{{Path: "unsafe"}})
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		return r.CopyRule().(*banned.Rule).CacheKey()
+	}
+
+	get := banned.Selector{Selector: "net/http.Get"}
+	key := newKey([]banned.Selector{get})
+
+	if e, a := key, newKey([]banned.Selector{get}); e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	if !strings.Contains(key, `"Selector":"net/http.Get"`) {
+		t.Errorf("expected the key to contain the configured selector, but received %q", key)
+	}
+
+	get.Message = "use the client"
+	if newKey([]banned.Selector{get}) == key {
+		t.Errorf("expected the key to change with the message")
+	}
+}
@@ -27,11 +27,11 @@ func NewRule(fset *token.FileSet) *Rule {
 	}
 }
 
-// AddRules will add rules for every deprecate rule
+// AddRules will register every deprecate rule
 func (r *Rule) AddRules(rules *pepperlint.Rules) {
-	r.structRule.AddRules(rules)
-	r.fieldRule.AddRules(rules)
-	r.opRule.AddRules(rules)
+	rules.Register(r.structRule).
+		Register(r.fieldRule).
+		Register(r.opRule)
 }
 
 // WithCache will add rules for every deprecate rule
@@ -49,6 +49,14 @@ func (r Rule) WithFileSet(fset *token.FileSet) {
 	r.opRule.WithFileSet(fset)
 }
 
+// WithLogger sets the logger of each rule inside the deprecated rule
+// container.
+func (r Rule) WithLogger(logger pepperlint.Logger) {
+	r.structRule.WithLogger(logger)
+	r.fieldRule.WithLogger(logger)
+	r.opRule.WithLogger(logger)
+}
+
 // CopyRule satisfies the copy ruler interface to copy the
 // current Rule
 func (r Rule) CopyRule() pepperlint.Rule {
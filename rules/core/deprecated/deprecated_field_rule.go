@@ -13,6 +13,7 @@ type FieldRule struct {
 	fset           *token.FileSet
 	currentPkgName string
 	helper         pepperlint.Helper
+	logger         pepperlint.Logger
 }
 
 type fieldInfo struct {
@@ -141,7 +142,7 @@ func (r FieldRule) checkBinaryExprFields(bexpr *ast.BinaryExpr) []error {
 				errs = append(errs, berr.Errors()...)
 			}
 		default:
-			pepperlint.Log("TODO: checkBinaryExprFields %T", exprType)
+			pepperlint.Unhandled(r.logger, r.fset, "FieldRule.checkBinaryExprFields", exprType)
 		}
 	}
 
@@ -159,7 +160,7 @@ func (r FieldRule) getFieldInfo(expr ast.Expr) fieldInfos {
 
 		return r.getFieldInfoFromDecl(t.Obj.Decl)
 	default:
-		pepperlint.Log("TODO: getInternalTypeSpec %T", t)
+		pepperlint.Unhandled(r.logger, r.fset, "FieldRule.getFieldInfo", t)
 	}
 
 	return nil
@@ -187,7 +188,7 @@ func (r FieldRule) getFieldInfoFromDecl(decl interface{}) fieldInfos {
 				info.LHS = lhs
 				populated = true
 			default:
-				pepperlint.Log("TODO: lhs.getTypeSpecFromDecl %T", lhs)
+				pepperlint.Unhandled(r.logger, r.fset, "FieldRule.getFieldInfoFromDecl", lhs)
 			}
 
 			switch rhs := t.Rhs[i].(type) {
@@ -213,7 +214,7 @@ func (r FieldRule) getFieldInfoFromDecl(decl interface{}) fieldInfos {
 					}
 
 				default:
-					pepperlint.Log("TODO: rhsType.inner.getTypeSpecFromDecl %T", rhsType)
+					pepperlint.Unhandled(r.logger, r.fset, "FieldRule.getFieldInfoFromDecl", rhsType)
 				}
 			case *ast.CallExpr:
 				info.RHS = rhs
@@ -222,7 +223,7 @@ func (r FieldRule) getFieldInfoFromDecl(decl interface{}) fieldInfos {
 				info.RHS = rhs
 				populated = true
 			default:
-				pepperlint.Log("TODO: rhsType.getTypeSpecFromDecl %T", rhs)
+				pepperlint.Unhandled(r.logger, r.fset, "FieldRule.getFieldInfoFromDecl", rhs)
 			}
 
 			if populated {
@@ -235,7 +236,7 @@ func (r FieldRule) getFieldInfoFromDecl(decl interface{}) fieldInfos {
 			Field: t,
 		})
 	default:
-		pepperlint.Log("TODO: getTypeSpecFromDecl %T", t)
+		pepperlint.Unhandled(r.logger, r.fset, "FieldRule.getFieldInfoFromDecl", t)
 	}
 
 	return infos
@@ -266,7 +267,7 @@ func (r FieldRule) ValidateAssignStmt(stmt *ast.AssignStmt) error {
 						batchError.Add(err)
 					}
 				default:
-					pepperlint.Log("TODO: deprecated_field_rule.ValidateAssignStmt %T", t)
+					pepperlint.Unhandled(r.logger, r.fset, "FieldRule.ValidateAssignStmt", t)
 				}
 			}
 		}
@@ -289,7 +290,7 @@ func (r FieldRule) ValidateAssignStmt(stmt *ast.AssignStmt) error {
 						batchError.Add(err)
 					}
 				default:
-					pepperlint.Log("TODO: deprecated_field_rule.ValidateAssignStmt %T", t)
+					pepperlint.Unhandled(r.logger, r.fset, "FieldRule.ValidateAssignStmt", t)
 				}
 			}
 		}
@@ -329,7 +330,7 @@ func (r FieldRule) ValidateAssignStmt(stmt *ast.AssignStmt) error {
 				}
 
 			default:
-				pepperlint.Log("TODO: ValidateAssignStmt %T", t)
+				pepperlint.Unhandled(r.logger, r.fset, "FieldRule.ValidateAssignStmt", t)
 			}
 		}
 	}
@@ -350,7 +351,7 @@ func (r FieldRule) ValidateCallExpr(expr *ast.CallExpr) error {
 				batchError.Add(err)
 			}
 		default:
-			pepperlint.Log("TODO: dep_field_rule.ValidateCallExpr: %T %v", t, t)
+			pepperlint.Unhandled(r.logger, r.fset, "FieldRule.ValidateCallExpr", t)
 		}
 	}
 
@@ -369,7 +370,7 @@ func (r FieldRule) ValidateReturnStmt(stmt *ast.ReturnStmt) error {
 				batchError.Add(err)
 			}
 		default:
-			pepperlint.Log("TODO: deprecated_field_rule.ValidateReturnStmt %T", t)
+			pepperlint.Unhandled(r.logger, r.fset, "FieldRule.ValidateReturnStmt", t)
 		}
 	}
 
@@ -401,7 +402,7 @@ func (r FieldRule) ValidateIncDecStmt(stmt *ast.IncDecStmt) error {
 			}
 		}
 	default:
-		pepperlint.Log("TODO: ValidateIncDecExpr %T", t)
+		pepperlint.Unhandled(r.logger, r.fset, "FieldRule.ValidateIncDecStmt", t)
 	}
 
 	return batchError.Return()
@@ -435,19 +436,12 @@ func (r FieldRule) ValidateRangeStmt(expr *ast.RangeStmt) error {
 	return batchError.Return()
 }
 
-// AddRules will add the FieldRule to the given visitor
+// AddRules will register the FieldRule with the given rules.
+//
+// Deprecated: Rules are added by the interfaces they implement, use
+// Rules.Register instead.
 func (r *FieldRule) AddRules(visitorRules *pepperlint.Rules) {
-	rules := pepperlint.Rules{
-		AssignStmtRules: pepperlint.AssignStmtRules{r},
-		BinaryExprRules: pepperlint.BinaryExprRules{r},
-		CallExprRules:   pepperlint.CallExprRules{r},
-		PackageRules:    pepperlint.PackageRules{r},
-		IncDecStmtRules: pepperlint.IncDecStmtRules{r},
-		ReturnStmtRules: pepperlint.ReturnStmtRules{r},
-		RangeStmtRules:  pepperlint.RangeStmtRules{r},
-	}
-
-	visitorRules.Merge(rules)
+	visitorRules.Register(r)
 }
 
 // WithCache will create a new helper with the given cache. This is used
@@ -461,3 +455,9 @@ func (r *FieldRule) WithCache(cache *pepperlint.Cache) {
 func (r *FieldRule) WithFileSet(fset *token.FileSet) {
 	r.fset = fset
 }
+
+// WithLogger sets the logger that nodes the rule does not handle are
+// logged to.
+func (r *FieldRule) WithLogger(logger pepperlint.Logger) {
+	r.logger = logger
+}
@@ -15,6 +15,7 @@ type OpRule struct {
 	fset           *token.FileSet
 	currentPkgName string
 	helper         pepperlint.Helper
+	logger         pepperlint.Logger
 }
 
 // NewOpRule returns a new OpRule with the given file set.
@@ -99,7 +100,7 @@ func (r *OpRule) getExternalPackageType(expr ast.Expr) ([]pepperlint.TypeInfo, b
 				infos = append(infos, info)
 			}
 		default:
-			pepperlint.Log("TODO: deprecated_op_rule.getInternalPackageType %T", decl)
+			pepperlint.Unhandled(r.logger, r.fset, "OpRule.getExternalPackageType", decl)
 		}
 	}
 
@@ -119,7 +120,7 @@ func (r *OpRule) getExternalTypeSpec(rhs ast.Expr) (pepperlint.TypeInfo, bool) {
 		typeName := exprType.Sel.Name
 		file, ok := r.helper.PackagesCache.CurrentFile()
 		if !ok {
-			panic("CurrentFile was not set")
+			return pepperlint.TypeInfo{}, false
 		}
 
 		importPath := file.Imports[pkgName]
@@ -139,7 +140,7 @@ func (r *OpRule) getExternalTypeSpec(rhs ast.Expr) (pepperlint.TypeInfo, bool) {
 	case *ast.UnaryExpr:
 		return r.getExternalTypeSpec(expr.X)
 	default:
-		pepperlint.Log("TODO: deprecated_op_rule.getInternalPackageType %T %v", expr, expr)
+		pepperlint.Unhandled(r.logger, r.fset, "OpRule.getExternalTypeSpec", expr)
 	}
 
 	return pepperlint.TypeInfo{}, false
@@ -176,7 +177,7 @@ func (r *OpRule) getInternalPackageType(expr ast.Expr) ([]pepperlint.TypeInfo, b
 				})
 			}
 		default:
-			pepperlint.Log("TODO: deprecated_op_rule.getInternalPackageType %T", decl)
+			pepperlint.Unhandled(r.logger, r.fset, "OpRule.getInternalPackageType", decl)
 		}
 	}
 
@@ -209,16 +210,17 @@ func (r *OpRule) getInternalTypeSpec(rhs ast.Expr) (*ast.TypeSpec, bool) {
 	case *ast.UnaryExpr:
 		return r.getInternalTypeSpec(expr.X)
 	default:
-		pepperlint.Log("TODO: deprecated_op_rule.getInternalPackageType %T %v", expr, expr)
+		pepperlint.Unhandled(r.logger, r.fset, "OpRule.getInternalTypeSpec", expr)
 	}
 
 	return nil, false
 }
 
 // isSelectorExprDeprecated will take a look at a selector expression and grab the
-// type spec off of the selector expression's X field. The type spec will be used
-// to determine whether or not that it is apart of the operation found via method name
-// and current package name.
+// type spec off of the selector expression's X field. The method of that type is
+// looked up by the name of the type spec, so methods of the same name on other
+// types are never reported. If there is no type spec, X is a package and the
+// function of that package is looked up instead.
 func (r *OpRule) isSelectorExprDeprecated(sel *ast.SelectorExpr) []error {
 	methodName := sel.Sel.Name
 
@@ -241,7 +243,7 @@ func (r *OpRule) isSelectorExprDeprecated(sel *ast.SelectorExpr) []error {
 		if externalPkg {
 			file, ok := r.helper.PackagesCache.CurrentFile()
 			if !ok {
-				panic("CurrentFile was not properly set")
+				continue
 			}
 
 			pkgImportPath := file.Imports[info.PkgName]
@@ -255,13 +257,14 @@ func (r *OpRule) isSelectorExprDeprecated(sel *ast.SelectorExpr) []error {
 			continue
 		}
 
-		opInfo, ok := pkg.Files.GetOpInfo(methodName)
-		if !ok {
-			continue
+		var opInfo pepperlint.OpInfo
+		if spec != nil {
+			opInfo, ok = pkg.Method(spec.Name.Name, methodName)
+		} else {
+			opInfo, ok = pkg.Func(methodName)
 		}
 
-		// Check to see if the type has the associated method name
-		if spec != nil && !opInfo.HasReceiverType(spec) {
+		if !ok {
 			continue
 		}
 
@@ -311,7 +314,7 @@ func (r *OpRule) ValidateCallExpr(expr *ast.CallExpr) error {
 			batchError.Add(errs...)
 		}
 	default:
-		pepperlint.Log("TODO: deprecated_op_rule.ValidateCallExpr %T", fun)
+		pepperlint.Unhandled(r.logger, r.fset, "OpRule.ValidateCallExpr", fun)
 	}
 
 	return batchError.Return()
@@ -324,15 +327,12 @@ func (r *OpRule) ValidatePackage(pkg *ast.Package) error {
 	return nil
 }
 
-// AddRules will add the DeprecatedFieldRule to the given visitor
+// AddRules will register the OpRule with the given rules.
+//
+// Deprecated: Rules are added by the interfaces they implement, use
+// Rules.Register instead.
 func (r *OpRule) AddRules(visitorRules *pepperlint.Rules) {
-	rules := pepperlint.Rules{
-		AssignStmtRules: pepperlint.AssignStmtRules{r},
-		CallExprRules:   pepperlint.CallExprRules{r},
-		PackageRules:    pepperlint.PackageRules{r},
-	}
-
-	visitorRules.Merge(rules)
+	visitorRules.Register(r)
 }
 
 // WithCache will create a new helper with the given cache. This is used
@@ -346,3 +346,9 @@ func (r *OpRule) WithCache(cache *pepperlint.Cache) {
 func (r *OpRule) WithFileSet(fset *token.FileSet) {
 	r.fset = fset
 }
+
+// WithLogger sets the logger that nodes the rule does not handle are
+// logged to.
+func (r *OpRule) WithLogger(logger pepperlint.Logger) {
+	r.logger = logger
+}
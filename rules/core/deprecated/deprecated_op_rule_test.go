@@ -56,6 +56,35 @@ func TestDeprecateOpRule(t *testing.T) {
 			},
 			expectedErrors: 4,
 		},
+		{
+			name: "same_named_methods",
+			code: `package foo
+			type A struct {}
+			type B struct {}
+
+			// Close closes
+			//
+			// Deprecated: Use Shutdown instead
+			func (a *A) Close() {
+			}
+
+			// Close closes
+			func (b *B) Close() {
+			}
+
+			func closeAll() {
+				a := &A{}
+				b := &B{}
+
+				a.Close()
+				b.Close()
+			}
+			`,
+			rulesFn: func(fset *token.FileSet) *deprecated.OpRule {
+				return deprecated.NewOpRule(fset)
+			},
+			expectedErrors: 1,
+		},
 	}
 
 	for _, c := range cases {
@@ -89,3 +118,26 @@ func TestDeprecateOpRule(t *testing.T) {
 		})
 	}
 }
+
+func TestAddRules(t *testing.T) {
+	fset := token.NewFileSet()
+	cases := map[string]interface {
+		AddRules(*pepperlint.Rules)
+	}{
+		"op":     deprecated.NewOpRule(fset),
+		"field":  deprecated.NewFieldRule(fset),
+		"struct": deprecated.NewStructRule(fset),
+	}
+
+	for name, rule := range cases {
+		added := pepperlint.Rules{}
+		rule.AddRules(&added)
+
+		registered := pepperlint.Rules{}
+		registered.Register(rule)
+
+		if e, a := registered.NodeTypes(), added.NodeTypes(); e != a || e == 0 {
+			t.Errorf("%s: expected %b, but received %b", name, e, a)
+		}
+	}
+}
@@ -14,6 +14,7 @@ type StructRule struct {
 	fset           *token.FileSet
 	currentPkgName string
 	helper         pepperlint.Helper
+	logger         pepperlint.Logger
 
 	// need to keep track of which call expr were visited due to
 	// assignment statement also calling ValidateCallExpr.
@@ -56,7 +57,7 @@ func (r StructRule) isSelectorExprDeprecated(node ast.Node, expr *ast.SelectorEx
 
 	file, ok := r.helper.PackagesCache.CurrentFile()
 	if !ok {
-		panic("CurrentFile was not set")
+		return nil
 	}
 
 	pkgImportPath := file.Imports[ident.Name]
@@ -136,7 +137,7 @@ func (r StructRule) isIdentDeprecated(node ast.Node, ident *ast.Ident) []error {
 					errs = append(errs, es...)
 				}
 			default:
-				pepperlint.Log("TODO: isIdentDeprecated %T", rhsType)
+				pepperlint.Unhandled(r.logger, r.fset, "StructRule.isIdentDeprecated", rhsType)
 			}
 
 		}
@@ -150,13 +151,13 @@ func (r StructRule) isIdentDeprecated(node ast.Node, ident *ast.Ident) []error {
 
 		return errs
 	default:
-		pepperlint.Log("TODO: deprecated_struct_rule.isIdentDeprecated %T", decl)
+		pepperlint.Unhandled(r.logger, r.fset, "StructRule.isIdentDeprecated", decl)
 		return errs
 	}
 
 	pkg, ok := r.helper.PackagesCache.CurrentPackage()
 	if !ok {
-		panic("SHOULD NOT BE HERE")
+		return errs
 	}
 
 	info, ok := pkg.Files.GetTypeInfo(spec.Name.Name)
@@ -206,7 +207,7 @@ func (r StructRule) validateAssignStmt(expr ast.Expr, rhs ast.Expr) []error {
 	case *ast.UnaryExpr:
 		return r.validateAssignStmt(expr, t.X)
 	default:
-		pepperlint.Log("TODO: RHS StructRule.ValidateAssignStmt %T %v\n", t, t)
+		pepperlint.Unhandled(r.logger, r.fset, "StructRule.validateAssignStmt", t)
 	}
 
 	return nil
@@ -240,7 +241,7 @@ func (r StructRule) deprecatedStructUsage(rhs ast.Expr, expr ast.Expr) []error {
 
 			pkg, ok := r.helper.PackagesCache.CurrentPackage()
 			if !ok {
-				panic("CurrentPackage was not set")
+				return errs
 			}
 
 			info, ok := pkg.Files.GetTypeInfo(decl.Name.Name)
@@ -271,7 +272,7 @@ func (r StructRule) deprecatedStructUsage(rhs ast.Expr, expr ast.Expr) []error {
 			errs = append(errs, es...)
 		}
 	default:
-		pepperlint.Log("TODO: deprecated_struct_rule.deprecatedStructUsage %T", tstruct)
+		pepperlint.Unhandled(r.logger, r.fset, "StructRule.deprecatedStructUsage", tstruct)
 	}
 
 	return errs
@@ -299,7 +300,7 @@ func (r StructRule) checkTypeAliases(rhs ast.Node, expr ast.Expr) []error {
 
 		pkg, ok := r.helper.PackagesCache.CurrentPackage()
 		if !ok {
-			panic("CurrentPackage was not set")
+			return nil
 		}
 
 		info, ok := pkg.Files.GetTypeInfo(spec.Name.Name)
@@ -370,7 +371,7 @@ func (r StructRule) validateCallExpr(node ast.Node, arg ast.Expr) []error {
 			}
 		}
 	default:
-		pepperlint.Log("TODO: dep_struct_rule.ValidateCallExpr: %T\n", t)
+		pepperlint.Unhandled(r.logger, r.fset, "StructRule.validateCallExpr", t)
 	}
 
 	return errs
@@ -392,7 +393,7 @@ func (r StructRule) ValidateReturnStmt(stmt *ast.ReturnStmt) error {
 			}
 
 		default:
-			pepperlint.Log("TODO: StructRule.ValidateReturnStmt: %T", t)
+			pepperlint.Unhandled(r.logger, r.fset, "StructRule.ValidateReturnStmt", t)
 		}
 	}
 
@@ -429,7 +430,7 @@ func (r StructRule) ValidateFuncDecl(decl *ast.FuncDecl) error {
 				batchError.Add(err)
 			}
 		default:
-			pepperlint.Log("TODO: ValidateFuncDecl.Param %T\n", t)
+			pepperlint.Unhandled(r.logger, r.fset, "StructRule.ValidateFuncDecl", t)
 		}
 	}
 
@@ -465,7 +466,7 @@ func (r StructRule) ValidateFuncDecl(decl *ast.FuncDecl) error {
 				batchError.Add(err)
 			}
 		default:
-			pepperlint.Log("TODO: ValidateFuncDecl.Result %T\n", t)
+			pepperlint.Unhandled(r.logger, r.fset, "StructRule.ValidateFuncDecl", t)
 		}
 	}
 
@@ -489,7 +490,7 @@ func (r StructRule) ValidateTypeSpec(spec *ast.TypeSpec) error {
 			batchError.Add(err)
 		}
 	default:
-		pepperlint.Log("TODO: StructRule.ValidateTypeSpec %T", t)
+		pepperlint.Unhandled(r.logger, r.fset, "StructRule.ValidateTypeSpec", t)
 	}
 
 	return batchError.Return()
@@ -533,20 +534,12 @@ func (r StructRule) ValidateBinaryExpr(expr *ast.BinaryExpr) error {
 	return batchError.Return()
 }
 
-// AddRules will add the StructRule to the given visitor
+// AddRules will register the StructRule with the given rules.
+//
+// Deprecated: Rules are added by the interfaces they implement, use
+// Rules.Register instead.
 func (r *StructRule) AddRules(visitorRules *pepperlint.Rules) {
-	rules := pepperlint.Rules{
-		AssignStmtRules: pepperlint.AssignStmtRules{r},
-		BinaryExprRules: pepperlint.BinaryExprRules{r},
-		CallExprRules:   pepperlint.CallExprRules{r},
-		ReturnStmtRules: pepperlint.ReturnStmtRules{r},
-		FuncDeclRules:   pepperlint.FuncDeclRules{r},
-		PackageRules:    pepperlint.PackageRules{r},
-		TypeSpecRules:   pepperlint.TypeSpecRules{r},
-		ValueSpecRules:  pepperlint.ValueSpecRules{r},
-	}
-
-	visitorRules.Merge(rules)
+	visitorRules.Register(r)
 }
 
 // WithCache will create a new helper with the given cache. This is used
@@ -560,3 +553,9 @@ func (r *StructRule) WithCache(cache *pepperlint.Cache) {
 func (r *StructRule) WithFileSet(fset *token.FileSet) {
 	r.fset = fset
 }
+
+// WithLogger sets the logger that nodes the rule does not handle are
+// logged to.
+func (r *StructRule) WithLogger(logger pepperlint.Logger) {
+	r.logger = logger
+}
@@ -0,0 +1,342 @@
+package pattern
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strconv"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// Match is code that matched a pattern.
+type Match struct {
+	Pos token.Pos
+	End token.Pos
+
+	// Binds maps the name of every metavariable to the node it matched.
+	Binds map[string]ast.Node
+}
+
+// Source is a file that patterns are matched against.
+type Source struct {
+	Fset *token.FileSet
+	File *ast.File
+
+	// Src is the contents the file was parsed from, which constraints are
+	// matched against.
+	Src []byte
+
+	// Imports maps the name each package is imported as to its import path.
+	Imports map[string]string
+}
+
+// Text will return the source of node.
+func (s Source) Text(node ast.Node) string {
+	tf := s.Fset.File(node.Pos())
+	if tf == nil {
+		return ""
+	}
+
+	start, end := tf.Offset(node.Pos()), tf.Offset(node.End())
+	if start < 0 || end > len(s.Src) || start > end {
+		return ""
+	}
+
+	return string(s.Src[start:end])
+}
+
+// Matches will return every match of the pattern within the file, in the order
+// they start. Matches may be nested within each other.
+func (p *Pattern) Matches(s Source) []Match {
+	matches := []Match{}
+
+	ast.Inspect(s.File, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+
+		if p.expr != nil {
+			if m, ok := p.matchNode(s, p.expr, node); ok {
+				matches = append(matches, m)
+			}
+
+			return true
+		}
+
+		var list []ast.Stmt
+		switch t := node.(type) {
+		case *ast.BlockStmt:
+			list = t.List
+		case *ast.CaseClause:
+			list = t.Body
+		case *ast.CommClause:
+			list = t.Body
+		default:
+			if len(p.stmts) == 1 {
+				if m, ok := p.matchNode(s, p.stmts[0], node); ok {
+					matches = append(matches, m)
+				}
+			}
+
+			return true
+		}
+
+		// single statements are matched as the nodes they are
+		if len(p.stmts) == 1 {
+			return true
+		}
+
+		for i := 0; i+len(p.stmts) <= len(list); i++ {
+			m := matcher{
+				pattern: p,
+				source:  s,
+				binds:   map[string]ast.Node{},
+			}
+
+			if m.matchStmts(p.stmts, list[i:i+len(p.stmts)]) {
+				matches = append(matches, Match{
+					Pos:   list[i].Pos(),
+					End:   list[i+len(p.stmts)-1].End(),
+					Binds: m.binds,
+				})
+			}
+		}
+
+		return true
+	})
+
+	return matches
+}
+
+func (p *Pattern) matchNode(s Source, pattern, node ast.Node) (Match, bool) {
+	m := matcher{
+		pattern: p,
+		source:  s,
+		binds:   map[string]ast.Node{},
+	}
+
+	if !m.match(reflect.ValueOf(pattern), reflect.ValueOf(node)) {
+		return Match{}, false
+	}
+
+	return Match{
+		Pos:   node.Pos(),
+		End:   node.End(),
+		Binds: m.binds,
+	}, true
+}
+
+// matcher matches a pattern against code, binding metavariables as it goes.
+type matcher struct {
+	pattern *Pattern
+	source  Source
+	binds   map[string]ast.Node
+}
+
+var (
+	posType     = reflect.TypeOf(token.NoPos)
+	objectType  = reflect.TypeOf((*ast.Object)(nil))
+	scopeType   = reflect.TypeOf((*ast.Scope)(nil))
+	commentType = reflect.TypeOf((*ast.CommentGroup)(nil))
+)
+
+func (m *matcher) matchStmts(patterns, stmts []ast.Stmt) bool {
+	for i := range patterns {
+		if !m.match(reflect.ValueOf(patterns[i]), reflect.ValueOf(stmts[i])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// match will return true if the pattern matches the code. Positions, comments
+// and resolved objects are ignored.
+func (m *matcher) match(pattern, code reflect.Value) bool {
+	if pattern.Kind() == reflect.Interface {
+		pattern = pattern.Elem()
+	}
+
+	if code.Kind() == reflect.Interface {
+		code = code.Elem()
+	}
+
+	if !pattern.IsValid() || !code.IsValid() {
+		return pattern.IsValid() == code.IsValid()
+	}
+
+	if pattern.Kind() == reflect.Ptr && pattern.IsNil() {
+		return code.Kind() == reflect.Ptr && code.IsNil()
+	}
+
+	if node, ok := pattern.Interface().(ast.Node); ok {
+		if matched, ok := m.matchMetavariable(node, code); ok {
+			return matched
+		}
+
+		if matched, ok := m.matchSpecial(node, code); ok {
+			return matched
+		}
+	}
+
+	if pattern.Type() != code.Type() {
+		return false
+	}
+
+	switch pattern.Kind() {
+	case reflect.Ptr:
+		if code.IsNil() {
+			return false
+		}
+
+		return m.match(pattern.Elem(), code.Elem())
+	case reflect.Struct:
+		for i := 0; i < pattern.NumField(); i++ {
+			switch pattern.Type().Field(i).Type {
+			case posType, objectType, scopeType, commentType:
+				continue
+			}
+
+			if !m.match(pattern.Field(i), code.Field(i)) {
+				return false
+			}
+		}
+
+		return true
+	case reflect.Slice:
+		if pattern.Len() != code.Len() {
+			return false
+		}
+
+		for i := 0; i < pattern.Len(); i++ {
+			if !m.match(pattern.Index(i), code.Index(i)) {
+				return false
+			}
+		}
+
+		return true
+	case reflect.String:
+		return pattern.String() == code.String()
+	case reflect.Bool:
+		return pattern.Bool() == code.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return pattern.Int() == code.Int()
+	}
+
+	return false
+}
+
+// matchMetavariable will match code against pattern if pattern is a
+// metavariable, or a metavariable used as a statement. False is returned as the
+// second value if it is not.
+func (m *matcher) matchMetavariable(pattern ast.Node, code reflect.Value) (bool, bool) {
+	if stmt, ok := pattern.(*ast.ExprStmt); ok {
+		if _, ok := metavariable(stmt.X); ok {
+			if _, ok := code.Interface().(ast.Stmt); ok {
+				return m.bind(stmt.X, code), true
+			}
+		}
+	}
+
+	if _, ok := metavariable(pattern); !ok {
+		return false, false
+	}
+
+	return m.bind(pattern, code), true
+}
+
+// bind will bind the metavariable to code. If the metavariable is already bound,
+// code must match what it is bound to.
+func (m *matcher) bind(pattern ast.Node, code reflect.Value) bool {
+	name, _ := metavariable(pattern)
+	node, ok := code.Interface().(ast.Node)
+	if !ok || code.Kind() == reflect.Ptr && code.IsNil() {
+		return false
+	}
+
+	if name == wildcard {
+		return true
+	}
+
+	if bound, ok := m.binds[name]; ok {
+		return m.equal(bound, node)
+	}
+
+	if c, ok := m.pattern.where[name]; ok {
+		if len(c.Kind) > 0 && reflect.TypeOf(node).Elem().Name() != c.Kind {
+			return false
+		}
+
+		if c.re != nil && !c.re.MatchString(m.source.Text(node)) {
+			return false
+		}
+	}
+
+	m.binds[name] = node
+	return true
+}
+
+// equal will return true if both nodes are the same code.
+func (m *matcher) equal(a, b ast.Node) bool {
+	eq := matcher{
+		pattern: &Pattern{},
+		source:  m.source,
+		binds:   map[string]ast.Node{},
+	}
+
+	return eq.match(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// matchSpecial handles nodes that are not matched field by field. False is
+// returned as the second value for any other node.
+func (m *matcher) matchSpecial(pattern ast.Node, code reflect.Value) (bool, bool) {
+	switch p := pattern.(type) {
+	case *ast.BasicLit:
+		c, ok := code.Interface().(*ast.BasicLit)
+		if !ok || p.Kind != c.Kind {
+			return false, true
+		}
+
+		// strings match regardless of how they are quoted
+		if p.Kind == token.STRING {
+			pv, perr := strconv.Unquote(p.Value)
+			cv, cerr := strconv.Unquote(c.Value)
+			if perr == nil && cerr == nil {
+				return pv == cv, true
+			}
+		}
+
+		return p.Value == c.Value, true
+	case *ast.SelectorExpr:
+		c, ok := code.Interface().(*ast.SelectorExpr)
+		if !ok {
+			return false, true
+		}
+
+		pkg, ok := p.X.(*ast.Ident)
+		if _, meta := metavariable(p.X); !ok || meta {
+			return false, false
+		}
+
+		ident, ok := c.X.(*ast.Ident)
+		if !ok || ident.Obj != nil {
+			return false, false
+		}
+
+		importPath, ok := m.source.Imports[ident.Name]
+		if !ok {
+			return false, false
+		}
+
+		// the code selects from an imported package, which matches any
+		// package of the same name
+		if pkg.Name != ident.Name && pkg.Name != pepperlint.GetPackageNameFromImportPath(importPath) {
+			return false, true
+		}
+
+		return m.match(reflect.ValueOf(p.Sel), reflect.ValueOf(c.Sel)), true
+	}
+
+	return false, false
+}
@@ -0,0 +1,258 @@
+// Package pattern contains a rule that reports code matching a pattern, which
+// is written as Go code with metavariables.
+//
+// Metavariables are identifiers prefixed with '$', such as $x. A metavariable
+// matches any expression, or any statement when it is used as a statement,
+// and every use of the same metavariable must match the same code. $_ matches
+// anything without binding it. Patterns of more than one statement match
+// consecutive statements of a block.
+//
+//	$x.Lock(); $x.Lock()
+//	fmt.Sprintf("%s", $s)
+//	if $err != nil { return $err }
+//
+// Package names in a pattern match any import of a package with that name, so
+// fmt.Sprintf also matches f.Sprintf when fmt is imported as f.
+package pattern
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// metaPrefix is what metavariables are renamed to start with, so patterns can
+// be parsed as Go code.
+const metaPrefix = "__pepperlint_"
+
+// wildcard is the metavariable that matches anything without being bound.
+const wildcard = "_"
+
+// Constraint restricts what a metavariable can match.
+type Constraint struct {
+	// Kind is the go/ast type the metavariable must match, such as "Ident" or
+	// "BasicLit".
+	Kind string `yaml:"kind"`
+
+	// Regexp must match the source of what the metavariable matches.
+	Regexp string `yaml:"regexp"`
+
+	re *regexp.Regexp
+}
+
+// Pattern is a compiled pattern. Either expr is set, or stmts is set for
+// patterns of statements.
+type Pattern struct {
+	src   string
+	expr  ast.Expr
+	stmts []ast.Stmt
+
+	vars  map[string]bool
+	where map[string]Constraint
+}
+
+// Compile will parse a pattern. Constraints are keyed by the name of their
+// metavariable, without the '$'.
+func Compile(src string, where map[string]Constraint) (*Pattern, error) {
+	p := &Pattern{
+		src:   src,
+		vars:  map[string]bool{},
+		where: map[string]Constraint{},
+	}
+
+	goSrc, vars, err := replaceMetavariables(src)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range vars {
+		p.vars[name] = true
+	}
+
+	if expr, err := parser.ParseExpr(goSrc); err == nil {
+		p.expr = expr
+	} else {
+		stmts, err := parseStmts(goSrc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", src, err)
+		}
+
+		p.stmts = stmts
+
+		// a single expression statement is matched as an expression
+		if stmt, ok := stmts[0].(*ast.ExprStmt); ok && len(stmts) == 1 {
+			p.expr = stmt.X
+			p.stmts = nil
+		}
+	}
+
+	for name, c := range where {
+		if !p.vars[name] {
+			return nil, fmt.Errorf("constraint on $%s, which is not in pattern %q", name, src)
+		}
+
+		if len(c.Regexp) > 0 {
+			re, err := regexp.Compile(c.Regexp)
+			if err != nil {
+				return nil, fmt.Errorf("constraint on $%s: %v", name, err)
+			}
+			c.re = re
+		}
+
+		p.where[name] = c
+	}
+
+	return p, nil
+}
+
+// String will return the source of the pattern.
+func (p *Pattern) String() string {
+	return p.src
+}
+
+// Vars will return the names of the pattern's metavariables, sorted.
+func (p *Pattern) Vars() []string {
+	vars := make([]string, 0, len(p.vars))
+	for name := range p.vars {
+		vars = append(vars, name)
+	}
+	sort.Strings(vars)
+
+	return vars
+}
+
+// parseStmts will parse src as the body of a function.
+func parseStmts(src string) ([]ast.Stmt, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+src+"\n}", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	stmts := f.Decls[0].(*ast.FuncDecl).Body.List
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	return stmts, nil
+}
+
+// replaceMetavariables will rename every metavariable of src, so it can be
+// parsed, and return the names of the metavariables other than $_. Dollar signs
+// within string literals and comments are left as they are.
+func replaceMetavariables(src string) (string, []string, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+
+	errs := scanner.ErrorList{}
+	s := scanner.Scanner{}
+	s.Init(file, []byte(src), func(pos token.Position, msg string) {
+		// the scanner reports every '$' as illegal
+		if pos.Offset < len(src) && src[pos.Offset] == '$' {
+			return
+		}
+		errs.Add(pos, msg)
+	}, scanner.ScanComments)
+
+	var b bytes.Buffer
+	vars := []string{}
+	last := 0
+	for {
+		pos, tok, _ := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		offset := file.Offset(pos)
+		if tok != token.ILLEGAL || src[offset] != '$' {
+			continue
+		}
+
+		name := metavariableName(src[offset+1:])
+		if len(name) == 0 {
+			return "", nil, fmt.Errorf("invalid pattern %q: '$' must be followed by a name", src)
+		}
+
+		b.WriteString(src[last:offset])
+		b.WriteString(metaPrefix + name)
+		last = offset + 1 + len(name)
+
+		if name != wildcard {
+			vars = append(vars, name)
+		}
+	}
+
+	if errs.Len() > 0 {
+		return "", nil, fmt.Errorf("invalid pattern %q: %v", src, errs.Err())
+	}
+
+	b.WriteString(src[last:])
+	return b.String(), vars, nil
+}
+
+// metavariableName will return the identifier that s starts with.
+func metavariableName(s string) string {
+	i := 0
+	for i < len(s) && (s[i] == '_' || 'a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z' || i > 0 && '0' <= s[i] && s[i] <= '9') {
+		i++
+	}
+
+	return s[:i]
+}
+
+// metavariable will return the name of the metavariable that node is, if it is
+// one.
+func metavariable(node ast.Node) (string, bool) {
+	ident, ok := node.(*ast.Ident)
+	if !ok || !strings.HasPrefix(ident.Name, metaPrefix) {
+		return "", false
+	}
+
+	return ident.Name[len(metaPrefix):], true
+}
+
+// expand will replace every metavariable of template with the source of what it
+// matched. Metavariables that are not bound are left as they are.
+func expand(template string, binds map[string]string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' {
+			b.WriteByte(template[i])
+			continue
+		}
+
+		name := metavariableName(template[i+1:])
+		text, ok := binds[name]
+		if !ok {
+			b.WriteByte('$')
+			continue
+		}
+
+		b.WriteString(text)
+		i += len(name)
+	}
+
+	return b.String()
+}
+
+// templateVars will return the names of the metavariables used in template.
+func templateVars(template string) []string {
+	vars := []string{}
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' {
+			continue
+		}
+
+		if name := metavariableName(template[i+1:]); len(name) > 0 {
+			vars = append(vars, name)
+			i += len(name)
+		}
+	}
+
+	return vars
+}
@@ -0,0 +1,256 @@
+package pattern_test
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-toolset/pepperlint"
+	"github.com/go-toolset/pepperlint/rules/core/pattern"
+)
+
+func TestPatternMatches(t *testing.T) {
+	cases := []struct {
+		name     string
+		pattern  string
+		where    map[string]pattern.Constraint
+		code     string
+		expected []string
+	}{
+		{
+			name:    "consecutive_statements",
+			pattern: "$x.Lock(); $x.Lock()",
+			code: `mu.Lock()
+	mu.Lock()
+	a.Lock()
+	b.Lock()
+	c.Lock()
+	foo()
+	c.Lock()`,
+			expected: []string{"mu.Lock()\n\tmu.Lock() [x=mu]"},
+		},
+		{
+			name:    "statement_metavariable",
+			pattern: "$m.Lock(); $_; $m.Unlock()",
+			code: `mu.Lock()
+	x := 1
+	mu.Unlock()
+	mu.Lock()
+	mu.Unlock()`,
+			expected: []string{"mu.Lock()\n\tx := 1\n\tmu.Unlock() [m=mu]"},
+		},
+		{
+			name:    "nested_blocks",
+			pattern: "$x.Lock(); $x.Lock()",
+			code: `switch {
+	case true:
+		mu.Lock()
+		mu.Lock()
+	}`,
+			expected: []string{"mu.Lock()\n\t\tmu.Lock() [x=mu]"},
+		},
+		{
+			name:    "renamed_import",
+			pattern: `fmt.Sprintf("%s", $s)`,
+			code: "_ = f.Sprintf(\"%s\", name)\n" +
+				"\t_ = f.Sprintf(`%s`, a.b)\n" +
+				"\t_ = f.Sprintf(\"%d\", name)\n" +
+				"\t_ = g.Sprintf(\"%s\", name)",
+			expected: []string{
+				`f.Sprintf("%s", name) [s=name]`,
+				"f.Sprintf(`%s`, a.b) [s=a.b]",
+			},
+		},
+		{
+			name:     "repeated_metavariable",
+			pattern:  "$x == $x",
+			code:     "_ = a.b == a.b\n\t_ = a == b\n\t_ = f(1) == f( 1 )",
+			expected: []string{"a.b == a.b [x=a.b]", "f(1) == f( 1 ) [x=f(1)]"},
+		},
+		{
+			name:     "wildcard",
+			pattern:  "$_ + $_",
+			code:     "_ = a + b",
+			expected: []string{"a + b []"},
+		},
+		{
+			name:     "nested_matches",
+			pattern:  "$x + $y",
+			code:     "_ = a + b + c",
+			expected: []string{"a + b + c [x=a + b y=c]", "a + b [x=a y=b]"},
+		},
+		{
+			name:    "kind_constraint",
+			pattern: "len($x)",
+			where: map[string]pattern.Constraint{
+				"x": {Kind: "Ident"},
+			},
+			code:     "_ = len(a)\n\t_ = len(a.b)",
+			expected: []string{"len(a) [x=a]"},
+		},
+		{
+			name:    "regexp_constraint",
+			pattern: "$x.$m()",
+			where: map[string]pattern.Constraint{
+				"m": {Regexp: "^Must"},
+			},
+			code:     "a.MustDo()\n\ta.Do()",
+			expected: []string{"a.MustDo() [m=MustDo x=a]"},
+		},
+		{
+			name:     "statement",
+			pattern:  "if $err != nil { return $err }",
+			code:     "if err != nil {\n\t\treturn err\n\t}\n\tif err != nil {\n\t\treturn nil\n\t}",
+			expected: []string{"if err != nil {\n\t\treturn err\n\t} [err=err]"},
+		},
+		{
+			name:     "dollar_in_string",
+			pattern:  `f("$x")`,
+			code:     "f(\"$x\")\n\tf(\"y\")",
+			expected: []string{`f("$x") []`},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := pattern.Compile(c.pattern, c.where)
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			src := "package foo\n\nimport f \"fmt\"\n\nfunc foo() {\n\t" + c.code + "\n}\n"
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "foo.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := pattern.Source{
+				Fset:    fset,
+				File:    file,
+				Src:     []byte(src),
+				Imports: map[string]string{"f": "fmt"},
+			}
+
+			actual := []string{}
+			for _, m := range p.Matches(s) {
+				binds := []string{}
+				for name, node := range m.Binds {
+					binds = append(binds, name+"="+s.Text(node))
+				}
+				sort.Strings(binds)
+
+				text := src[fset.Position(m.Pos).Offset:fset.Position(m.End).Offset]
+				actual = append(actual, text+" "+bindsString(binds))
+			}
+
+			if e, a := c.expected, actual; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected\n%q\nbut received\n%q", e, a)
+			}
+		})
+	}
+}
+
+func bindsString(binds []string) string {
+	s := "["
+	for i, b := range binds {
+		if i > 0 {
+			s += " "
+		}
+		s += b
+	}
+
+	return s + "]"
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		pattern string
+		where   map[string]pattern.Constraint
+	}{
+		{pattern: "$x +"},
+		{pattern: "$ + 1"},
+		{pattern: "$x + 1", where: map[string]pattern.Constraint{"y": {}}},
+		{pattern: "$x + 1", where: map[string]pattern.Constraint{"x": {Regexp: "("}}},
+	}
+
+	for _, c := range cases {
+		if _, err := pattern.Compile(c.pattern, c.where); err == nil {
+			t.Errorf("%q: expected error", c.pattern)
+		}
+	}
+
+	if _, err := pattern.New("team/sprintf", "fmt.Sprint($x)", "", "$y.String()", nil); err == nil {
+		t.Errorf("expected error for unknown metavariable in rewrite")
+	}
+}
+
+func TestRule(t *testing.T) {
+	rule, err := pattern.New(
+		"team/sprintf",
+		`fmt.Sprintf("%s", $s)`,
+		"use $s.String() instead",
+		"$s.String()",
+		map[string]pattern.Constraint{"s": {Kind: "Ident"}},
+	)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "foo.go")
+	src := "package foo\n\nimport \"fmt\"\n\nfunc foo() string {\n\treturn fmt.Sprintf(\"%s\", id)\n}\n"
+
+	// the file only exists in the overlay, so its source comes from the cache
+	result, err := pepperlint.Run(context.Background(), pepperlint.Options{
+		Patterns: []string{dir},
+		Overlay:  map[string][]byte{filename: []byte(src)},
+		Rules:    []pepperlint.CopyRuler{rule},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if e, a := 1, len(result.Diagnostics); e != a {
+		t.Fatalf("expected %d diagnostics, but received %v", e, result.Diagnostics)
+	}
+
+	diag := result.Diagnostics[0]
+	if e, a := "team/sprintf", diag.Rule; e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	if e, a := "use id.String() instead", diag.Err.(*pepperlint.ErrorWrap).Message(); e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	if e, a := "6:9-6:30", fmt.Sprintf("%d:%d-%d:%d", diag.Pos.Line, diag.Pos.Column, diag.End.Line, diag.End.Column); e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	fixes := diag.Fixes()
+	if len(fixes) != 1 || len(fixes[0].Edits) != 1 {
+		t.Fatalf("expected one edit, but received %v", fixes)
+	}
+
+	edit := fixes[0].Edits[0]
+	if e, a := "return id.String()", src[:edit.Pos.Offset][len(src[:edit.Pos.Offset])-7:]+edit.NewText; e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+
+	if e, a := diag.End, edit.End; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
@@ -0,0 +1,135 @@
+package pattern
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+
+	"github.com/go-toolset/pepperlint"
+)
+
+// Rule reports every match of a pattern. Its message and rewrite are templates,
+// in which metavariables are replaced with the source of what they matched.
+type Rule struct {
+	name    string
+	pattern *Pattern
+	message string
+	rewrite string
+
+	fset  *token.FileSet
+	cache *pepperlint.Cache
+}
+
+// New will return a rule named name that reports matches of pattern with
+// message. If rewrite is not empty, matches can be fixed by replacing them
+// with it.
+func New(name, pattern, message, rewrite string, where map[string]Constraint) (*Rule, error) {
+	p, err := Compile(pattern, where)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, template := range []string{message, rewrite} {
+		for _, name := range templateVars(template) {
+			if !p.vars[name] {
+				return nil, fmt.Errorf("$%s is not in pattern %q", name, pattern)
+			}
+		}
+	}
+
+	if len(message) == 0 {
+		message = fmt.Sprintf("matches %q", pattern)
+	}
+
+	return &Rule{
+		name:    name,
+		pattern: p,
+		message: message,
+		rewrite: rewrite,
+	}, nil
+}
+
+// CopyRule satisfies the pepperlint.CopyRuler interface.
+func (r *Rule) CopyRule() pepperlint.Rule {
+	return &Rule{
+		name:    r.name,
+		pattern: r.pattern,
+		message: r.message,
+		rewrite: r.rewrite,
+	}
+}
+
+// RuleName satisfies the pepperlint.RuleNamer interface.
+func (r *Rule) RuleName() string {
+	return r.name
+}
+
+// CacheKey satisfies the pepperlint.CacheKeyer interface.
+func (r *Rule) CacheKey() string {
+	return fmt.Sprintf("%s %q %v %q %q", r.name, r.pattern.src, r.pattern.where, r.message, r.rewrite)
+}
+
+// NodeTypes satisfies the pepperlint.NodeFilter interface. Patterns can match
+// any node, so the rule walks files itself.
+func (r *Rule) NodeTypes() pepperlint.NodeType {
+	return pepperlint.FileNode
+}
+
+// WithFileSet satisfies the pepperlint.FileSetOption interface.
+func (r *Rule) WithFileSet(fset *token.FileSet) {
+	r.fset = fset
+}
+
+// WithCache satisfies the pepperlint.CacheOption interface.
+func (r *Rule) WithCache(cache *pepperlint.Cache) {
+	r.cache = cache
+}
+
+// ValidateFile will report every match of the rule's pattern in f.
+func (r *Rule) ValidateFile(f *ast.File) error {
+	file, ok := r.cache.CurrentFile()
+	if !ok || file.ASTFile != f {
+		return nil
+	}
+
+	src, ok := r.cache.Source(f)
+	if !ok {
+		b, err := ioutil.ReadFile(r.fset.File(f.Pos()).Name())
+		if err != nil {
+			return err
+		}
+		src = b
+	}
+
+	s := Source{
+		Fset:    r.fset,
+		File:    f,
+		Src:     src,
+		Imports: file.Imports,
+	}
+
+	batchError := pepperlint.NewBatchError()
+	for _, m := range r.pattern.Matches(s) {
+		binds := map[string]string{}
+		for name, node := range m.Binds {
+			binds[name] = s.Text(node)
+		}
+
+		pos, end := r.fset.Position(m.Pos), r.fset.Position(m.End)
+		err := pepperlint.NewErrorWrapAt(pos, expand(r.message, binds)).WithEnd(end)
+		if len(r.rewrite) > 0 {
+			text := expand(r.rewrite, binds)
+			err.WithFix(pepperlint.SuggestedFix{
+				Message: fmt.Sprintf("replace with %s", text),
+				Edits: []pepperlint.TextEdit{
+					{Pos: pos, End: end, NewText: text},
+				},
+			})
+		}
+
+		batchError.Add(err)
+	}
+
+	return batchError.Return()
+}
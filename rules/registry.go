@@ -27,3 +27,10 @@ func Add(name string, opt pepperlint.CopyRuler) {
 func Get(name string) pepperlint.Option {
 	return rulesRegistry.Get(name).CopyRule()
 }
+
+// Lookup will return the copy ruler registered under name, and false if no
+// rule was registered with that name.
+func Lookup(name string) (pepperlint.CopyRuler, bool) {
+	r, ok := rulesRegistry[name]
+	return r, ok
+}
@@ -0,0 +1,128 @@
+package pepperlint
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fieldLengths returns the length of every rule list in Rules keyed by
+// field name.
+func fieldLengths(rules Rules) map[string]int {
+	lengths := map[string]int{}
+
+	v := reflect.ValueOf(rules)
+	for i := 0; i < v.NumField(); i++ {
+		lengths[v.Type().Field(i).Name] = v.Field(i).Len()
+	}
+
+	return lengths
+}
+
+func TestRulesMerge(t *testing.T) {
+	// Fill every rule list, including any added in the future, with a
+	// single rule.
+	other := Rules{}
+	v := reflect.ValueOf(&other).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		list := reflect.MakeSlice(field.Type(), 1, 1)
+		list.Index(0).Set(reflect.ValueOf(testAllRule{}))
+		field.Set(list)
+	}
+
+	rules := Rules{}
+	rules.Merge(other)
+
+	for name, l := range fieldLengths(rules) {
+		if e, a := 1, l; e != a {
+			t.Errorf("%s: expected %d, but received %d, Merge may be missing the field", name, e, a)
+		}
+	}
+}
+
+func TestRulesListTypes(t *testing.T) {
+	expected := map[NodeType]string{
+		PackageNode:       "PackageRules",
+		FileNode:          "FileRules",
+		TypeSpecNode:      "TypeSpecRules",
+		ValueSpecNode:     "ValueSpecRules",
+		GenDeclNode:       "GenDeclRules",
+		FuncDeclNode:      "FuncDeclRules",
+		CallExprNode:      "CallExprRules",
+		BinaryExprNode:    "BinaryExprRules",
+		AssignStmtNode:    "AssignStmtRules",
+		BlockStmtNode:     "BlockStmtRules",
+		ReturnStmtNode:    "ReturnStmtRules",
+		IncDecStmtNode:    "IncDecStmtRules",
+		RangeStmtNode:     "RangeStmtRules",
+		StructTypeNode:    "StructTypeRules",
+		FieldNode:         "FieldRules",
+		FieldListNode:     "FieldListRules",
+		FuncTypeNode:      "FuncTypeRules",
+		InterfaceTypeNode: "InterfaceTypeRules",
+		ArrayTypeNode:     "ArrayTypeRules",
+		ChanTypeNode:      "ChanTypeRules",
+		MapTypeNode:       "MapTypeRules",
+	}
+
+	rulesType := reflect.TypeOf(Rules{})
+	if e, a := len(expected), rulesType.NumField(); e != a {
+		t.Fatalf("expected %d rule lists, but received %d", e, a)
+	}
+
+	for i := 0; i < rulesType.NumField(); i++ {
+		if e, a := expected[ruleListType(i)], rulesType.Field(i).Name; e != a {
+			t.Errorf("%d: expected %s, but received %s", i, e, a)
+		}
+	}
+
+	if e, a := AllNodes, ruleListType(rulesType.NumField())-1; e != a {
+		t.Errorf("expected %b, but received %b", e, a)
+	}
+}
+
+func TestRulesRegister(t *testing.T) {
+	cases := []struct {
+		name          string
+		rule          Rule
+		expectedTypes NodeType
+	}{
+		{
+			name:          "every rule list",
+			rule:          testAllRule{},
+			expectedTypes: AllNodes,
+		},
+		{
+			name:          "not a rule",
+			rule:          struct{}{},
+			expectedTypes: 0,
+		},
+		{
+			name:          "partial rule",
+			rule:          &testCountCallExpr{},
+			expectedTypes: FileNode | FuncDeclNode | CallExprNode | BinaryExprNode,
+		},
+		{
+			name:          "node filter",
+			rule:          &testFilteredCallExpr{},
+			expectedTypes: CallExprNode,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rules := Rules{}
+			rules.Register(c.rule)
+
+			if e, a := c.expectedTypes, rules.NodeTypes(); e != a {
+				t.Errorf("expected %b, but received %b", e, a)
+			}
+
+			for name, l := range fieldLengths(rules) {
+				if l > 1 {
+					t.Errorf("%s: expected at most 1 rule, but received %d", name, l)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,628 @@
+package pepperlint
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// Options determines which packages Run will lint and with what rules.
+type Options struct {
+	// Patterns are the files and directories that will be linted. Directories
+	// are walked and every package within them is linted.
+	Patterns []string
+
+	// IncludePkgs are import paths, relative to $GOPATH/src, of packages that
+	// are not linted, but are cached so rules can look up declarations from
+	// them.
+	IncludePkgs []string
+
+	// Overlay contains the contents of files, keyed by path, that are linted
+	// in place of the files on disk. Overlaid files do not need to exist on
+	// disk, which allows for unsaved files to be linted.
+	Overlay map[string][]byte
+
+	// BuildTags, GOOS and GOARCH determine which files of a directory are
+	// linted, using the same build constraints and file name suffixes as the
+	// go tool. GOOS and GOARCH default to those of go/build's default context.
+	BuildTags []string
+	GOOS      string
+	GOARCH    string
+
+	// ExcludeTests will leave _test.go files out of the packages of any
+	// directory that is linted or included. Test files that are passed as a
+	// pattern are still linted.
+	ExcludeTests bool
+
+	// IncludeGenerated will lint files that are marked as generated. Otherwise
+	// generated files are only cached, so their declarations can still be
+	// looked up, but no diagnostics are reported for them.
+	IncludeGenerated bool
+
+	// DiskCache, if set, is used to load the declarations of included packages
+	// that have not changed since a previous run, instead of parsing them. The
+	// diagnostics of linted packages are stored in it as well, and reused by
+	// runs where neither the package, the packages it imports, nor the rules
+	// have changed.
+	DiskCache *DiskCache
+
+	// Rules are copied for every package that is linted, so no rule is shared
+	// between packages that are linted at the same time.
+	Rules []CopyRuler
+
+	// Suppressions will remove any matching diagnostics from the result.
+	Suppressions []Suppression
+
+	// Jobs is the number of packages that will be linted in parallel.
+	Jobs int
+
+	// Stats will collect the time spent in each phase of a run and in each
+	// rule, which is returned as the Stats of the result.
+	Stats bool
+
+	// Logger is given to the visitor of every package, and is used for the
+	// entries of the run itself. DefaultLogger is used if it is nil.
+	Logger Logger
+
+	// Relint will lint every package, instead of reusing the diagnostics of
+	// packages that have not changed, which are still cached for later runs.
+	// Loggers that aggregate what rules log, such as an UnhandledReport, need
+	// it, as nothing is logged for packages whose diagnostics are reused.
+	Relint bool
+}
+
+// Suppression will suppress the diagnostics of a file. If Line is set, only the
+// diagnostics on that line are suppressed.
+type Suppression struct {
+	Filename string
+	Line     int
+}
+
+// Result is the outcome of a lint run.
+type Result struct {
+	// Diagnostics are all diagnostics that were not suppressed, in the order
+	// returned by Errors.Diagnostics.
+	Diagnostics []Diagnostic
+
+	// Packages is the number of packages that were linted, and CachedPackages
+	// the number of those whose diagnostics were loaded from the disk cache,
+	// or reused from a previous run of the same Linter.
+	Packages       int
+	CachedPackages int
+
+	// Stats is only set if the options enabled them.
+	Stats *Stats
+}
+
+// Errors will return the diagnostics of the result as Errors.
+func (r Result) Errors() Errors {
+	errs := Errors{}
+	for _, diag := range r.Diagnostics {
+		errs = append(errs, diag)
+	}
+
+	return errs
+}
+
+// Run will lint the packages matching the options. All packages are parsed
+// and cached before any rule is run. If ctx is cancelled, Run stops as soon as
+// it can and returns the context's error.
+func Run(ctx context.Context, opts Options) (Result, error) {
+	l, err := NewLinter(ctx, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return l.Run(ctx, opts.Patterns, opts.Overlay)
+}
+
+// Linter lints packages against included packages that are only loaded once,
+// so they can be reused by every run. This keeps repeated runs, such as
+// linting a file every time it is edited, from loading the included packages
+// again.
+//
+// A Linter also keeps what it can from one run to the next. Files that have
+// not changed are not parsed again, the cache entries of packages whose files
+// have not changed are reused, and packages are only linted again when they,
+// the declarations of packages they import, or the rules have changed.
+type Linter struct {
+	opts Options
+
+	// fset is the file set of the previous run, or the file set the included
+	// packages were parsed into before the first run. includeBase is the base
+	// of the first file that is not part of an included package.
+	fset        *token.FileSet
+	includeBase int
+
+	include []dirPackages
+	errs    Errors
+	hashes  map[*ast.File]fileHash
+
+	// lock is held for the whole of a run, so runs never share any of the
+	// state below.
+	lock sync.Mutex
+
+	// cache contains the included packages, and is only built once a run
+	// needs it.
+	cache *Cache
+
+	// files, packages and results are kept from the previous run.
+	files    map[string]parsedFile
+	packages map[string]lintedPackage
+	results  map[string]Errors
+}
+
+// lintedPackage is the cache entry of a linted package along with the files it
+// was built from.
+type lintedPackage struct {
+	files map[string]*ast.File
+	pkg   *Package
+}
+
+// NewLinter will load the included packages of the options. The patterns and
+// overlay of the options are not used, since they are given to each run
+// instead, but the overlay still applies to files of included packages.
+func NewLinter(ctx context.Context, opts Options) (*Linter, error) {
+	gopath := filepath.Join(os.Getenv("GOPATH"), "src")
+
+	includeDirs := []string{}
+	for _, p := range opts.IncludePkgs {
+		if len(p) == 0 {
+			continue
+		}
+
+		includeDirs = append(includeDirs, filepath.Join(gopath, p))
+	}
+
+	includeOpts := opts
+	includeOpts.Patterns = nil
+
+	fset := token.NewFileSet()
+	set, err := loadPackages(ctx, fset, includeOpts, newOverlay(opts.Overlay), includeDirs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Linter{
+		opts:        opts,
+		fset:        fset,
+		includeBase: fset.Base(),
+		include:     set.include,
+		errs:        set.errs,
+		hashes:      set.hashes,
+		files:       map[string]parsedFile{},
+		packages:    map[string]lintedPackage{},
+		results:     map[string]Errors{},
+	}, nil
+}
+
+// Run will lint the packages matching patterns, with the files in overlay
+// linted in place of the files on disk. Run can be called any number of times,
+// and runs that are called concurrently are run one after the other.
+func (l *Linter) Run(ctx context.Context, patterns []string, overlay map[string][]byte) (Result, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	opts := l.opts
+	opts.Patterns = patterns
+	opts.Overlay = overlay
+	logger := loggerOrDefault(opts.Logger)
+
+	var stats *Stats
+	var rules *ruleStatsSet
+	var p *phase
+	if opts.Stats {
+		stats = &Stats{}
+		rules = &ruleStatsSet{rules: ruleStats{}}
+		p = startPhase()
+	}
+
+	fset := l.fileSet()
+	set, err := loadPackages(ctx, fset, opts, newOverlay(overlay), nil, l.files)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if stats != nil {
+		stats.Load = p.stop()
+	}
+	set.include = l.include
+	set.includeHashes = l.hashes
+
+	pkgs := sortedPackages(set.lint)
+
+	// results of packages that have not changed are reused from the previous
+	// run, or loaded from the disk cache, and every other package is linted.
+	// Nothing is reused when relinting.
+	var keys []string
+	results := make([]Errors, len(pkgs))
+	reused := make([]bool, len(pkgs))
+	cached := 0
+	if key, err := rulesKey(opts.Rules); err != nil {
+		logger.Log(WarnLevel, fmt.Sprintf("not caching results: %v", err))
+	} else {
+		keys = set.resultKeys(pkgs, key, opts)
+	}
+
+	for i, key := range keys {
+		if opts.Relint {
+			continue
+		} else if errs, ok := l.results[key]; ok {
+			results[i], reused[i] = errs, true
+			cached++
+		} else if opts.DiskCache == nil {
+			continue
+		} else if errs, ok := opts.DiskCache.getResult(key, logger); ok {
+			results[i], reused[i] = errs, true
+			cached++
+		}
+	}
+
+	// Every package needs to be cached before any rule is run since rules look
+	// up declarations from other packages, and from files of the current
+	// package that have yet to be visited. Caching only walks top level
+	// declarations, which leaves a single full walk for linting. Nothing needs
+	// to be cached if the results of every package were reused.
+	var cache *Cache
+	if cached < len(pkgs) {
+		if stats != nil {
+			p = startPhase()
+		}
+
+		if cache, err = l.packageCache(ctx, pkgs, set.files); err != nil {
+			return Result{}, err
+		}
+
+		if stats != nil {
+			stats.Cache = p.stop()
+		}
+	}
+
+	if stats != nil {
+		p = startPhase()
+	}
+
+	errs, err := lintPackages(ctx, fset, cache, pkgs, results, reused, keys, opts, rules)
+	if err != nil {
+		return Result{}, err
+	}
+
+	l.fset = fset
+	if stats != nil {
+		stats.Lint = p.stop()
+		stats.Rules = rules.sorted()
+	}
+
+	l.files = set.files
+	l.results = make(map[string]Errors, len(keys))
+	for i, key := range keys {
+		l.results[key] = results[i]
+	}
+
+	all := Errors{}
+	all = append(all, l.errs...)
+	all = append(all, set.errs...)
+	all = append(all, errs...)
+
+	logger.Log(InfoLevel, "linted packages",
+		Field{Key: "packages", Value: len(pkgs)},
+		Field{Key: "cached", Value: cached},
+	)
+
+	diags := suppress(opts.Suppressions, all.Diagnostics())
+	return Result{
+		Diagnostics:    ignore(ignoreDirectives(fset, pkgs), diags),
+		Packages:       len(pkgs),
+		CachedPackages: cached,
+		Stats:          stats,
+	}, nil
+}
+
+// fileSet will return a new file set for a run. It contains the files of the
+// included packages, and the files of the previous run so they can be reused,
+// but none of the files that previous runs no longer need. This keeps the file
+// set from growing with every run. Files keep their base, so positions within
+// them stay the same.
+func (l *Linter) fileSet() *token.FileSet {
+	bases := map[int]struct{}{}
+	for _, file := range l.files {
+		if tf := l.fset.File(file.f.Pos()); tf != nil {
+			bases[tf.Base()] = struct{}{}
+		}
+	}
+
+	return copyFileSet(l.fset, func(base int) bool {
+		_, ok := bases[base]
+		return ok || base < l.includeBase
+	})
+}
+
+// copyFileSet will return a copy of fset with only the files whose base keep
+// returns true for. New files are added after every file of fset, including
+// those that were not kept.
+func copyFileSet(fset *token.FileSet, keep func(base int) bool) *token.FileSet {
+	var state reflect.Value
+	fset.Write(func(v interface{}) error {
+		state = reflect.ValueOf(v)
+		return nil
+	})
+
+	files := state.FieldByName("Files")
+	kept := reflect.MakeSlice(files.Type(), 0, files.Len())
+	for i := 0; i < files.Len(); i++ {
+		if keep(int(files.Index(i).FieldByName("Base").Int())) {
+			kept = reflect.Append(kept, files.Index(i))
+		}
+	}
+
+	cp := token.NewFileSet()
+	cp.Read(func(v interface{}) error {
+		copied := reflect.ValueOf(v).Elem()
+		copied.Set(state)
+		copied.FieldByName("Files").Set(kept)
+		return nil
+	})
+
+	return cp
+}
+
+// Cache will return the cache that the packages matching patterns would be
+// linted with, along with the file set of its positions, without running any
+// rule. It shows what rules are able to look up.
+func (l *Linter) Cache(ctx context.Context, patterns []string, overlay map[string][]byte) (*Cache, *token.FileSet, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	opts := l.opts
+	opts.Patterns = patterns
+	opts.Overlay = overlay
+
+	fset := l.fileSet()
+	set, err := loadPackages(ctx, fset, opts, newOverlay(overlay), nil, l.files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache, err := l.packageCache(ctx, sortedPackages(set.lint), set.files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l.fset = fset
+	l.files = set.files
+	return cache, fset, nil
+}
+
+// packageCache will return a cache of the included packages and pkgs, along
+// with the sources of files. The cache entries of packages whose files are the
+// same as in the previous run are reused, and every other package is cached
+// again.
+func (l *Linter) packageCache(ctx context.Context, pkgs []*ast.Package, files map[string]parsedFile) (*Cache, error) {
+	if l.cache == nil {
+		cache := NewCache()
+		for _, pkg := range sortedPackages(l.include) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			WalkPackage(cache, pkg)
+		}
+
+		l.cache = cache
+	}
+
+	// packages that share an import path, or are part of an included
+	// package, are cached into the same entry, so they are never reused
+	importPaths := map[string]int{}
+	for _, pkg := range pkgs {
+		importPaths[GetImportPathFromPackage(pkg)]++
+	}
+
+	cache := l.cache.Copy()
+	cache.sources = make(map[*ast.File][]byte, len(files))
+	for _, file := range files {
+		cache.sources[file.f] = file.src
+	}
+
+	packages := map[string]lintedPackage{}
+	for _, pkg := range pkgs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		importPath := GetImportPathFromPackage(pkg)
+		_, included := l.cache.Packages[importPath]
+		reusable := !included && importPaths[importPath] == 1
+
+		if prev, ok := l.packages[importPath]; ok && reusable && sameFiles(prev.files, pkg.Files) {
+			cache.Packages[importPath] = prev.pkg
+			packages[importPath] = prev
+			continue
+		}
+
+		WalkPackage(cache, pkg)
+		if reusable {
+			packages[importPath] = lintedPackage{
+				files: pkg.Files,
+				pkg:   cache.Packages[importPath],
+			}
+		}
+	}
+
+	l.packages = packages
+	return cache, nil
+}
+
+func sameFiles(a, b map[string]*ast.File) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for filename, f := range a {
+		if b[filename] != f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// buildContext will return go/build's default context with the build tags,
+// GOOS and GOARCH of the options.
+func (opts Options) buildContext() build.Context {
+	buildContext := build.Default
+	buildContext.BuildTags = opts.BuildTags
+
+	if len(opts.GOOS) > 0 {
+		buildContext.GOOS = opts.GOOS
+	}
+
+	if len(opts.GOARCH) > 0 {
+		buildContext.GOARCH = opts.GOARCH
+	}
+
+	return buildContext
+}
+
+// lintPackages will validate every package in pkgs with up to opts.Jobs
+// packages being linted at the same time. Each package is linted by its own
+// visitor with its own copy of the rules and snapshot of the cache, so the cache
+// must be fully built beforehand. Errors are merged in the order the packages
+// are walked, which keeps the output the same regardless of the number of jobs.
+//
+// Packages that are marked as reused, such as those whose results were loaded
+// from the disk cache, are not linted again, and cache may only be nil if
+// every package is reused. If keys are provided and the options have a disk
+// cache, the errors of every other package are stored in it under the
+// package's key. If stats is not nil, the stats of every rule are added to it.
+func lintPackages(ctx context.Context, fset *token.FileSet, cache *Cache, pkgs []*ast.Package, results []Errors, reused []bool, keys []string, opts Options, stats *ruleStatsSet) (Errors, error) {
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	logger := loggerOrDefault(opts.Logger)
+
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for idx := range work {
+				ruleOpts := make([]Option, 0, len(opts.Rules)+1)
+				ruleOpts = append(ruleOpts, WithLogger(logger))
+				for _, rule := range opts.Rules {
+					ruleOpts = append(ruleOpts, rule.CopyRule())
+				}
+
+				pkg := pkgs[idx]
+				if !opts.IncludeGenerated {
+					pkg = withoutGenerated(pkg)
+				}
+
+				v := NewVisitor(fset, cache.Snapshot(), ruleOpts...)
+				if stats != nil {
+					v.stats = ruleStats{}
+				}
+
+				WalkPackage(v, pkg)
+				results[idx] = v.Errors
+
+				if stats != nil {
+					stats.add(v.stats)
+				}
+
+				if keys != nil && opts.DiskCache != nil {
+					if err := opts.DiskCache.putResult(keys[idx], v.Errors); err != nil {
+						logger.Log(WarnLevel, fmt.Sprintf("unable to cache results of %s: %v", pkg.Name, err))
+					}
+				}
+			}
+		}()
+	}
+
+	done := ctx.Done()
+loop:
+	for i := range pkgs {
+		if reused != nil && reused[i] {
+			continue
+		}
+
+		select {
+		case work <- i:
+		case <-done:
+			break loop
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	errs := Errors{}
+	for _, result := range results {
+		errs = append(errs, result...)
+	}
+
+	return errs, nil
+}
+
+// withoutGenerated will return the package without any of its generated files.
+// The package itself is returned if it has no generated files.
+func withoutGenerated(pkg *ast.Package) *ast.Package {
+	files := map[string]*ast.File{}
+	for filename, f := range pkg.Files {
+		if !IsGenerated(f) {
+			files[filename] = f
+		}
+	}
+
+	if len(files) == len(pkg.Files) {
+		return pkg
+	}
+
+	return &ast.Package{
+		Name:    pkg.Name,
+		Scope:   pkg.Scope,
+		Imports: pkg.Imports,
+		Files:   files,
+	}
+}
+
+// suppress will return every diagnostic that does not match any of the
+// suppressions.
+func suppress(suppressions []Suppression, diags []Diagnostic) []Diagnostic {
+	s := map[string][]Suppression{}
+	for _, sup := range suppressions {
+		s[sup.Filename] = append(s[sup.Filename], sup)
+	}
+
+	valid := []Diagnostic{}
+	for _, diag := range diags {
+		if !suppressed(s[diag.Filename()], diag) {
+			valid = append(valid, diag)
+		}
+	}
+
+	return valid
+}
+
+func suppressed(suppressions []Suppression, diag Diagnostic) bool {
+	for _, sup := range suppressions {
+		if sup.Line == 0 || sup.Line == diag.LineNumber() {
+			return true
+		}
+	}
+
+	return false
+}
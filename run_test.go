@@ -0,0 +1,951 @@
+package pepperlint
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"foo.go": "package foo\n\ntype Foo struct {\n\tA int\n}\n",
+		"bar.go": "package foo\n\ntype Bar struct {\n\tB int\n\tC int\n}\n",
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := Options{
+		Patterns: []string{dir},
+		Rules:    []CopyRuler{&testFieldPositions{}},
+		Suppressions: []Suppression{
+			{
+				Filename: filepath.Join(dir, "bar.go"),
+				Line:     4,
+			},
+		},
+		Jobs: 2,
+	}
+
+	result, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	names := []string{}
+	for _, diag := range result.Diagnostics {
+		names = append(names, filepath.Base(diag.Filename())+":"+diag.Err.(*ErrorWrap).Message())
+	}
+
+	if e, a := []string{"bar.go:C", "foo.go:A"}, names; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := "package foo\n\ntype Foo struct {\n\tA int\n\tB, C int\n}\n\ntype Bar struct {\n\tD int\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{
+		Patterns: []string{dir},
+		Rules:    []CopyRuler{&testFieldPositions{}},
+	}
+
+	result, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if result.Stats != nil {
+		t.Errorf("expected no stats, but received %v", result.Stats)
+	}
+
+	opts.Stats = true
+	result, err = Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	stats := result.Stats
+	if stats == nil {
+		t.Fatalf("expected stats")
+	}
+
+	if stats.Load.Time <= 0 || stats.Cache.Time <= 0 || stats.Lint.Time <= 0 {
+		t.Errorf("expected time in every phase, but received %+v", stats)
+	}
+
+	if e, a := 1, len(stats.Rules); e != a {
+		t.Fatalf("expected %d rules, but received %v", e, stats.Rules)
+	}
+
+	rule := stats.Rules[0]
+	rule.Time = 0
+	expected := RuleStats{
+		Rule:     "*pepperlint.testFieldPositions",
+		Nodes:    3,
+		Findings: 4,
+	}
+
+	if e, a := expected, rule; e != a {
+		t.Errorf("expected %+v, but received %+v", e, a)
+	}
+}
+
+func TestRunOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "foo.go"), []byte("package foo\n\ntype Foo struct {\n\tA int\n}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overlay := map[string][]byte{
+		filepath.Join(dir, "foo.go"):        []byte("package foo\n\ntype Foo struct {\n\tB int\n}\n"),
+		filepath.Join(dir, "bar.go"):        []byte("package foo\n\ntype Bar struct {\n\tC int\n}\n"),
+		filepath.Join(dir, "sub", "baz.go"): []byte("package sub\n\ntype Baz struct {\n\tD int\n}\n"),
+	}
+
+	cases := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{
+			name:     "directory",
+			patterns: []string{dir},
+			expected: []string{"bar.go:4:C", "foo.go:4:B"},
+		},
+		{
+			name:     "file",
+			patterns: []string{filepath.Join(dir, "foo.go")},
+			expected: []string{"foo.go:4:B"},
+		},
+		{
+			name:     "file not on disk",
+			patterns: []string{filepath.Join(dir, "sub", "baz.go")},
+			expected: []string{"baz.go:4:D"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := Run(context.Background(), Options{
+				Patterns: c.patterns,
+				Overlay:  overlay,
+				Rules:    []CopyRuler{&testFieldPositions{}},
+			})
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			names := []string{}
+			for _, diag := range result.Diagnostics {
+				names = append(names, fmt.Sprintf("%s:%d:%s", filepath.Base(diag.Filename()), diag.LineNumber(), diag.Err.(*ErrorWrap).Message()))
+			}
+
+			if e, a := c.expected, names; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+
+func TestRunPatterns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a/a.go":          "A",
+		"a/b/b.go":        "B",
+		"a/vendor/v.go":   "V",
+		"a/testdata/t.go": "T",
+		"a/.hidden/h.go":  "H",
+		"a/_skip/s.go":    "S",
+		"c/c.go":          "C",
+	}
+
+	for name, field := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		src := fmt.Sprintf("package foo\n\ntype Foo struct {\n\t%s int\n}\n", field)
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{
+			name:     "directory",
+			patterns: []string{"a"},
+			expected: []string{"A"},
+		},
+		{
+			name:     "file",
+			patterns: []string{"a/b/b.go"},
+			expected: []string{"B"},
+		},
+		{
+			name:     "recursive",
+			patterns: []string{"a/..."},
+			expected: []string{"A", "B"},
+		},
+		{
+			name:     "wildcard",
+			patterns: []string{"..."},
+			expected: []string{"A", "B", "C"},
+		},
+		{
+			name:     "partial wildcard",
+			patterns: []string{"a/b..."},
+			expected: []string{"B"},
+		},
+		{
+			name:     "multiple",
+			patterns: []string{"a", "c", "a/...", "a/b/b.go"},
+			expected: []string{"A", "B", "C"},
+		},
+		{
+			name:     "skipped directory as root",
+			patterns: []string{"a/testdata/..."},
+			expected: []string{"T"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patterns := []string{}
+			for _, pattern := range c.patterns {
+				patterns = append(patterns, filepath.Join(dir, filepath.FromSlash(pattern)))
+			}
+
+			result, err := Run(context.Background(), Options{
+				Patterns: patterns,
+				Rules:    []CopyRuler{&testFieldPositions{}},
+			})
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			fields := []string{}
+			for _, diag := range result.Diagnostics {
+				fields = append(fields, diag.Err.(*ErrorWrap).Message())
+			}
+
+			if e, a := c.expected, fields; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+
+func TestRunBuildConstraints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a.go":         "package foo\n\ntype Foo struct {\n\tA int\n}\n",
+		"b_linux.go":   "package foo\n\ntype Bar struct {\n\tLinux int\n}\n",
+		"b_windows.go": "package foo\n\ntype Bar struct {\n\tWindows int\n}\n",
+		"c_arm64.go":   "package foo\n\ntype Baz struct {\n\tArm64 int\n}\n",
+		"d.go":         "// +build custom\n\npackage foo\n\ntype Qux struct {\n\tCustom int\n}\n",
+		"e.go":         "// +build ignore\n\npackage main\n\ntype Ignored struct {\n\tIgnored int\n}\n",
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{
+			name: "linux",
+			opts: Options{
+				GOOS:   "linux",
+				GOARCH: "amd64",
+			},
+			expected: []string{"A", "Linux"},
+		},
+		{
+			name: "windows arm64",
+			opts: Options{
+				GOOS:   "windows",
+				GOARCH: "arm64",
+			},
+			expected: []string{"A", "Windows", "Arm64"},
+		},
+		{
+			name: "tags",
+			opts: Options{
+				GOOS:      "linux",
+				GOARCH:    "amd64",
+				BuildTags: []string{"custom"},
+			},
+			expected: []string{"A", "Linux", "Custom"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.opts.Patterns = []string{dir}
+			c.opts.Rules = []CopyRuler{&testFieldPositions{}}
+
+			result, err := Run(context.Background(), c.opts)
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			fields := []string{}
+			for _, diag := range result.Diagnostics {
+				fields = append(fields, diag.Err.(*ErrorWrap).Message())
+			}
+
+			if e, a := c.expected, fields; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"foo.go":          "package foo\n",
+		"foo_test.go":     "package foo\n",
+		"foo_ext_test.go": "package foo_test\n",
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name         string
+		excludeTests bool
+		expected     []string
+	}{
+		{
+			name:     "tests",
+			expected: []string{"foo_ext_test.go:foo_test", "foo_test.go:foo"},
+		},
+		{
+			name:         "exclude tests",
+			excludeTests: true,
+			expected:     []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := Run(context.Background(), Options{
+				Patterns:     []string{dir},
+				ExcludeTests: c.excludeTests,
+				Rules:        []CopyRuler{&testTestFiles{}},
+			})
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			testFiles := []string{}
+			for _, diag := range result.Diagnostics {
+				testFiles = append(testFiles, filepath.Base(diag.Filename())+":"+diag.Err.(*ErrorWrap).Message())
+			}
+
+			if e, a := c.expected, testFiles; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+
+func TestLoadPackagesFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a/a.go", "a/b.go", "b/a.go", "c/c.go"} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filename, []byte("package foo\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		patterns []string
+		expected [][]string
+	}{
+		{
+			name:     "same directory",
+			patterns: []string{"a/a.go", "a/b.go"},
+			expected: [][]string{{"a/a.go", "a/b.go"}},
+		},
+		{
+			name:     "different directories",
+			patterns: []string{"a/a.go", "b/a.go"},
+			expected: [][]string{{"a/a.go"}, {"b/a.go"}},
+		},
+		{
+			name:     "file before its directory",
+			patterns: []string{"a/a.go", "a"},
+			expected: [][]string{{"a/a.go", "a/b.go"}},
+		},
+		{
+			name:     "file after its directory",
+			patterns: []string{"a", "c", "a/b.go"},
+			expected: [][]string{{"a/a.go", "a/b.go"}, {"c/c.go"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patterns := []string{}
+			for _, pattern := range c.patterns {
+				patterns = append(patterns, filepath.Join(dir, filepath.FromSlash(pattern)))
+			}
+
+			set, err := loadPackages(context.Background(), token.NewFileSet(), Options{Patterns: patterns}, overlay{}, nil, nil)
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			pkgs := [][]string{}
+			for _, pkg := range sortedPackages(set.lint) {
+				filenames := []string{}
+				for filename := range pkg.Files {
+					rel, err := filepath.Rel(dir, filename)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					filenames = append(filenames, filepath.ToSlash(rel))
+				}
+				sort.Strings(filenames)
+
+				pkgs = append(pkgs, filenames)
+			}
+
+			if e, a := c.expected, pkgs; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Run(ctx, Options{
+		Patterns: []string{"testdata"},
+		Rules:    []CopyRuler{&testFieldPositions{}},
+	})
+
+	if e, a := context.Canceled, err; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestRunSuppress(t *testing.T) {
+	diag := func(filename string, line int) Diagnostic {
+		return Diagnostic{
+			Pos: token.Position{
+				Filename: filename,
+				Line:     line,
+			},
+		}
+	}
+
+	cases := []struct {
+		name          string
+		diags         []Diagnostic
+		suppressions  []Suppression
+		expectedDiags []Diagnostic
+	}{
+		{
+			name:          "empty case",
+			expectedDiags: []Diagnostic{},
+		},
+		{
+			name: "simple supression by filename",
+			suppressions: []Suppression{
+				{
+					Filename: "foo.go",
+				},
+			},
+			diags: []Diagnostic{
+				diag("foo.go", 0),
+				diag("bar.go", 0),
+			},
+			expectedDiags: []Diagnostic{
+				diag("bar.go", 0),
+			},
+		},
+		{
+			name: "simple supression by filename and line",
+			suppressions: []Suppression{
+				{
+					Filename: "foo.go",
+					Line:     5,
+				},
+			},
+			diags: []Diagnostic{
+				diag("bar.go", 1),
+				diag("foo.go", 5),
+				diag("foo.go", 6),
+				diag("bar.go", 10),
+			},
+			expectedDiags: []Diagnostic{
+				diag("bar.go", 1),
+				diag("foo.go", 6),
+				diag("bar.go", 10),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			diags := suppress(c.suppressions, c.diags)
+
+			if e, a := c.expectedDiags, diags; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		})
+	}
+}
+
+func TestLinter(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", gopath)
+
+	included := filepath.Join(gopath, "src", "inc", "inc.go")
+	if err := os.MkdirAll(filepath.Dir(included), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(included, []byte("package inc\n\ntype Inc struct {\n\tI int\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewLinter(context.Background(), Options{
+		IncludePkgs: []string{"inc"},
+		Rules:       []CopyRuler{&testFieldPositions{}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	filename := filepath.Join(gopath, "src", "foo", "foo.go")
+	srcs := []string{
+		"package foo\n\ntype Foo struct {\n\tA int\n}\n",
+		"package foo\n\ntype Foo struct {\n\tA int\n\tB int\n}\n",
+	}
+
+	var includeCache *Cache
+	for i, src := range srcs {
+		result, err := l.Run(context.Background(), []string{filename}, map[string][]byte{
+			filename: []byte(src),
+		})
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		if e, a := i+1, len(result.Diagnostics); e != a {
+			t.Errorf("expected %d diagnostics, but received %d", e, a)
+		}
+
+		if i > 0 && l.cache != includeCache {
+			t.Errorf("expected included packages to only be cached once")
+		}
+		includeCache = l.cache
+
+		if _, ok := l.cache.Packages.Get("foo"); ok {
+			t.Errorf("expected linted package to not be added to the included packages")
+		}
+
+		if _, ok := l.cache.Packages.Get("inc"); !ok {
+			t.Errorf("expected included package to be cached")
+		}
+
+		// only the included file and the file of the last run are kept by
+		// the file set the next run starts from
+		files := 0
+		l.fileSet().Iterate(func(*token.File) bool {
+			files++
+			return true
+		})
+
+		if e, a := 2, files; e != a {
+			t.Errorf("expected %d files, but received %d", e, a)
+		}
+	}
+
+	// while the included files are found by every run
+	for _, pkg := range sortedPackages(l.include) {
+		for _, f := range pkg.Files {
+			if e, a := included, l.fileSet().Position(f.Pos()).Filename; e != a {
+				t.Errorf("expected %v, but received %v", e, a)
+			}
+		}
+	}
+}
+
+func TestLinterRunTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "foo", "foo.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// the package has no fields, so it is clean and has no errors to reuse
+	if err := ioutil.WriteFile(filename, []byte("package foo\n\ntype Foo struct{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	newLinter := func(diskCache *DiskCache) *Linter {
+		l, err := NewLinter(context.Background(), Options{
+			DiskCache: diskCache,
+			Rules:     []CopyRuler{&testFieldPositions{}},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		return l
+	}
+
+	run := func(l *Linter, expectedCached int) {
+		result, err := l.Run(context.Background(), []string{filename}, nil)
+		if err != nil {
+			t.Fatalf("expected no error, but received %v", err)
+		}
+
+		if e, a := 0, len(result.Diagnostics); e != a {
+			t.Errorf("expected %d diagnostics, but received %d", e, a)
+		}
+
+		if e, a := expectedCached, result.CachedPackages; e != a {
+			t.Errorf("expected %d cached packages, but received %d", e, a)
+		}
+	}
+
+	// results reused from the previous run of the same linter
+	l := newLinter(nil)
+	run(l, 0)
+	run(l, 1)
+
+	// results reused from the disk cache by another linter
+	diskCache := NewDiskCache(filepath.Join(dir, "cache"))
+	run(newLinter(diskCache), 0)
+	run(newLinter(diskCache), 1)
+}
+
+func TestLinterRelint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "foo", "foo.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte("package foo\n\nfunc foo() {\n\ta := b\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		relint         bool
+		expectedCached int
+		expectedCount  int
+	}{
+		"reused": {
+			expectedCached: 1,
+			expectedCount:  1,
+		},
+		"relinted": {
+			relint:        true,
+			expectedCount: 2,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			report := NewUnhandledReport(nil)
+			l, err := NewLinter(context.Background(), Options{
+				Rules:  []CopyRuler{&testUnhandledRule{}},
+				Logger: report,
+				Relint: c.relint,
+			})
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			var result Result
+			for i := 0; i < 2; i++ {
+				if result, err = l.Run(context.Background(), []string{filename}, nil); err != nil {
+					t.Fatalf("expected no error, but received %v", err)
+				}
+			}
+
+			if e, a := c.expectedCached, result.CachedPackages; e != a {
+				t.Errorf("expected %d cached packages, but received %d", e, a)
+			}
+
+			nodes := report.Nodes()
+			if e, a := 1, len(nodes); e != a {
+				t.Fatalf("expected %d unhandled nodes, but received %d", e, a)
+			}
+
+			if e, a := c.expectedCount, nodes[0].Count; e != a {
+				t.Errorf("expected %d, but received %d", e, a)
+			}
+		})
+	}
+}
+
+func TestLinterCache(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", gopath)
+
+	included := filepath.Join(gopath, "src", "inc", "inc.go")
+	if err := os.MkdirAll(filepath.Dir(included), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(included, []byte("package inc\n\ntype Inc struct{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewLinter(context.Background(), Options{
+		IncludePkgs: []string{"inc"},
+		Rules:       []CopyRuler{&testFieldPositions{}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	filename := filepath.Join(gopath, "src", "foo", "foo.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte("package foo\n\ntype Foo struct{}\n\nfunc (f *Foo) Bar() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, fset, err := l.Cache(context.Background(), []string{filepath.Dir(filename)}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	if _, ok := cache.Packages.Get("inc"); !ok {
+		t.Errorf("expected included package to be cached")
+	}
+
+	pkg, ok := cache.Packages.Get("foo")
+	if !ok {
+		t.Fatalf("expected linted package to be cached")
+	}
+
+	op, ok := pkg.Method("Foo", "Bar")
+	if !ok {
+		t.Fatalf("expected Bar to be cached")
+	}
+
+	if e, a := "foo.go:5:1", filepath.Base(fset.Position(op.Decl.Pos()).String()); e != a {
+		t.Errorf("expected %q, but received %q", e, a)
+	}
+}
+
+func TestLinterIncremental(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	os.Setenv("GOPATH", gopath)
+
+	writeFile := func(name, content string) {
+		filename := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("a/a.go", "package a\n\nimport \"b\"\n\ntype A struct {\n\tA b.B\n}\n")
+	writeFile("b/b.go", "package b\n\ntype B struct {\n\tB int\n}\n\nfunc f() {}\n")
+	writeFile("c/c.go", "package c\n\ntype C struct {\n\tC int\n}\n")
+
+	l, err := NewLinter(context.Background(), Options{
+		Rules: []CopyRuler{&testFieldPositions{}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	cases := []struct {
+		name           string
+		change         func()
+		expectedCached int
+		expectedParsed []string
+		expectedFields int
+	}{
+		{
+			name:           "first run",
+			expectedParsed: []string{"a.go", "b.go", "c.go"},
+			expectedFields: 3,
+		},
+		{
+			name:           "nothing changed",
+			expectedCached: 3,
+			expectedFields: 3,
+		},
+		{
+			name: "function body changed",
+			change: func() {
+				writeFile("b/b.go", "package b\n\ntype B struct {\n\tB int\n}\n\nfunc f() { f() }\n")
+			},
+			expectedCached: 2,
+			expectedParsed: []string{"b.go"},
+			expectedFields: 3,
+		},
+		{
+			name: "declaration changed",
+			change: func() {
+				writeFile("b/b.go", "package b\n\ntype B struct {\n\tB int\n\tD int\n}\n\nfunc f() { f() }\n")
+			},
+			expectedCached: 1,
+			expectedParsed: []string{"b.go"},
+			expectedFields: 4,
+		},
+	}
+
+	pattern := filepath.Join(gopath, "src", "...")
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.change != nil {
+				c.change()
+			}
+
+			prevFiles := l.files
+			prevPackages := l.packages
+
+			result, err := l.Run(context.Background(), []string{pattern}, nil)
+			if err != nil {
+				t.Fatalf("expected no error, but received %v", err)
+			}
+
+			if e, a := c.expectedCached, result.CachedPackages; e != a {
+				t.Errorf("expected %d cached packages, but received %d", e, a)
+			}
+
+			if e, a := c.expectedFields, len(result.Diagnostics); e != a {
+				t.Errorf("expected %d diagnostics, but received %d", e, a)
+			}
+
+			parsed := []string{}
+			for filename, file := range l.files {
+				if prevFiles[filename].f != file.f {
+					parsed = append(parsed, filepath.Base(filename))
+				}
+			}
+			sort.Strings(parsed)
+
+			if e, a := c.expectedParsed, parsed; len(e) != len(a) || (len(e) > 0 && !reflect.DeepEqual(e, a)) {
+				t.Errorf("expected %v to be parsed, but received %v", e, a)
+			}
+
+			// the cache entries of unchanged packages are reused
+			for importPath, pkg := range l.packages {
+				prev, ok := prevPackages[importPath]
+				if e, a := ok && sameFiles(prev.files, pkg.files), prev.pkg == pkg.pkg; ok && e != a {
+					t.Errorf("%s: expected cache entry reused to be %t", importPath, e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package pepperlint
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats contains where the time of a run was spent. Stats are only collected
+// when Options.Stats is set.
+type Stats struct {
+	// Load is spent finding, reading and parsing the files that are linted,
+	// Cache is spent caching the declarations of every package, and Lint is
+	// spent running the rules. Cache is zero if no package needed linting.
+	Load  PhaseStats `json:"load"`
+	Cache PhaseStats `json:"cache"`
+	Lint  PhaseStats `json:"lint"`
+
+	// Rules are sorted by the time spent in them, longest first.
+	Rules []RuleStats `json:"rules"`
+}
+
+// PhaseStats contains the time and memory spent in a phase of a run. Bytes and
+// Allocs include the allocations of any other goroutine running at the same
+// time.
+type PhaseStats struct {
+	Time   time.Duration `json:"time_ns"`
+	Bytes  uint64        `json:"bytes"`
+	Allocs uint64        `json:"allocs"`
+}
+
+// RuleStats contains the time spent in the Validate methods of a rule, the
+// number of nodes it validated, and the number of errors it reported. Time is
+// summed over every package, so it can be longer than the lint phase when
+// packages are linted in parallel.
+type RuleStats struct {
+	Rule     string        `json:"rule"`
+	Time     time.Duration `json:"time_ns"`
+	Nodes    int           `json:"nodes"`
+	Findings int           `json:"findings"`
+}
+
+// phase measures the time and memory spent from when it was started.
+type phase struct {
+	start time.Time
+	mem   runtime.MemStats
+}
+
+func startPhase() *phase {
+	p := &phase{}
+	runtime.ReadMemStats(&p.mem)
+	p.start = time.Now()
+
+	return p
+}
+
+func (p *phase) stop() PhaseStats {
+	elapsed := time.Since(p.start)
+
+	mem := runtime.MemStats{}
+	runtime.ReadMemStats(&mem)
+
+	return PhaseStats{
+		Time:   elapsed,
+		Bytes:  mem.TotalAlloc - p.mem.TotalAlloc,
+		Allocs: mem.Mallocs - p.mem.Mallocs,
+	}
+}
+
+// ruleStats are the stats of every rule, keyed by name, which visitors add to
+// as they validate nodes.
+type ruleStats map[string]*RuleStats
+
+func (s ruleStats) record(rule string, elapsed time.Duration, err error) {
+	stats, ok := s[rule]
+	if !ok {
+		stats = &RuleStats{Rule: rule}
+		s[rule] = stats
+	}
+
+	stats.Time += elapsed
+	stats.Nodes++
+
+	if err, _ := splitControl(err); err != nil {
+		stats.Findings += count(err)
+	}
+}
+
+// ruleStatsSet merges the stats of visitors that run at the same time.
+type ruleStatsSet struct {
+	lock  sync.Mutex
+	rules ruleStats
+}
+
+func (s *ruleStatsSet) add(stats ruleStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for name, rule := range stats {
+		total, ok := s.rules[name]
+		if !ok {
+			total = &RuleStats{Rule: name}
+			s.rules[name] = total
+		}
+
+		total.Time += rule.Time
+		total.Nodes += rule.Nodes
+		total.Findings += rule.Findings
+	}
+}
+
+// sorted will return the stats of every rule, longest first.
+func (s *ruleStatsSet) sorted() []RuleStats {
+	rules := make([]RuleStats, 0, len(s.rules))
+	for _, rule := range s.rules {
+		rules = append(rules, *rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Time != rules[j].Time {
+			return rules[i].Time > rules[j].Time
+		}
+
+		return rules[i].Rule < rules[j].Rule
+	})
+
+	return rules
+}
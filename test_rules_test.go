@@ -3,6 +3,8 @@ package pepperlint
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
+	"strings"
 )
 
 type testExcludeNameTypeSpecRule struct {
@@ -66,3 +68,191 @@ func (v testExcludeMethod) ValidateFuncDecl(fnDecl *ast.FuncDecl) error {
 
 	return nil
 }
+
+// testCountCallExpr counts call and binary expressions and will return the
+// control value on any function declaration or file named SkipName.
+type testCountCallExpr struct {
+	SkipName string
+	Control  error
+	Count    int
+}
+
+func (v *testCountCallExpr) ValidateFile(f *ast.File) error {
+	if f.Name.Name == v.SkipName {
+		return v.Control
+	}
+
+	return nil
+}
+
+func (v *testCountCallExpr) ValidateFuncDecl(fnDecl *ast.FuncDecl) error {
+	if fnDecl.Name.Name == v.SkipName {
+		return v.Control
+	}
+
+	return nil
+}
+
+func (v *testCountCallExpr) ValidateCallExpr(expr *ast.CallExpr) error {
+	v.Count++
+	return nil
+}
+
+func (v *testCountCallExpr) ValidateBinaryExpr(expr *ast.BinaryExpr) error {
+	v.Count++
+	return nil
+}
+
+// testFilteredCallExpr is the same as testCountCallExpr but only asks for
+// call expressions.
+type testFilteredCallExpr struct {
+	testCountCallExpr
+}
+
+func (v *testFilteredCallExpr) NodeTypes() NodeType {
+	return CallExprNode
+}
+
+// testFuncCallExpr is the same as testCountCallExpr but only asks for function
+// declarations and expressions, so it has no file hook.
+type testFuncCallExpr struct {
+	testCountCallExpr
+}
+
+func (v *testFuncCallExpr) NodeTypes() NodeType {
+	return FuncDeclNode | CallExprNode | BinaryExprNode
+}
+
+// testAllRule satisfies every rule interface.
+type testAllRule struct{}
+
+func (testAllRule) ValidatePackage(*ast.Package) error             { return nil }
+func (testAllRule) ValidateFile(*ast.File) error                   { return nil }
+func (testAllRule) ValidateTypeSpec(*ast.TypeSpec) error           { return nil }
+func (testAllRule) ValidateValueSpec(*ast.ValueSpec) error         { return nil }
+func (testAllRule) ValidateGenDecl(*ast.GenDecl) error             { return nil }
+func (testAllRule) ValidateFuncDecl(*ast.FuncDecl) error           { return nil }
+func (testAllRule) ValidateCallExpr(*ast.CallExpr) error           { return nil }
+func (testAllRule) ValidateBinaryExpr(*ast.BinaryExpr) error       { return nil }
+func (testAllRule) ValidateAssignStmt(*ast.AssignStmt) error       { return nil }
+func (testAllRule) ValidateBlockStmt(*ast.BlockStmt) error         { return nil }
+func (testAllRule) ValidateReturnStmt(*ast.ReturnStmt) error       { return nil }
+func (testAllRule) ValidateIncDecStmt(*ast.IncDecStmt) error       { return nil }
+func (testAllRule) ValidateRangeStmt(*ast.RangeStmt) error         { return nil }
+func (testAllRule) ValidateStructType(*ast.StructType) error       { return nil }
+func (testAllRule) ValidateField(*ast.Field) error                 { return nil }
+func (testAllRule) ValidateFieldList(*ast.FieldList) error         { return nil }
+func (testAllRule) ValidateFuncType(*ast.FuncType) error           { return nil }
+func (testAllRule) ValidateInterfaceType(*ast.InterfaceType) error { return nil }
+func (testAllRule) ValidateArrayType(*ast.ArrayType) error         { return nil }
+func (testAllRule) ValidateChanType(*ast.ChanType) error           { return nil }
+func (testAllRule) ValidateMapType(*ast.MapType) error             { return nil }
+
+// testFieldNames reports the name of every field it is called with.
+type testFieldNames struct{}
+
+func (testFieldNames) ValidateField(field *ast.Field) error {
+	batchError := NewBatchError()
+	for _, name := range field.Names {
+		batchError.Add(fmt.Errorf("%s", name.Name))
+	}
+
+	return batchError.Return()
+}
+
+func (testFieldNames) RuleName() string {
+	return "test/field-names"
+}
+
+// testPanicCallExpr panics on every call expression.
+type testPanicCallExpr struct{}
+
+func (testPanicCallExpr) ValidateCallExpr(*ast.CallExpr) error {
+	panic("boom")
+}
+
+func (testPanicCallExpr) RuleName() string {
+	return "test/panic"
+}
+
+// testFieldPositions reports the name of every field it is called with at the
+// position of the field's name.
+type testFieldPositions struct {
+	fset *token.FileSet
+}
+
+func (r *testFieldPositions) ValidateField(field *ast.Field) error {
+	batchError := NewBatchError()
+	for _, name := range field.Names {
+		batchError.Add(NewErrorWrap(r.fset, name, name.Name))
+	}
+
+	return batchError.Return()
+}
+
+func (r *testFieldPositions) WithFileSet(fset *token.FileSet) {
+	r.fset = fset
+}
+
+func (r *testFieldPositions) CopyRule() Rule {
+	return &testFieldPositions{}
+}
+
+// testTestFiles reports the package name of every test file it is called with.
+type testTestFiles struct {
+	fset  *token.FileSet
+	cache *Cache
+}
+
+func (r *testTestFiles) ValidateFile(f *ast.File) error {
+	if !r.cache.IsTestFile() {
+		return nil
+	}
+
+	return NewErrorWrap(r.fset, f.Name, f.Name.Name)
+}
+
+func (r *testTestFiles) WithFileSet(fset *token.FileSet) {
+	r.fset = fset
+}
+
+func (r *testTestFiles) WithCache(cache *Cache) {
+	r.cache = cache
+}
+
+func (r *testTestFiles) CopyRule() Rule {
+	return &testTestFiles{}
+}
+
+// testFieldFixes reports every field name that is not upper case, along with a
+// fix that upper cases it.
+type testFieldFixes struct {
+	fset *token.FileSet
+}
+
+func (r *testFieldFixes) ValidateField(field *ast.Field) error {
+	batchError := NewBatchError()
+	for _, name := range field.Names {
+		upper := strings.ToUpper(name.Name)
+		if upper == name.Name {
+			continue
+		}
+
+		batchError.Add(NewErrorWrap(r.fset, name, name.Name).WithFix(SuggestedFix{
+			Message: "upper case " + name.Name,
+			Edits: []TextEdit{
+				NewTextEdit(r.fset, name, upper),
+			},
+		}))
+	}
+
+	return batchError.Return()
+}
+
+func (r *testFieldFixes) WithFileSet(fset *token.FileSet) {
+	r.fset = fset
+}
+
+func (r *testFieldFixes) CopyRule() Rule {
+	return &testFieldFixes{}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -288,6 +289,51 @@ func GetImportPathFromFullPath(path string) string {
 	return getImportPathFromFullPath([]string{gopath}, path)
 }
 
+// GetImportPathFromPackage will return the import path of the package based on
+// the directory of its files. External test packages, whose names end in _test,
+// are given the import path of their directory with a _test suffix, like the go
+// tool does, so they do not collide with the package they test.
+func GetImportPathFromPackage(pkg *ast.Package) string {
+	importPath := ""
+	for k := range pkg.Files {
+		importPath = GetImportPathFromFullPath(filepath.Dir(k))
+		break
+	}
+
+	if strings.HasSuffix(pkg.Name, "_test") && !strings.HasSuffix(importPath, "_test") {
+		importPath += "_test"
+	}
+
+	return importPath
+}
+
+// generatedRegexp matches the comment that marks a file as generated, as
+// described in https://golang.org/s/generatedcode.
+var generatedRegexp = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGenerated will return true if the file contains the standard comment for
+// generated code before its package clause.
+func IsGenerated(f *ast.File) bool {
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+
+		for _, c := range group.List {
+			if generatedRegexp.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsTestFilename will return true if the filename is of a go test file.
+func IsTestFilename(filename string) bool {
+	return strings.HasSuffix(filename, "_test.go")
+}
+
 func getImportPathFromFullPath(prefixes []string, path string) string {
 	for _, prefix := range prefixes {
 		if !strings.HasPrefix(path, prefix) {
@@ -2,6 +2,8 @@ package pepperlint
 
 import (
 	"go/ast"
+	"go/parser"
+	"go/token"
 	"reflect"
 	"testing"
 )
@@ -128,3 +130,50 @@ func TestGetImportPathFromFullPath(t *testing.T) {
 		})
 	}
 }
+
+func TestIsGenerated(t *testing.T) {
+	cases := []struct {
+		name     string
+		src      string
+		expected bool
+	}{
+		{
+			name:     "not generated",
+			src:      "package foo",
+			expected: false,
+		},
+		{
+			name:     "generated",
+			src:      "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage foo",
+			expected: true,
+		},
+		{
+			name:     "generated after license",
+			src:      "// Copyright\n\n// Code generated by stringer; DO NOT EDIT.\n\n// Package foo\npackage foo",
+			expected: true,
+		},
+		{
+			name:     "comment after package clause",
+			src:      "package foo\n\n// Code generated by mockgen. DO NOT EDIT.",
+			expected: false,
+		},
+		{
+			name:     "missing period",
+			src:      "// Code generated by hand. DO NOT EDIT\npackage foo",
+			expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), "foo.go", c.src, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if e, a := c.expected, IsGenerated(f); e != a {
+				t.Errorf("expected %t, but received %t", e, a)
+			}
+		})
+	}
+}
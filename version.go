@@ -0,0 +1,5 @@
+package pepperlint
+
+// Version is the version of pepperlint. It is part of the key of every disk
+// cache entry, so it needs to change whenever what is cached changes.
+const Version = "0.2.1"
@@ -3,11 +3,14 @@ package pepperlint
 import (
 	"go/ast"
 	"go/token"
-	"path/filepath"
+	"sort"
 )
 
 // Visitor is used to traferse a node and run the proper validaters
 // based on the node that is passed in.
+//
+// Rules are dispatched from tables built on the first visit, so any changes
+// made to Rules after visiting has started are not reflected.
 type Visitor struct {
 	Rules  Rules
 	Errors Errors
@@ -17,23 +20,68 @@ type Visitor struct {
 	FileSet *token.FileSet
 
 	currentPkgImportPath string
+
+	dispatch dispatch
+	prepared bool
+
+	// depth is the depth of the node currently being visited and fileDepth
+	// is the depth of the most recent ast.File.
+	depth     int
+	fileDepth int
+
+	// skipped maps rules that returned a control value to the depth of the
+	// node they returned it from. The rule is not called for nodes deeper
+	// than that depth, and the entry is removed once that node is left.
+	skipped map[interface{}]int
+
+	// stats are the stats of each rule, which are only collected when set.
+	stats ruleStats
+
+	logger Logger
 }
 
 // NewVisitor returns a new visitor and instantiates a new rule set from
-// the adders parameter.
+// the options provided. Options that implement RulesAdder add their own
+// rules, while all other options are registered by Rules.Register. The
+// logger of a WithLogger option is given to every rule, wherever it is in
+// the options.
 func NewVisitor(fset *token.FileSet, cache *Cache, opts ...Option) *Visitor {
 	v := &Visitor{
 		FileSet:       fset,
 		PackagesCache: cache,
+		logger:        DefaultLogger,
+	}
+
+	for _, o := range opts {
+		if opt, ok := o.(loggerOption); ok && opt.logger != nil {
+			v.logger = opt.logger
+		}
 	}
 
 	for _, o := range opts {
+		if _, ok := o.(loggerOption); ok {
+			continue
+		}
+
 		if opt, ok := o.(FileSetOption); ok {
 			opt.WithFileSet(fset)
 		}
 
+		if opt, ok := o.(LoggerOption); ok {
+			opt.WithLogger(With(v.logger, RuleField(ruleName(o))))
+		}
+
 		if opt, ok := o.(RulesAdder); ok {
-			opt.AddRules(&v.Rules)
+			rules := Rules{}
+			opt.AddRules(&rules)
+
+			if filter, ok := o.(NodeFilter); ok {
+				rules = rules.Filter(filter.NodeTypes())
+			}
+
+			v.Rules.Merge(rules)
+		} else {
+			v.Rules.Register(o)
 		}
 
 		if opt, ok := o.(CacheOption); ok {
@@ -41,240 +89,125 @@ func NewVisitor(fset *token.FileSet, cache *Cache, opts ...Option) *Visitor {
 		}
 	}
 
+	v.prepare()
 	return v
 }
 
+// prepare will build the dispatch tables from the visitor's rules.
+func (v *Visitor) prepare() {
+	v.prepared = true
+	v.dispatch = newDispatch(v.Rules)
+	v.skipped = map[interface{}]int{}
+}
+
 // Visit is our generic visitor that will visit each ast type and call
-// the appropriate rules based on what type the node is.
+// the appropriate rules based on what type the node is. Each node is
+// dispatched with a single type switch, and node types without any rules
+// are never dispatched.
 func (v *Visitor) Visit(node ast.Node) ast.Visitor {
 	if node == nil {
+		// ast.Walk calls Visit with nil once all children of a node have
+		// been walked.
+		v.leave()
 		return v
 	}
 
-	//Log("VISITING %p %T %v", node, node, node)
+	if !v.prepared {
+		v.prepare()
+	}
+
+	v.depth++
+
+	// control values returned within previous files no longer apply
+	if _, ok := node.(*ast.File); ok {
+		v.fileDepth = v.depth
+		v.unskip(v.depth)
+	}
+
+	// every rule has asked for this subtree to be skipped
+	if v.skippingAll() {
+		v.leave()
+		return nil
+	}
+
+	nodeTypes := v.dispatch.nodeTypes
 
 	switch t := node.(type) {
 	case *ast.Package:
-		for k := range t.Files {
-			v.PackagesCache.CurrentPkgImportPath = GetImportPathFromFullPath(filepath.Dir(k))
-			break
-		}
+		v.PackagesCache.CurrentPkgImportPath = GetImportPathFromPackage(t)
 
-		v.visitPackage(t)
+		v.validatePackage(t)
 	case *ast.File:
 		v.PackagesCache.CurrentASTFile = t
 
-		v.visitFile(t)
-	case ast.Decl:
-		v.visitDecl(t)
-	case ast.Expr:
-		// ignored due to visiting of ExprStmt in
-		// visitStmt
-		if binExpr, ok := t.(*ast.BinaryExpr); ok {
-			v.visitBinaryExpr(binExpr)
-		}
-	case ast.Spec:
-		v.visitSpec(t)
-	case ast.Stmt:
-		v.visitStmt(t)
-		// TOOD: May contain a bug that visits twice for both visitField
-		// and visitFieldList
-	case *ast.Field:
-		v.visitField(t)
-	case *ast.FieldList:
-		v.visitFieldList(t)
-	case *ast.Comment:
-	case *ast.CommentGroup:
-	default:
-		if t != nil {
-			Log("TODO: visit %T\n", t)
-		}
-		return v
-	}
+		v.validateFile(t)
 
-	return v
-}
+		// No rule is interested in anything nested in a file
+		if nodeTypes&^(PackageNode|FileNode) == 0 {
+			v.leave()
+			return nil
+		}
 
-func (v *Visitor) visitDecl(decl ast.Decl) {
-	switch t := decl.(type) {
-	case *ast.BadDecl:
-		// TODO: add error of bad declaration here
-		Log("TODO: visit %T\n", t)
+	// Declarations
 	case *ast.FuncDecl:
-		v.visitFuncDecl(t)
+		v.validateFuncDecl(t)
 	case *ast.GenDecl:
-		v.visitGenDecl(t)
-	default:
-		Log("TODO: visitDecl %T\n", t)
-	}
-}
-
-func (v *Visitor) visitExpr(expr ast.Expr) {
-	switch t := expr.(type) {
-	// covered by visitSpec which is why these do nothing
-	case *ast.Ident:
-	case *ast.ParenExpr:
-	case *ast.SelectorExpr:
-	case *ast.StarExpr:
-	case *ast.ArrayType:
-	case *ast.ChanType:
-	case *ast.FuncType:
-		v.visitFuncType(t)
-	case *ast.InterfaceType:
-	case *ast.MapType:
-	case *ast.StructType:
-	// Not covered by visitSpec
-	case *ast.BasicLit:
-		Log("TODO: visitExpr %T\n", t)
-	case *ast.CompositeLit:
-		Log("TODO: visitExpr %T\n", t)
-	case *ast.CallExpr:
-		v.visitCallExpr(t)
-	case *ast.BinaryExpr:
-		v.visitBinaryExpr(t)
-	case *ast.IndexExpr:
-		Log("TODO: visitExpr %T\n", t)
-	case *ast.KeyValueExpr:
-		Log("TODO: visitExpr %T\n", t)
-	default:
-		Log("T %T\n", t)
-	}
-}
+		v.validateGenDecl(t)
 
-func (v *Visitor) visitSpec(spec ast.Spec) {
-	switch t := spec.(type) {
-	case *ast.ImportSpec:
-		//log.Println("TODO: visit *ast.ImportSpec")
+	// Specifications
 	case *ast.TypeSpec:
 		v.visitTypeSpec(t)
 	case *ast.ValueSpec:
-		v.visitValueSpec(t)
-	default:
-		Log("TODO: visitDecl %T\n", t)
-	}
-}
+		v.validateValueSpec(t)
 
-func (v *Visitor) visitStmt(stmt ast.Stmt) {
-	switch t := stmt.(type) {
+	// Statements
 	case *ast.AssignStmt:
-		v.visitAssignStmt(t)
+		v.validateAssignStmt(t)
 	case *ast.BlockStmt:
-		v.visitBlockStmt(t)
+		v.validateBlockStmt(t)
 	case *ast.ExprStmt:
-		v.visitExpr(t.X)
+		v.visitExprStmt(t)
 	case *ast.ReturnStmt:
-		v.visitReturnStmt(t)
+		v.validateReturnStmt(t)
 	case *ast.IncDecStmt:
-		v.visitIncDecStmt(t)
+		v.validateIncDecStmt(t)
 	case *ast.RangeStmt:
-		v.visitRangeStmt(t)
-	default:
-		Log("TODO: visitStmt %T\n", t)
-	}
-}
+		v.validateRangeStmt(t)
 
-func (v *Visitor) visitTypeSpec(spec *ast.TypeSpec) {
-	if err := v.Rules.TypeSpecRules.ValidateTypeSpec(spec); err != nil {
-		v.Errors.Add(err)
-	}
-
-	switch t := spec.Type.(type) {
-	case *ast.Ident:
-		Log("TODO: visit *ast.Ident")
-	case *ast.ParenExpr:
-		Log("TODO: visit *ast.ParenExpr")
-	case *ast.SelectorExpr:
-		Log("TODO: visit *ast.SelectorExpr")
-	case *ast.StarExpr:
-		Log("TODO: visit *ast.StarExpr")
-
-	// Types
-	case *ast.ArrayType:
-		v.visitArrayType(t)
-	case *ast.ChanType:
-		v.visitChanType(t)
-	case *ast.FuncType:
-		v.visitFuncType(t)
-	case *ast.InterfaceType:
-		v.visitInterfaceType(t)
-	case *ast.MapType:
-		v.visitMapType(t)
-	case *ast.StructType:
-		v.visitStructType(t)
-	default:
-		Log("TODO: visitType %T\n", t)
-	}
-}
-
-func (v *Visitor) visitValueSpec(spec *ast.ValueSpec) {
-	if err := v.Rules.ValueSpecRules.ValidateValueSpec(spec); err != nil {
-		v.Errors.Add(err)
-	}
-}
-
-func (v *Visitor) visitStructType(s *ast.StructType) {
-	if err := v.Rules.StructTypeRules.ValidateStructType(s); err != nil {
-		v.Errors.Add(err)
-	}
-
-	v.visitFieldList(s.Fields)
-}
-
-func (v *Visitor) visitFieldList(fields *ast.FieldList) {
-	if err := v.Rules.FieldListRules.ValidateFieldList(fields); err != nil {
-		v.Errors.Add(err)
-	}
-
-	for _, field := range fields.List {
-		v.visitField(field)
-	}
-}
-
-func (v *Visitor) visitField(field *ast.Field) {
-	if err := v.Rules.FieldRules.ValidateField(field); err != nil {
-		v.Errors.Add(err)
-	}
-}
-
-func (v *Visitor) visitArrayType(array *ast.ArrayType) {
-	if err := v.Rules.ArrayTypeRules.ValidateArrayType(array); err != nil {
-		v.Errors.Add(err)
-	}
-}
-
-func (v *Visitor) visitMapType(m *ast.MapType) {
-	if err := v.Rules.MapTypeRules.ValidateMapType(m); err != nil {
-		v.Errors.Add(err)
-	}
-}
-
-func (v *Visitor) visitChanType(ch *ast.ChanType) {
-	if err := v.Rules.ChanTypeRules.ValidateChanType(ch); err != nil {
-		v.Errors.Add(err)
-	}
-}
+	// Expressions
+	case *ast.BinaryExpr:
+		v.validateBinaryExpr(t)
 
-func (v *Visitor) visitFuncType(fn *ast.FuncType) {
-	if err := v.Rules.FuncTypeRules.ValidateFuncType(fn); err != nil {
-		v.Errors.Add(err)
+	// Fields are walked after their field list, so each is only
+	// validated once.
+	case *ast.Field:
+		v.validateField(t)
+	case *ast.FieldList:
+		v.validateFieldList(t)
 	}
-}
 
-func (v *Visitor) visitInterfaceType(iface *ast.InterfaceType) {
-	if err := v.Rules.InterfaceTypeRules.ValidateInterfaceType(iface); err != nil {
-		v.Errors.Add(err)
-	}
+	return v
 }
 
-func (v *Visitor) visitFuncDecl(fnDecl *ast.FuncDecl) {
-	if err := v.Rules.FuncDeclRules.ValidateFuncDecl(fnDecl); err != nil {
-		v.Errors.Add(err)
+// visitExprStmt validates the expression of an expression statement. Call
+// expressions are only validated as statements, while rules check calls
+// within other statements themselves. Binary expressions are validated
+// wherever they are walked, so they are not validated here as well.
+func (v *Visitor) visitExprStmt(stmt *ast.ExprStmt) {
+	switch t := stmt.X.(type) {
+	case *ast.FuncType:
+		v.validateFuncType(t)
+	case *ast.CallExpr:
+		v.validateCallExpr(t)
 	}
 }
 
-// visitGenDecl will happen before any visiting of more specific specs, ie XXXSpec.
-// This function can be used to grab documentation or other metadata to further
-// validation used on more specific rules.
+// visitTypeSpec will validate the spec followed by the spec's type.
+//
+// Since the GenDecl is walked first, GenDecl rules are validated before any
+// visiting of more specific specs, ie XXXSpec. This can be used to grab
+// documentation or other metadata to further validation used on more specific
+// rules.
 //
 // An example of this would be how DeprecateStructRule works. The rule will visit
 // general declarations first to populate the documentation of the type spec. Docs
@@ -287,64 +220,43 @@ func (v *Visitor) visitFuncDecl(fnDecl *ast.FuncDecl) {
 //     // TypeSpec docs!
 //     foo struct{}
 // )
-func (v *Visitor) visitGenDecl(decl *ast.GenDecl) *Visitor {
-	if err := v.Rules.GenDeclRules.ValidateGenDecl(decl); err != nil {
-		v.Errors.Add(err)
-	}
-
-	return v
-}
-
-func (v *Visitor) visitAssignStmt(stmt *ast.AssignStmt) {
-	if err := v.Rules.AssignStmtRules.ValidateAssignStmt(stmt); err != nil {
-		v.Errors.Add(err)
-	}
-}
-
-func (v *Visitor) visitBlockStmt(stmt *ast.BlockStmt) {
-	if err := v.Rules.BlockStmtRules.ValidateBlockStmt(stmt); err != nil {
-		v.Errors.Add(err)
-	}
-}
-
-func (v *Visitor) visitCallExpr(expr *ast.CallExpr) {
-	if err := v.Rules.CallExprRules.ValidateCallExpr(expr); err != nil {
-		v.Errors.Add(err)
-	}
-}
-
-func (v *Visitor) visitReturnStmt(stmt *ast.ReturnStmt) {
-	if err := v.Rules.ReturnStmtRules.ValidateReturnStmt(stmt); err != nil {
-		v.Errors.Add(err)
-	}
-}
+func (v *Visitor) visitTypeSpec(spec *ast.TypeSpec) {
+	v.validateTypeSpec(spec)
 
-func (v *Visitor) visitFile(f *ast.File) {
-	if err := v.Rules.FileRules.ValidateFile(f); err != nil {
-		v.Errors.Add(err)
+	switch t := spec.Type.(type) {
+	case *ast.ArrayType:
+		v.validateArrayType(t)
+	case *ast.ChanType:
+		v.validateChanType(t)
+	case *ast.FuncType:
+		v.validateFuncType(t)
+	case *ast.InterfaceType:
+		v.validateInterfaceType(t)
+	case *ast.MapType:
+		v.validateMapType(t)
+	case *ast.StructType:
+		v.validateStructType(t)
 	}
 }
 
-func (v *Visitor) visitPackage(pkg *ast.Package) {
-	if err := v.Rules.PackageRules.ValidatePackage(pkg); err != nil {
-		v.Errors.Add(err)
+// WalkPackage will walk the package much like ast.Walk, but the package's files
+// are walked in order of their filenames instead of in map order. This keeps
+// the order in which errors are found, and files are cached, the same between
+// runs.
+func WalkPackage(v ast.Visitor, pkg *ast.Package) {
+	if v = v.Visit(pkg); v == nil {
+		return
 	}
-}
 
-func (v *Visitor) visitBinaryExpr(expr *ast.BinaryExpr) {
-	if err := v.Rules.BinaryExprRules.ValidateBinaryExpr(expr); err != nil {
-		v.Errors.Add(err)
+	filenames := make([]string, 0, len(pkg.Files))
+	for filename := range pkg.Files {
+		filenames = append(filenames, filename)
 	}
-}
 
-func (v *Visitor) visitIncDecStmt(stmt *ast.IncDecStmt) {
-	if err := v.Rules.IncDecStmtRules.ValidateIncDecStmt(stmt); err != nil {
-		v.Errors.Add(err)
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		ast.Walk(v, pkg.Files[filename])
 	}
-}
 
-func (v *Visitor) visitRangeStmt(stmt *ast.RangeStmt) {
-	if err := v.Rules.RangeStmtRules.ValidateRangeStmt(stmt); err != nil {
-		v.Errors.Add(err)
-	}
+	v.Visit(nil)
 }
@@ -0,0 +1,142 @@
+package pepperlint_test
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-toolset/pepperlint"
+	"github.com/go-toolset/pepperlint/rules/core/deprecated"
+)
+
+// benchCorpus is the root directory of the packages that are linted during
+// benchmarks. PEPPERLINT_BENCH_CORPUS can be set to point at a large code
+// base, such as a checkout of github.com/aws/aws-sdk-go, and otherwise the
+// go packages from GOROOT are used.
+func benchCorpus() string {
+	if v := os.Getenv("PEPPERLINT_BENCH_CORPUS"); len(v) > 0 {
+		return v
+	}
+
+	return filepath.Join(build.Default.GOROOT, "src", "go")
+}
+
+// loadBenchCorpus will parse every package within the corpus.
+func loadBenchCorpus(b *testing.B) (*token.FileSet, []*ast.Package) {
+	root := benchCorpus()
+	fset := token.NewFileSet()
+	pkgs := []*ast.Package{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if name := info.Name(); path != root && (name == "testdata" || strings.HasPrefix(name, ".")) {
+			return filepath.SkipDir
+		}
+
+		parsed, err := parser.ParseDir(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			// skip packages that do not parse
+			return nil
+		}
+
+		names := []string{}
+		for name := range parsed {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			pkgs = append(pkgs, parsed[name])
+		}
+
+		return nil
+	})
+
+	if err != nil || len(pkgs) == 0 {
+		b.Skipf("unable to load benchmark corpus %q: %v", root, err)
+	}
+
+	return fset, pkgs
+}
+
+func BenchmarkCache(b *testing.B) {
+	_, pkgs := loadBenchCorpus(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache := pepperlint.NewCache()
+		for _, pkg := range pkgs {
+			ast.Walk(cache, pkg)
+		}
+	}
+}
+
+func BenchmarkVisitor(b *testing.B) {
+	fset, pkgs := loadBenchCorpus(b)
+
+	cache := pepperlint.NewCache()
+	for _, pkg := range pkgs {
+		ast.Walk(cache, pkg)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := pepperlint.NewVisitor(fset, cache, deprecated.NewRule(fset))
+		for _, pkg := range pkgs {
+			ast.Walk(v, pkg)
+		}
+	}
+}
+
+func BenchmarkVisitorNoRules(b *testing.B) {
+	fset, pkgs := loadBenchCorpus(b)
+
+	cache := pepperlint.NewCache()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := pepperlint.NewVisitor(fset, cache)
+		for _, pkg := range pkgs {
+			ast.Walk(v, pkg)
+		}
+	}
+}
+
+// noopRule never reports anything and is used to measure the cost of
+// dispatching nodes to rules.
+type noopRule struct{}
+
+func (noopRule) ValidateFuncDecl(*ast.FuncDecl) error     { return nil }
+func (noopRule) ValidateAssignStmt(*ast.AssignStmt) error { return nil }
+func (noopRule) ValidateCallExpr(*ast.CallExpr) error     { return nil }
+func (noopRule) ValidateBinaryExpr(*ast.BinaryExpr) error { return nil }
+func (noopRule) ValidateField(*ast.Field) error           { return nil }
+
+func BenchmarkVisitorNoopRule(b *testing.B) {
+	fset, pkgs := loadBenchCorpus(b)
+
+	cache := pepperlint.NewCache()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := pepperlint.NewVisitor(fset, cache, noopRule{})
+		for _, pkg := range pkgs {
+			ast.Walk(v, pkg)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package pepperlint
 
 import (
+	"bytes"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -75,3 +78,370 @@ func TestVisitor(t *testing.T) {
 		})
 	}
 }
+
+func TestVisitorControl(t *testing.T) {
+	const code = `package foo
+	func a() {
+		b()
+		c()
+	}
+
+	func helper() {
+		b()
+		c()
+		d()
+	}
+
+	func e() {
+		b()
+	}
+
+	var f = 1 + 2`
+
+	cases := []struct {
+		name           string
+		rule           *testCountCallExpr
+		expectedCount  int
+		expectedOthers int
+	}{
+		{
+			name:           "continue",
+			rule:           &testCountCallExpr{},
+			expectedCount:  7,
+			expectedOthers: 7,
+		},
+		{
+			name: "skip children",
+			rule: &testCountCallExpr{
+				SkipName: "helper",
+				Control:  SkipChildren,
+			},
+			expectedCount:  4,
+			expectedOthers: 7,
+		},
+		{
+			name: "skip file",
+			rule: &testCountCallExpr{
+				SkipName: "helper",
+				Control:  SkipFile,
+			},
+			expectedCount:  2,
+			expectedOthers: 7,
+		},
+		{
+			name: "skip file from file",
+			rule: &testCountCallExpr{
+				SkipName: "foo",
+				Control:  SkipFile,
+			},
+			expectedCount:  0,
+			expectedOthers: 7,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			node, err := parser.ParseFile(fset, "foo.go", code, 0)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			other := &testCountCallExpr{}
+			v := NewVisitor(fset, &Cache{}, c.rule, other)
+			ast.Walk(v, node)
+
+			if e, a := c.expectedCount, c.rule.Count; e != a {
+				t.Errorf("expected %d, but received %d", e, a)
+			}
+
+			if e, a := c.expectedOthers, other.Count; e != a {
+				t.Errorf("expected %d, but received %d", e, a)
+			}
+
+			if len(v.Errors) != 0 {
+				t.Errorf("expected no errors, but received %v", v.Errors)
+			}
+		})
+	}
+}
+
+func TestVisitorControlFiles(t *testing.T) {
+	srcs := []string{
+		"package foo\nfunc a() { b() }\nfunc helper() { c() }\nvar x = 1 + 2\nfunc d() { e() }",
+		"package foo\nfunc f() { g() }\nvar h = 1 + 2",
+	}
+
+	cases := []struct {
+		name          string
+		control       error
+		expectedCount int
+	}{
+		{
+			name:          "skip children",
+			control:       SkipChildren,
+			expectedCount: 5,
+		},
+		{
+			name:          "skip file",
+			control:       SkipFile,
+			expectedCount: 3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			files := []*ast.File{}
+			for i, src := range srcs {
+				f, err := parser.ParseFile(fset, fmt.Sprintf("%d.go", i), src, 0)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+
+				files = append(files, f)
+			}
+
+			// the rule has no file hook, so nothing but leaving the subtree or
+			// file can end what it skips
+			rule := &testFuncCallExpr{
+				testCountCallExpr: testCountCallExpr{
+					SkipName: "helper",
+					Control:  c.control,
+				},
+			}
+			other := &testCountCallExpr{}
+
+			v := NewVisitor(fset, &Cache{}, rule, other)
+			for _, f := range files {
+				ast.Walk(v, f)
+			}
+
+			if e, a := c.expectedCount, rule.Count; e != a {
+				t.Errorf("expected %d, but received %d", e, a)
+			}
+
+			if e, a := 6, other.Count; e != a {
+				t.Errorf("expected %d, but received %d", e, a)
+			}
+		})
+	}
+}
+
+func TestVisitorExprStmt(t *testing.T) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "foo.go", "package foo\nfunc a() {\n\tb()\n\tc == d\n}", 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the call and the binary expression are each validated once, even though
+	// both are the expression of a statement
+	rule := &testCountCallExpr{}
+	ast.Walk(NewVisitor(fset, &Cache{}, rule), node)
+
+	if e, a := 2, rule.Count; e != a {
+		t.Errorf("expected %d, but received %d", e, a)
+	}
+}
+
+func TestVisitorNodeFilter(t *testing.T) {
+	rule := &testFilteredCallExpr{
+		testCountCallExpr: testCountCallExpr{
+			SkipName: "foo",
+			Control:  SkipFile,
+		},
+	}
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "foo.go", "package foo\nfunc a() { b() }", 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	v := NewVisitor(fset, &Cache{}, rule)
+
+	if e, a := CallExprNode, v.Rules.NodeTypes(); e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	// ValidateFile would have skipped the file if it was not filtered out
+	ast.Walk(v, node)
+	if e, a := 1, rule.Count; e != a {
+		t.Errorf("expected %d, but received %d", e, a)
+	}
+}
+
+func TestVisitorFieldsOnce(t *testing.T) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "foo.go", `package foo
+	type Foo struct {
+		A int
+		B string
+	}
+
+	func bar(c int) {}`, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	v := NewVisitor(fset, &Cache{}, testFieldNames{})
+	ast.Walk(v, node)
+
+	names := []string{}
+	for _, diag := range v.Errors.Diagnostics() {
+		if e, a := "test/field-names", diag.Rule; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+
+		names = append(names, diag.Error())
+	}
+
+	if e, a := []string{"A", "B", "c"}, names; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+type testFileOrder struct {
+	Filenames []string
+	fset      *token.FileSet
+}
+
+func (r *testFileOrder) ValidateFile(f *ast.File) error {
+	r.Filenames = append(r.Filenames, r.fset.Position(f.Pos()).Filename)
+	return nil
+}
+
+func TestWalkPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := &ast.Package{
+		Name:  "foo",
+		Files: map[string]*ast.File{},
+	}
+
+	expected := []string{}
+	for i := 0; i < 10; i++ {
+		filename := fmt.Sprintf("%d.go", i)
+		f, err := parser.ParseFile(fset, filename, "package foo", 0)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		pkg.Files[filename] = f
+		expected = append(expected, filename)
+	}
+
+	rule := &testFileOrder{fset: fset}
+	WalkPackage(NewVisitor(fset, &Cache{}, rule), pkg)
+
+	if e, a := expected, rule.Filenames; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestVisitorRulePanic(t *testing.T) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "foo.go", `package foo
+	type Foo struct {
+		A int
+	}
+
+	func bar() {
+		baz()
+		qux()
+	}
+
+	type Bar struct {
+		B int
+	}`, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var buf bytes.Buffer
+	v := NewVisitor(fset, &Cache{}, WithLogger(NewLogger(&buf, DebugLevel)), testPanicCallExpr{}, testFieldNames{})
+	ast.Walk(v, node)
+
+	diags := v.Errors.Diagnostics()
+	if e, a := 3, len(diags); e != a {
+		t.Fatalf("expected %v, but received %v: %v", e, a, diags)
+	}
+
+	internalErrs := 0
+	for _, diag := range diags {
+		internalErr, ok := diag.Err.(*InternalError)
+		if !ok {
+			continue
+		}
+
+		internalErrs++
+		if e, a := "test/panic", internalErr.Rule; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+
+		if e, a := 7, internalErr.Pos.Line; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+
+		if e, a := "boom", internalErr.Value; e != a {
+			t.Errorf("expected %v, but received %v", e, a)
+		}
+
+		// the stack is only logged, so the message is the same from run to run
+		if len(internalErr.Stack) == 0 {
+			t.Errorf("expected a stack")
+		}
+
+		if e, a := "foo.go:7:3: internal error: rule test/panic panicked: boom", internalErr.Error(); e != a {
+			t.Errorf("expected %q, but received %q", e, a)
+		}
+	}
+
+	// the panicking rule stops validating the file, while other rules continue
+	if e, a := 1, internalErrs; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+
+	if !strings.Contains(buf.String(), "DEBUG rule panicked: boom") {
+		t.Errorf("expected the panic to be logged, but received %q", buf.String())
+	}
+
+	if !strings.Contains(buf.String(), "stack=") {
+		t.Errorf("expected the stack to be logged, but received %q", buf.String())
+	}
+}
+
+func TestVisitorRulePanicFiles(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := &ast.Package{
+		Name:  "foo",
+		Files: map[string]*ast.File{},
+	}
+
+	for _, filename := range []string{"a.go", "b.go"} {
+		f, err := parser.ParseFile(fset, filename, "package foo\nfunc a() {\n\tb()\n\tc()\n}", 0)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		pkg.Files[filename] = f
+	}
+
+	v := NewVisitor(fset, &Cache{}, testPanicCallExpr{})
+	WalkPackage(v, pkg)
+
+	// the panicking rule is only skipped for the remainder of each file
+	filenames := []string{}
+	for _, diag := range v.Errors.Diagnostics() {
+		internalErr, ok := diag.Err.(*InternalError)
+		if !ok {
+			t.Fatalf("expected an internal error, but received %v", diag.Err)
+		}
+
+		filenames = append(filenames, internalErr.Pos.Filename)
+	}
+
+	if e, a := []string{"a.go", "b.go"}, filenames; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
@@ -0,0 +1,113 @@
+package pepperlint
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileStamp is used to tell whether a file changed without reading it.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch will run the linter once, and again every time a go file matched by
+// patterns is added, removed or changed, until ctx is cancelled. fn is called
+// with the outcome of every run. Files are polled every interval, and since
+// the linter keeps what has not changed, only the packages affected by a
+// change are linted again. The included packages are not watched.
+//
+// If the files cannot be stamped, such as when a watched directory is removed,
+// fn is called with the error once, and the files are linted again as soon as
+// they can be stamped.
+func (l *Linter) Watch(ctx context.Context, patterns []string, interval time.Duration, fn func(Result, error)) error {
+	var prev map[string]fileStamp
+	var prevErr error
+	for {
+		stamps, err := l.stamps(ctx, patterns)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			// the previous stamps are kept, since nothing is known about the
+			// files until they can be stamped again
+			if prevErr == nil || prevErr.Error() != err.Error() {
+				fn(Result{}, err)
+			}
+			prevErr = err
+		} else if prevErr != nil || prev == nil || !sameStamps(prev, stamps) {
+			result, err := l.Run(ctx, patterns, nil)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			fn(result, err)
+			prev, prevErr = stamps, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// stamps will return the stamp of every go file matched by patterns, keyed by
+// filename.
+func (l *Linter) stamps(ctx context.Context, patterns []string) (map[string]fileStamp, error) {
+	set := packageSet{}
+	stamps := map[string]fileStamp{}
+
+	for _, pattern := range patterns {
+		targets, err := set.expandPattern(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, target := range targets {
+			info, err := os.Stat(target)
+			if err != nil {
+				return nil, err
+			}
+
+			if !info.IsDir() {
+				stamps[target] = fileStamp{info.ModTime(), info.Size()}
+				continue
+			}
+
+			infos, err := ioutil.ReadDir(target)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, info := range infos {
+				if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
+					stamps[filepath.Join(target, info.Name())] = fileStamp{info.ModTime(), info.Size()}
+				}
+			}
+		}
+	}
+
+	return stamps, nil
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for filename, stamp := range a {
+		other, ok := b[filename]
+		if !ok || !stamp.modTime.Equal(other.modTime) || stamp.size != other.size {
+			return false
+		}
+	}
+
+	return true
+}
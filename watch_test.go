@@ -0,0 +1,160 @@
+package pepperlint
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLinterWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "foo.go")
+	write := func(src string, modTime time.Time) {
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// modification times are set explicitly, since they may not change
+		// between writes on file systems with a coarse resolution
+		if err := os.Chtimes(filename, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("package foo\n\ntype Foo struct {\n\tA int\n}\n", start)
+
+	l, err := NewLinter(context.Background(), Options{
+		Rules: []CopyRuler{&testFieldPositions{}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan Result)
+	done := make(chan error, 1)
+	go func() {
+		done <- l.Watch(ctx, []string{dir}, time.Millisecond, func(result Result, err error) {
+			if err != nil {
+				t.Errorf("expected no error, but received %v", err)
+			}
+
+			results <- result
+		})
+	}()
+
+	receive := func() Result {
+		select {
+		case result := <-results:
+			return result
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for a run")
+		}
+
+		return Result{}
+	}
+
+	if e, a := 1, len(receive().Diagnostics); e != a {
+		t.Errorf("expected %d diagnostics, but received %d", e, a)
+	}
+
+	write("package foo\n\ntype Foo struct {\n\tA int\n\tB int\n}\n", start.Add(time.Minute))
+	if e, a := 2, len(receive().Diagnostics); e != a {
+		t.Errorf("expected %d diagnostics, but received %d", e, a)
+	}
+
+	cancel()
+	if e, a := context.Canceled, <-done; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}
+
+func TestLinterWatchError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pepperlint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pkgDir := filepath.Join(dir, "foo")
+	write := func() {
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		src := []byte("package foo\n\ntype Foo struct {\n\tA int\n}\n")
+		if err := ioutil.WriteFile(filepath.Join(pkgDir, "foo.go"), src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write()
+
+	l, err := NewLinter(context.Background(), Options{
+		Rules: []CopyRuler{&testFieldPositions{}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but received %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- l.Watch(ctx, []string{pkgDir}, time.Millisecond, func(result Result, err error) {
+			errs <- err
+		})
+	}()
+
+	receive := func() error {
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for a run")
+		}
+
+		return nil
+	}
+
+	if err := receive(); err != nil {
+		t.Errorf("expected no error, but received %v", err)
+	}
+
+	// the missing directory is reported once, however many polls fail
+	if err := os.RemoveAll(pkgDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := receive(); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if e, a := 0, len(errs); e != a {
+		t.Errorf("expected %d more runs, but received %d", e, a)
+	}
+
+	// and the files are linted again once they are back
+	write()
+	if err := receive(); err != nil {
+		t.Errorf("expected no error, but received %v", err)
+	}
+
+	cancel()
+	if e, a := context.Canceled, <-done; e != a {
+		t.Errorf("expected %v, but received %v", e, a)
+	}
+}